@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"time"
+
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/config"
+	"github.com/spf13/cobra"
+)
+
+func newCacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Manage the cached credentials, identities and eks tokens",
+		Long: `gotoaws caches caller identities, assumed role credentials, mfa sessions and eks tokens
+in ~/.config/configstore/gotoaws-cache until shortly before they expire.`,
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(
+		newCacheListCmd(),
+		newCacheClearCmd(),
+	)
+
+	return cmd
+}
+
+func newCacheListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "list",
+		Short:         "List the cached entries",
+		Example:       "gotoaws cache list --output json",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			entries, err := config.ListCache()
+			if err != nil {
+				return err
+			}
+
+			table := &internal.Table{Header: []string{"KIND", "KEY", "EXPIRES"}}
+			for _, e := range entries {
+				expires := e.Expires.Local().Format(time.RFC3339)
+				if e.Expired {
+					expires += " (expired)"
+				}
+
+				table.AddRow(e.Kind, e.Key, expires)
+			}
+
+			return internal.PrintOutput(entries, table)
+		},
+	}
+
+	return cmd
+}
+
+func newCacheClearCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "clear",
+		Short:         "Remove all cached entries",
+		Example:       "gotoaws cache clear",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			n, err := config.ClearCache()
+			if err != nil {
+				return err
+			}
+
+			internal.PrintInfof("Removed %d cached entries", n)
+
+			return nil
+		},
+	}
+
+	return cmd
+}
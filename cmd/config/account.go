@@ -0,0 +1,98 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hupe1980/gotoaws/pkg/iam"
+	"github.com/spf13/viper"
+)
+
+// Account is a named entry of the accounts section of the config file.
+type Account struct {
+	// Name of the account
+	Name string `mapstructure:"-"`
+
+	// ID of the account. It is only required if the role is a name.
+	ID string `mapstructure:"id"`
+
+	// Role is the name or arn of the role to assume
+	Role string `mapstructure:"role"`
+}
+
+// RoleARN returns the arn of the role to assume.
+func (a *Account) RoleARN() string {
+	return iam.RoleARN(a.ID, a.Role)
+}
+
+// Accounts returns the accounts of the config file sorted by name.
+func Accounts() ([]Account, error) {
+	var m map[string]Account
+	if err := viper.UnmarshalKey("accounts", &m); err != nil {
+		return nil, fmt.Errorf("invalid accounts config: %w", err)
+	}
+
+	accounts := make([]Account, 0, len(m))
+
+	for name, a := range m {
+		a.Name = name
+		accounts = append(accounts, a)
+	}
+
+	sort.Slice(accounts, func(i, j int) bool {
+		return accounts[i].Name < accounts[j].Name
+	})
+
+	return accounts, nil
+}
+
+// FindAccounts returns the accounts with the given names. The name all selects every account.
+func FindAccounts(names []string) ([]Account, error) {
+	accounts, err := Accounts()
+	if err != nil {
+		return nil, err
+	}
+
+	var found []Account
+
+	for _, name := range names {
+		if strings.EqualFold(name, "all") {
+			if len(accounts) == 0 {
+				return nil, errors.New("no accounts configured")
+			}
+
+			for i := range accounts {
+				if accounts[i].Role == "" {
+					return nil, fmt.Errorf("account %s has no role", accounts[i].Name)
+				}
+			}
+
+			return accounts, nil
+		}
+
+		account, err := findAccount(accounts, name)
+		if err != nil {
+			return nil, err
+		}
+
+		found = append(found, *account)
+	}
+
+	return found, nil
+}
+
+func findAccount(accounts []Account, name string) (*Account, error) {
+	for i := range accounts {
+		if strings.EqualFold(accounts[i].Name, name) {
+			if accounts[i].Role == "" {
+				return nil, fmt.Errorf("account %s has no role", accounts[i].Name)
+			}
+
+			return &accounts[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("account %s not found", name)
+}
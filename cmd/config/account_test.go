@@ -0,0 +1,45 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccounts(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.SetConfigType("json")
+	require.NoError(t, viper.ReadConfig(strings.NewReader(`{
+		"accounts": {
+			"prod": {"id": "111111111111", "role": "OrganizationAccountAccessRole"},
+			"dev": {"role": "arn:aws:iam::222222222222:role/Admin"},
+			"broken": {"id": "333333333333"}
+		}
+	}`)))
+
+	t.Run("by name", func(t *testing.T) {
+		accounts, err := FindAccounts([]string{"PROD", "dev"})
+		require.NoError(t, err)
+		require.Len(t, accounts, 2)
+		assert.Equal(t, "arn:aws:iam::111111111111:role/OrganizationAccountAccessRole", accounts[0].RoleARN())
+		assert.Equal(t, "arn:aws:iam::222222222222:role/Admin", accounts[1].RoleARN())
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := FindAccounts([]string{"staging"})
+		assert.EqualError(t, err, "account staging not found")
+	})
+
+	t.Run("without role", func(t *testing.T) {
+		_, err := FindAccounts([]string{"broken"})
+		assert.EqualError(t, err, "account broken has no role")
+
+		_, err = FindAccounts([]string{"all"})
+		assert.EqualError(t, err, "account broken has no role")
+	})
+}
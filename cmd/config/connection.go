@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// ConnectionKind names the command a connection runs.
+type ConnectionKind string
+
+const (
+	KindEC2Session ConnectionKind = "ec2-session"
+	KindEC2SSH     ConnectionKind = "ec2-ssh"
+	KindEC2Fwd     ConnectionKind = "ec2-fwd"
+	KindECSExec    ConnectionKind = "ecs-exec"
+	KindEKSExec    ConnectionKind = "eks-exec"
+	KindEKSFwd     ConnectionKind = "eks-fwd"
+	KindEKSLogs    ConnectionKind = "eks-logs"
+)
+
+type connectionCommand struct {
+	path       []string
+	targetFlag string
+}
+
+var connectionCommands = map[ConnectionKind]connectionCommand{
+	KindEC2Session: {path: []string{"ec2", "session"}, targetFlag: "target"},
+	KindEC2SSH:     {path: []string{"ec2", "ssh"}, targetFlag: "target"},
+	KindEC2Fwd:     {path: []string{"ec2", "fwd"}, targetFlag: "target"},
+	KindECSExec:    {path: []string{"ecs", "exec"}, targetFlag: "task"},
+	KindEKSExec:    {path: []string{"eks", "exec"}, targetFlag: "pod"},
+	KindEKSFwd:     {path: []string{"eks", "fwd"}, targetFlag: "pod"},
+	KindEKSLogs:    {path: []string{"eks", "logs"}, targetFlag: "pod"},
+}
+
+// Connection is a named entry of the connections section of the config file.
+type Connection struct {
+	// Name of the connection
+	Name string `mapstructure:"-"`
+
+	// Kind of the connection, e.g. ec2-fwd
+	Kind ConnectionKind `mapstructure:"kind"`
+
+	// Profile is the AWS profile to use
+	Profile string `mapstructure:"profile"`
+
+	// Region is the AWS region to use
+	Region string `mapstructure:"region"`
+
+	// Target identifies the instance (ec2), task (ecs) or pod (eks)
+	Target string `mapstructure:"target"`
+
+	// Parameters are passed as flags to the command. Lists are passed as repeated flags.
+	Parameters map[string]interface{} `mapstructure:"parameters"`
+}
+
+// Connections returns the connections of the config file sorted by name.
+func Connections() ([]Connection, error) {
+	var m map[string]Connection
+	if err := viper.UnmarshalKey("connections", &m); err != nil {
+		return nil, fmt.Errorf("invalid connections config: %w", err)
+	}
+
+	connections := make([]Connection, 0, len(m))
+
+	for name, c := range m {
+		c.Name = name
+		connections = append(connections, c)
+	}
+
+	sort.Slice(connections, func(i, j int) bool {
+		return connections[i].Name < connections[j].Name
+	})
+
+	return connections, nil
+}
+
+// FindConnection returns the connection with the given name. Names are case-insensitive like all config keys.
+func FindConnection(name string) (*Connection, error) {
+	connections, err := Connections()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range connections {
+		if strings.EqualFold(connections[i].Name, name) {
+			return &connections[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("connection %s not found", name)
+}
+
+// Args returns the command line that runs the connection.
+func (c *Connection) Args() ([]string, error) {
+	cmd, ok := connectionCommands[c.Kind]
+	if !ok {
+		return nil, fmt.Errorf("connection %s has unknown kind %q", c.Name, c.Kind)
+	}
+
+	args := append([]string{}, cmd.path...)
+
+	if c.Profile != "" {
+		args = append(args, "--profile", c.Profile)
+	}
+
+	if c.Region != "" {
+		args = append(args, "--region", c.Region)
+	}
+
+	if c.Target != "" {
+		args = append(args, fmt.Sprintf("--%s", cmd.targetFlag), c.Target)
+	}
+
+	keys := make([]string, 0, len(c.Parameters))
+	for k := range c.Parameters {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		switch v := c.Parameters[k].(type) {
+		case []interface{}:
+			for _, item := range v {
+				args = append(args, fmt.Sprintf("--%s=%v", k, item))
+			}
+		default:
+			args = append(args, fmt.Sprintf("--%s=%v", k, v))
+		}
+	}
+
+	return args, nil
+}
@@ -0,0 +1,69 @@
+package config
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnections(t *testing.T) {
+	viper.Reset()
+	defer viper.Reset()
+
+	viper.SetConfigType("json")
+	require.NoError(t, viper.ReadConfig(strings.NewReader(`{
+		"connections": {
+			"prod-db": {
+				"kind": "ec2-fwd",
+				"profile": "prod",
+				"region": "eu-central-1",
+				"target": "bastion-a",
+				"parameters": {"forward": ["5432:db:5432", "6379:redis:6379"], "keepalive": "1m"}
+			},
+			"api": {"kind": "eks-exec", "target": "api", "parameters": {"cluster": "staging"}},
+			"broken": {"kind": "rds-exec"}
+		}
+	}`)))
+
+	connections, err := Connections()
+	require.NoError(t, err)
+	require.Len(t, connections, 3)
+	assert.Equal(t, "api", connections[0].Name)
+
+	t.Run("ec2 fwd", func(t *testing.T) {
+		conn, err := FindConnection("PROD-DB")
+		require.NoError(t, err)
+
+		args, err := conn.Args()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"ec2", "fwd", "--profile", "prod", "--region", "eu-central-1", "--target", "bastion-a",
+			"--forward=5432:db:5432", "--forward=6379:redis:6379", "--keepalive=1m",
+		}, args)
+	})
+
+	t.Run("eks exec", func(t *testing.T) {
+		conn, err := FindConnection("api")
+		require.NoError(t, err)
+
+		args, err := conn.Args()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"eks", "exec", "--pod", "api", "--cluster=staging"}, args)
+	})
+
+	t.Run("unknown kind", func(t *testing.T) {
+		conn, err := FindConnection("broken")
+		require.NoError(t, err)
+
+		_, err = conn.Args()
+		assert.Error(t, err)
+	})
+
+	t.Run("not found", func(t *testing.T) {
+		_, err := FindConnection("nope")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/hupe1980/gotoaws/cmd/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type connectOptions struct {
+	list bool
+}
+
+func newConnectCmd() *cobra.Command {
+	opts := &connectOptions{}
+	cmd := &cobra.Command{
+		Use:   "connect [name]",
+		Short: "Run a named connection from the config file",
+		Long: `Run a named connection from the connections section of the config file:
+
+{
+  "connections": {
+    "prod-db": {
+      "kind": "ec2-fwd",
+      "profile": "prod",
+      "region": "eu-central-1",
+      "target": "bastion-a",
+      "parameters": {"forward": ["5432:xxx.rds.amazonaws.com:5432"]}
+    }
+  }
+}
+
+Supported kinds are ec2-session, ec2-ssh, ec2-fwd, ecs-exec, eks-exec, eks-fwd and eks-logs.
+The target is the instance (ec2), task (ecs) or pod (eks) and parameters are passed as flags to the command.`,
+		Example: `gotoaws connect --list
+gotoaws connect prod-db`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.MaximumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, _ string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+
+			// The config file was read before the flags of the completion request were parsed
+			if f := cmd.Flag("config"); f != nil && f.Changed {
+				viper.SetConfigFile(f.Value.String())
+				_ = viper.ReadInConfig()
+			}
+
+			connections, err := config.Connections()
+			if err != nil {
+				return nil, cobra.ShellCompDirectiveError
+			}
+
+			names := make([]string, 0, len(connections))
+			for _, c := range connections {
+				names = append(names, fmt.Sprintf("%s\t%s %s", c.Name, c.Kind, c.Target))
+			}
+
+			return names, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.list {
+				return listConnections(cmd)
+			}
+
+			if len(args) == 0 {
+				return errors.New("connection name is required")
+			}
+
+			conn, err := config.FindConnection(args[0])
+			if err != nil {
+				return err
+			}
+
+			connArgs, err := conn.Args()
+			if err != nil {
+				return err
+			}
+
+			sub, flags, err := cmd.Root().Find(connArgs)
+			if err != nil {
+				return err
+			}
+
+			if err := sub.ParseFlags(flags); err != nil {
+				return fmt.Errorf("connection %s: %w", conn.Name, err)
+			}
+
+			if err := sub.ValidateArgs(sub.Flags().Args()); err != nil {
+				return fmt.Errorf("connection %s: %w", conn.Name, err)
+			}
+
+			return sub.RunE(sub, sub.Flags().Args())
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.list, "list", "", false, "list the configured connections")
+
+	return cmd
+}
+
+func listConnections(cmd *cobra.Command) error {
+	connections, err := config.Connections()
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tKIND\tPROFILE\tREGION\tTARGET")
+
+	for _, c := range connections {
+		fmt.Fprintln(w, strings.Join([]string{c.Name, string(c.Kind), c.Profile, c.Region, c.Target}, "\t"))
+	}
+
+	return w.Flush()
+}
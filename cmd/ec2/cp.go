@@ -0,0 +1,148 @@
+package ec2
+
+import (
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/ec2"
+	"github.com/spf13/cobra"
+)
+
+type cpOptions struct {
+	sshAuthOptions
+	target    string
+	last      bool
+	receiving bool
+	recursive bool
+	resume    bool
+	preserve  bool
+	dryRun    bool
+}
+
+func newCPCmd() *cobra.Command {
+	opts := &cpOptions{}
+	cmd := &cobra.Command{
+		Use:   "cp [source(s)] [target]",
+		Short: "Copy files and directories over SFTP",
+		Example: `gotoaws ec2 cp -t myserver -r ./dist /opt/app/
+gotoaws ec2 cp -t myserver -R '/var/log/app/*.log' ./logs/
+gotoaws ec2 cp -t myserver --resume --preserve backup.tar.gz /data/
+gotoaws ec2 cp -t myserver -r --dry-run ./dist /opt/app/`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.MinimumNArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			if err := opts.validate(); err != nil {
+				return err
+			}
+
+			cfg, err := internal.NewConfigFromFlags()
+			if err != nil {
+				return err
+			}
+
+			inst, cfg, err := findInstance(cfg, opts.target, opts.last)
+			if err != nil {
+				return err
+			}
+
+			session, sshInput, closeSession, err := openSSHSession(cfg, inst, &opts.sshAuthOptions)
+			if err != nil {
+				return err
+			}
+			defer closeSession()
+
+			mode := ec2.SCPModeSending
+			if opts.receiving {
+				mode = ec2.SCPModeReceiving
+			}
+
+			var (
+				current *ec2.CopyFile
+				bar     *internal.ProgressBar
+			)
+
+			pos := len(args) - 1
+
+			files, err := session.Copy(&ec2.CopyInput{
+				SSHInput:  *sshInput,
+				Sources:   args[:pos],
+				Target:    args[pos],
+				Mode:      mode,
+				Recursive: opts.recursive,
+				Resume:    opts.resume,
+				Preserve:  opts.preserve,
+				DryRun:    opts.dryRun,
+				Progress: func(file *ec2.CopyFile, written int64) {
+					if file != current {
+						bar.Finish()
+
+						current = file
+						bar = internal.NewProgressBar(file.Source, file.Size, file.Offset)
+					}
+
+					bar.Set(written)
+				},
+			})
+			if err != nil {
+				bar.Stop()
+				return err
+			}
+
+			bar.Finish()
+
+			if opts.dryRun {
+				return printCopyPlan(files)
+			}
+
+			var count, size int64
+
+			for _, f := range files {
+				if !f.Dir {
+					count++
+					size += f.Size - f.Offset
+				}
+			}
+
+			internal.PrintInfof("Copied %d files (%s)", count, internal.FormatBytes(size))
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.receiving, "recv", "R", false, "receive files from target")
+	cmd.Flags().BoolVarP(&opts.recursive, "recursive", "r", false, "copy directories recursively")
+	cmd.Flags().BoolVarP(&opts.resume, "resume", "", false, "continue partial files that are smaller than the source")
+	cmd.Flags().BoolVarP(&opts.preserve, "preserve", "", false, "preserve the mode and modification time")
+	cmd.Flags().BoolVarP(&opts.dryRun, "dry-run", "", false, "list the files without copying")
+	cmd.Flags().StringVarP(&opts.target, "target", "t", "", "name|ID|IP|DNS of the instance")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "reconnect to the previous instance of the profile and region")
+	cmd.MarkFlagsMutuallyExclusive("target", "last")
+	cmd.Flags().StringVarP(&opts.port, "port", "p", "22", "SSH port to us")
+	cmd.Flags().StringVarP(&opts.user, "user", "l", "ec2-user", "SSH user to us")
+	cmd.Flags().StringVarP(&opts.identity, "identity", "i", "", "file from which the identity (private key) for public key authentication is read, in addition to the keys of the ssh agent")
+	cmd.Flags().StringVarP(&opts.knownHosts, "known-hosts", "", "", "known_hosts file, host keys are stored by instance ID (default \"~/.ssh/known_hosts\")")
+	cmd.Flags().BoolVarP(&opts.strictHostKeyChecking, "strict-host-key-checking", "", false, "reject unknown host keys instead of adding them to the known_hosts file")
+	cmd.Flags().BoolVarP(&opts.instanceConnect, "instance-connect", "", false, "push an ephemeral key with ec2 instance connect instead of --identity")
+	cmd.Flags().BoolVarP(&opts.agent, "agent", "", false, "add the ephemeral key to the ssh agent while it is valid")
+	cmd.MarkFlagsMutuallyExclusive("identity", "instance-connect")
+
+	return cmd
+}
+
+func printCopyPlan(files []ec2.CopyFile) error {
+	table := &internal.Table{Header: []string{"SOURCE", "TARGET", "SIZE", "RESUME"}}
+
+	for _, f := range files {
+		size, resume := internal.FormatBytes(f.Size), ""
+		if f.Dir {
+			size = "dir"
+		}
+
+		if f.Offset > 0 {
+			resume = internal.FormatBytes(f.Offset)
+		}
+
+		table.AddRow(f.Source, f.Target, size, resume)
+	}
+
+	return internal.PrintOutput(files, table)
+}
@@ -4,10 +4,13 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/internal/history"
+	"github.com/hupe1980/gotoaws/internal/picker"
 	"github.com/hupe1980/gotoaws/pkg/config"
 	"github.com/hupe1980/gotoaws/pkg/ec2"
-	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func NewEC2Cmd() *cobra.Command {
@@ -18,66 +21,193 @@ func NewEC2Cmd() *cobra.Command {
 	}
 
 	cmd.AddCommand(
+		newListCmd(),
 		newRunCmd(),
+		newCPCmd(),
 		newFwdCmd(),
+		newProxyCmd(),
 		newSCPCmd(),
 		newSSHCmd(),
+		newSSHConfigCmd(),
 		newSessionCmd(),
 	)
 
+	cmd.PersistentFlags().StringSlice("profiles", nil, "search the instances of these profiles (comma separated)")
+	cmd.PersistentFlags().StringSlice("accounts", nil, "search the instances of these accounts of the config file, or all (comma separated)")
+	cmd.PersistentFlags().Bool("all-regions", false, "search the instances of all enabled regions")
+
+	for _, name := range []string{"profiles", "accounts", "all-regions"} {
+		err := viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name))
+		cobra.CheckErr(err)
+	}
+
 	return cmd
 }
 
-func findInstance(cfg *config.Config, identifier string) (*ec2.Instance, error) {
-	finder := ec2.NewInstanceFinder(cfg)
-	if identifier != "" {
-		instances, err := finder.FindByIdentifier(identifier)
+// findInstance resolves the instance and records it in the history. With last the
+// previous instance of the profile and region is used. The returned config belongs
+// to the account and region of the instance.
+func findInstance(cfg *config.Config, identifier string, last bool) (*ec2.Instance, *config.Config, error) {
+	if last {
+		inst, err := findLastInstance(cfg)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		if len(instances) > 1 {
-			return chooseInstance(instances)
+		recordInstance(cfg, inst)
+
+		return inst, cfg, nil
+	}
+
+	scope, err := newScope(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return findInstanceInScope(scope, identifier)
+}
+
+func findInstanceInScope(scope []*config.Config, identifier string) (*ec2.Instance, *config.Config, error) {
+	inst, err := lookupInstance(scope, identifier)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg := scopeConfig(scope, inst)
+
+	recordInstance(cfg, inst)
+
+	return inst, cfg, nil
+}
+
+func lookupInstance(scope []*config.Config, identifier string) (*ec2.Instance, error) {
+	instances, err := findInScope(scope, func(finder ec2.InstanceFinder) ([]ec2.Instance, error) {
+		if identifier != "" {
+			return finder.FindByIdentifier(identifier)
 		}
 
+		return finder.Find()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if identifier != "" && len(instances) == 1 {
 		return &instances[0], nil
 	}
 
-	instances, err := finder.Find()
+	return chooseInstance(instances)
+}
+
+func findLastInstance(cfg *config.Config) (*ec2.Instance, error) {
+	entry, err := history.Last(history.KindEC2Instance, cfg.Profile, cfg.Region)
 	if err != nil {
 		return nil, err
 	}
 
-	return chooseInstance(instances)
+	instances, err := ec2.NewInstanceFinder(cfg).FindByIdentifier(entry.InstanceID)
+	if err != nil {
+		return nil, fmt.Errorf("previous instance %s is no longer available: %w", entry.Target(), err)
+	}
+
+	if err := connectable(&instances[0]); err != nil {
+		return nil, fmt.Errorf("previous instance %s is no longer available: %w", entry.Target(), err)
+	}
+
+	internal.PrintInfof("Reconnecting to %s", entry.Target())
+
+	return &instances[0], nil
 }
 
-func chooseInstance(instances []ec2.Instance) (*ec2.Instance, error) {
-	templates := &promptui.SelectTemplates{
-		Active:   fmt.Sprintf(`%s {{ .Name | cyan | bold }} ({{ .ID }})`, promptui.IconSelect),
-		Inactive: `   {{ .Name | cyan }} ({{ .ID }})`,
-		Selected: fmt.Sprintf(`%s {{ "Instance" }}: {{ .Name | cyan }} ({{ .ID }})`, promptui.IconGood),
+// connectable tells why no session can be started on the instance. Managed
+// instances outside of ec2 have no state.
+func connectable(inst *ec2.Instance) error {
+	if inst.State != "" && inst.State != "running" {
+		return fmt.Errorf("instance %s is %s", inst.ID, inst.State)
 	}
 
-	searcher := func(input string, index int) bool {
-		instance := instances[index]
-		name := strings.Replace(strings.ToLower(instance.Name), " ", "", -1)
-		input = strings.Replace(strings.ToLower(input), " ", "", -1)
+	if inst.PingStatus != "Online" {
+		status := inst.PingStatus
+		if status == "" {
+			status = "not registered"
+		}
+
+		return fmt.Errorf("the ssm agent of instance %s is %s", inst.ID, status)
+	}
+
+	return nil
+}
+
+func recordInstance(cfg *config.Config, inst *ec2.Instance) {
+	// The history is a convenience, failing to write it must not fail the command
+	_ = history.Add(history.Entry{
+		Kind:       history.KindEC2Instance,
+		Profile:    cfg.Profile,
+		Region:     cfg.Region,
+		Account:    cfg.Account,
+		InstanceID: inst.ID,
+		Name:       inst.Name,
+	})
+}
+
+func chooseInstance(instances []ec2.Instance) (*ec2.Instance, error) {
+	items := make([]picker.Item, 0, len(instances))
+	multi := spansScopes(instances)
+
+	for _, inst := range instances {
+		label := inst.Name
+		if label == "" {
+			label = inst.ID
+		}
 
-		return strings.Contains(name, input)
+		fields := []string{inst.ID, inst.PrivateIP, inst.PublicIP, inst.InstanceType, inst.AvailabilityZone, inst.PlatformName, inst.Account, inst.Region}
+		for k, v := range inst.Tags {
+			fields = append(fields, fmt.Sprintf("%s=%s", k, v))
+		}
+
+		description := fmt.Sprintf("(%s)", inst.ID)
+		if multi {
+			description = fmt.Sprintf("(%s, %s %s)", inst.ID, inst.Account, inst.Region)
+		}
+
+		items = append(items, picker.Item{
+			Key:         inst.ID,
+			Label:       label,
+			Description: description,
+			Fields:      fields,
+			Details: []picker.Detail{
+				{Name: "Account", Value: strings.TrimSpace(fmt.Sprintf("%s %s", inst.Account, inst.Region))},
+				{Name: "Type", Value: inst.InstanceType},
+				{Name: "AZ", Value: inst.AvailabilityZone},
+				{Name: "Private IP", Value: inst.PrivateIP},
+				{Name: "Public IP", Value: inst.PublicIP},
+				{Name: "Platform", Value: strings.TrimSpace(fmt.Sprintf("%s %s %s", inst.Platform, inst.PlatformName, inst.PlatformVersion))},
+				{Name: "Agent", Value: strings.TrimSpace(fmt.Sprintf("%s %s", inst.AgentVersion, inst.PingStatus))},
+			},
+		})
 	}
 
-	prompt := promptui.Select{
-		Label:     "Choose an instance",
-		Items:     instances,
-		Templates: templates,
-		Size:      15,
-		Searcher:  searcher,
+	p := &picker.Picker{
+		Label:    "Choose an instance",
+		Kind:     "ec2-instance",
+		Selected: "Instance",
 	}
 
-	i, _, err := prompt.Run()
+	i, err := p.Pick(items)
 	if err != nil {
 		return nil, err
 	}
 
 	return &instances[i], nil
 }
+
+// spansScopes reports whether the instances belong to more than one account or region.
+func spansScopes(instances []ec2.Instance) bool {
+	for _, inst := range instances {
+		if inst.Account != instances[0].Account || inst.Region != instances[0].Region {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,17 @@
+package ec2
+
+import (
+	"testing"
+
+	"github.com/hupe1980/gotoaws/pkg/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConnectable(t *testing.T) {
+	assert.NoError(t, connectable(&ec2.Instance{ID: "i-1", State: "running", PingStatus: "Online"}))
+	assert.NoError(t, connectable(&ec2.Instance{ID: "mi-1", PingStatus: "Online"}))
+
+	assert.EqualError(t, connectable(&ec2.Instance{ID: "i-1", State: "terminated"}), "instance i-1 is terminated")
+	assert.EqualError(t, connectable(&ec2.Instance{ID: "i-1", State: "running", PingStatus: "ConnectionLost"}), "the ssm agent of instance i-1 is ConnectionLost")
+	assert.EqualError(t, connectable(&ec2.Instance{ID: "i-1", State: "running"}), "the ssm agent of instance i-1 is not registered")
+}
@@ -1,17 +1,29 @@
 package ec2
 
 import (
-	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
 	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/config"
 	"github.com/hupe1980/gotoaws/pkg/ec2"
 	"github.com/spf13/cobra"
 )
 
 type fwdOptions struct {
 	target           string
+	last             bool
 	remotePortNumber string
 	remoteHost       string
 	localPortNumber  string
+	specs            []string
+	reconnect        bool
+	keepAlive        time.Duration
 }
 
 func newFwdCmd() *cobra.Command {
@@ -19,71 +31,131 @@ func newFwdCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "fwd",
 		Short: "Port forwarding",
-		Example: `gotoaws ec2 fwd run -t myserver -l 8080 -r 8080
-gotoaws ec2 fwd run -t myserver -l 5432 -r 5432 -H xxx.rds.amazonaws.com`,
+		Example: `gotoaws ec2 fwd -t myserver -l 8080 -r 8080
+gotoaws ec2 fwd -t myserver -l 5432 -r 5432 -H xxx.rds.amazonaws.com
+gotoaws ec2 fwd -t bastion -L 5432:xxx.rds.amazonaws.com:5432 -L 6379:xxx.cache.amazonaws.com:6379 -L 8080:internal.example.com:80
+gotoaws ec2 fwd -t bastion -L 5432:xxx.rds.amazonaws.com:5432 --keepalive 1m`,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(_ *cobra.Command, _ []string) error {
-			cfg, err := internal.NewConfigFromFlags()
+			specs, err := internal.ParseForwardSpecs(opts.specs, opts.localPortNumber, opts.remoteHost, opts.remotePortNumber)
 			if err != nil {
 				return err
 			}
 
-			inst, err := findInstance(cfg, opts.target)
+			cfg, err := internal.NewConfigFromFlags()
 			if err != nil {
 				return err
 			}
 
-			docName := "AWS-StartPortForwardingSession"
-			input := &ssm.StartSessionInput{
-				DocumentName: &docName,
-				Parameters: map[string][]string{
-					"portNumber":      {opts.remotePortNumber},
-					"localPortNumber": {opts.localPortNumber},
-				},
-				Target: &inst.ID,
+			inst, cfg, err := findInstance(cfg, opts.target, opts.last)
+			if err != nil {
+				return err
 			}
 
-			if opts.remoteHost != "" {
-				docName = "AWS-StartPortForwardingSessionToRemoteHost"
-				input = &ssm.StartSessionInput{
-					DocumentName: &docName,
-					Parameters: map[string][]string{
-						"portNumber":      {opts.remotePortNumber},
-						"localPortNumber": {opts.localPortNumber},
-						"host":            {opts.remoteHost},
-					},
-					Target: &inst.ID,
+			forwarders := make([]*ec2.Forwarder, 0, len(specs))
+
+			for _, spec := range specs {
+				session, err := ec2.NewSession(cfg, spec.StartSessionInput(inst.ID))
+				if err != nil {
+					return err
 				}
-			}
+				defer session.Close()
 
-			session, err := ec2.NewSession(cfg, input)
-			if err != nil {
-				return err
+				forwarders = append(forwarders, opts.newForwarder(cfg, inst, session, spec))
 			}
-			defer session.Close()
 
-			if err := session.RunPlugin(); err != nil {
-				return err
-			}
-			return nil
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-sigs
+				cancel()
+			}()
+
+			return internal.RunForwarders(ctx, specs, forwarders)
 		},
 	}
 
 	cmd.Flags().StringVarP(&opts.target, "target", "t", "", "name|ID|IP|DNS of the instance")
-	cmd.Flags().StringVarP(&opts.remotePortNumber, "remote", "r", "", "remote port to forward to (required)")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "reconnect to the previous instance of the profile and region")
+	cmd.MarkFlagsMutuallyExclusive("target", "last")
+	cmd.Flags().StringVarP(&opts.remotePortNumber, "remote", "r", "", "remote port to forward to")
+	cmd.Flags().StringVarP(&opts.remoteHost, "host", "H", "", "remote host to forward to")
+	cmd.Flags().StringVarP(&opts.localPortNumber, "local", "l", "", "local port to use")
+	cmd.Flags().StringArrayVarP(&opts.specs, "forward", "L", nil, "local:host:remote or local:remote port forwarding (repeatable)")
+	cmd.Flags().BoolVarP(&opts.reconnect, "reconnect", "", true, "restart dropped sessions")
+	cmd.Flags().DurationVarP(&opts.keepAlive, "keepalive", "", 0, "interval of keepalive messages to prevent idle timeouts (e.g. 1m)")
+
+	return cmd
+}
 
-	if err := cmd.MarkFlagRequired("remote"); err != nil {
-		panic(err)
+func (opts *fwdOptions) newForwarder(cfg *config.Config, inst *ec2.Instance, session ec2.Session, spec *ec2.ForwardSpec) *ec2.Forwarder {
+	if !opts.reconnect {
+		return internal.NewForwarder(session, spec, opts.keepAlive, nil)
 	}
 
-	cmd.Flags().StringVarP(&opts.remoteHost, "host", "H", "", "remote host to forward to")
+	return internal.NewForwarder(session, spec, opts.keepAlive, func() (ec2.Session, error) {
+		id, err := opts.resolveInstance(cfg, inst)
+		if err != nil {
+			return nil, err
+		}
+
+		session, err := ec2.NewSession(cfg, spec.StartSessionInput(id))
+		if err != nil {
+			return nil, err
+		}
+
+		internal.PrintInfof("[%s] Reconnected to %s", spec, id)
+
+		return session, nil
+	})
+}
+
+// resolveInstance looks up the target again. The previous instance is preferred while
+// it is running, otherwise a single running match (e.g. a replaced instance with the
+// same name) is used.
+func (opts *fwdOptions) resolveInstance(cfg *config.Config, prev *ec2.Instance) (string, error) {
+	identifier := opts.target
+	if identifier == "" {
+		identifier = prev.ID
+	}
 
-	cmd.Flags().StringVarP(&opts.localPortNumber, "local", "l", "", "local port to use (required)")
+	instances, err := ec2.NewInstanceFinder(cfg).FindByIdentifier(identifier)
+	if err != nil {
+		if errors.Is(err, ec2.ErrNoInstances) {
+			return "", fmt.Errorf("%w: %s", ec2.ErrTargetUnavailable, err)
+		}
 
-	if err := cmd.MarkFlagRequired("local"); err != nil {
-		panic(err)
+		return "", err
 	}
 
-	return cmd
+	return selectRunningInstance(identifier, prev.ID, instances)
+}
+
+func selectRunningInstance(identifier, prevID string, instances []ec2.Instance) (string, error) {
+	running := make([]ec2.Instance, 0, len(instances))
+
+	for _, inst := range instances {
+		if inst.State != "running" {
+			continue
+		}
+
+		if inst.ID == prevID {
+			return inst.ID, nil
+		}
+
+		running = append(running, inst)
+	}
+
+	switch len(running) {
+	case 0:
+		return "", fmt.Errorf("%w: no running instance matches %s", ec2.ErrTargetUnavailable, identifier)
+	case 1:
+		return running[0].ID, nil
+	default:
+		return "", fmt.Errorf("%w: target %s matches %d running instances", ec2.ErrTargetUnavailable, identifier, len(running))
+	}
 }
@@ -0,0 +1,43 @@
+package ec2
+
+import (
+	"testing"
+
+	"github.com/hupe1980/gotoaws/pkg/ec2"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectRunningInstance(t *testing.T) {
+	t.Run("previous instance", func(t *testing.T) {
+		id, err := selectRunningInstance("web", "i-1", []ec2.Instance{
+			{ID: "i-2", State: "running"},
+			{ID: "i-1", State: "running"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "i-1", id)
+	})
+
+	t.Run("replaced instance", func(t *testing.T) {
+		id, err := selectRunningInstance("web", "i-1", []ec2.Instance{
+			{ID: "i-1", State: "terminated"},
+			{ID: "i-2", State: "running"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "i-2", id)
+	})
+
+	t.Run("no running instance", func(t *testing.T) {
+		_, err := selectRunningInstance("i-1", "i-1", []ec2.Instance{{ID: "i-1", State: "stopped"}})
+		assert.ErrorIs(t, err, ec2.ErrTargetUnavailable)
+	})
+
+	t.Run("ambiguous", func(t *testing.T) {
+		_, err := selectRunningInstance("web", "i-1", []ec2.Instance{
+			{ID: "i-1", State: "terminated"},
+			{ID: "i-2", State: "running"},
+			{ID: "i-3", State: "running"},
+		})
+		assert.ErrorIs(t, err, ec2.ErrTargetUnavailable)
+		assert.EqualError(t, err, "target is no longer available: target web matches 2 running instances")
+	})
+}
@@ -0,0 +1,55 @@
+package ec2
+
+import (
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/ec2"
+	"github.com/spf13/cobra"
+)
+
+func newListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List ssm managed instances",
+		Example: `gotoaws ec2 list
+gotoaws ec2 list --output json
+gotoaws ec2 list --all-regions --profiles dev,prod`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.NewConfigFromFlags()
+			if err != nil {
+				return err
+			}
+
+			scope, err := newScope(cfg)
+			if err != nil {
+				return err
+			}
+
+			instances, err := findInScope(scope, func(finder ec2.InstanceFinder) ([]ec2.Instance, error) {
+				return finder.Find()
+			})
+			if err != nil {
+				return err
+			}
+
+			table := &internal.Table{Header: []string{"NAME", "ID", "TYPE", "AZ", "PRIVATE IP", "PUBLIC IP", "PLATFORM", "PING STATUS", "AGENT"}}
+			if len(scope) > 1 {
+				table.Header = append([]string{"ACCOUNT", "REGION"}, table.Header...)
+			}
+
+			for _, inst := range instances {
+				row := []interface{}{inst.Name, inst.ID, inst.InstanceType, inst.AvailabilityZone, inst.PrivateIP, inst.PublicIP, inst.Platform, inst.PingStatus, inst.AgentVersion}
+				if len(scope) > 1 {
+					row = append([]interface{}{inst.Account, inst.Region}, row...)
+				}
+
+				table.AddRow(row...)
+			}
+
+			return internal.PrintOutput(instances, table)
+		},
+	}
+
+	return cmd
+}
@@ -0,0 +1,87 @@
+package ec2
+
+import (
+	"os"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/ec2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type proxyOptions struct {
+	target          string
+	port            string
+	user            string
+	instanceConnect bool
+}
+
+func newProxyCmd() *cobra.Command {
+	opts := &proxyOptions{}
+	cmd := &cobra.Command{
+		Use:   "proxy",
+		Short: "Bridge stdin and stdout to ssh of an instance, for ProxyCommand",
+		Example: `ssh -o ProxyCommand='gotoaws ec2 proxy --target %h --port %p' ec2-user@i-0123456789abcdef0
+ssh -o ProxyCommand='gotoaws ec2 proxy --target %h --port %p --instance-connect --user %r' ec2-user@myserver`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			// stdout belongs to ssh
+			viper.Set("silent", true)
+
+			cfg, err := internal.NewConfigFromFlags()
+			if err != nil {
+				return err
+			}
+
+			inst, cfg, err := findInstance(cfg, opts.target, false)
+			if err != nil {
+				return err
+			}
+
+			if opts.instanceConnect {
+				// The ssh client finds the ephemeral key in the agent
+				key, err := ec2.PushEphemeralKey(cfg, &ec2.PushKeyInput{
+					InstanceID:       inst.ID,
+					AvailabilityZone: inst.AvailabilityZone,
+					User:             opts.user,
+					UseAgent:         true,
+				})
+				if err != nil {
+					return err
+				}
+				defer key.Close()
+			}
+
+			docName := "AWS-StartSSHSession"
+			session, err := ec2.NewSession(cfg, &ssm.StartSessionInput{
+				DocumentName: &docName,
+				Parameters:   map[string][]string{"portNumber": {opts.port}},
+				Target:       &inst.ID,
+			})
+			if err != nil {
+				return err
+			}
+			defer session.Close()
+
+			dc, err := session.OpenDataChannel()
+			if err != nil {
+				return err
+			}
+
+			return ec2.Proxy(dc, os.Stdin, os.Stdout)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.target, "target", "t", "", "name|ID|IP|DNS of the instance (required)")
+	cmd.Flags().StringVarP(&opts.port, "port", "p", "22", "SSH port to us")
+	cmd.Flags().StringVarP(&opts.user, "user", "l", "ec2-user", "SSH user of the ephemeral key")
+	cmd.Flags().BoolVarP(&opts.instanceConnect, "instance-connect", "", false, "push an ephemeral key with ec2 instance connect and add it to the ssh agent")
+
+	if err := cmd.MarkFlagRequired("target"); err != nil {
+		panic(err)
+	}
+
+	return cmd
+}
@@ -4,14 +4,22 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/config"
 	"github.com/hupe1980/gotoaws/pkg/ec2"
 	"github.com/spf13/cobra"
 )
 
 type runOptions struct {
-	target string
+	targets        []string
+	tags           map[string]string
+	all            bool
+	last           bool
+	document       string
+	maxConcurrency string
+	maxErrors      string
 }
 
 func newRunCmd() *cobra.Command {
@@ -20,46 +28,285 @@ func newRunCmd() *cobra.Command {
 		Use:   "run [flags] -- COMMAND [args...]",
 		Short: "Run commands",
 		Example: `gotoaws ec2 run -- date
-gotoaws ec2 run -t myserver -- date`,
+gotoaws ec2 run -t myserver -- date
+gotoaws ec2 run --last -- date
+gotoaws ec2 run -t web-1 -t web-2 -- uptime
+gotoaws ec2 run --tags Env=prod --tags Role=web --max-concurrency 25% --max-errors 1 -- systemctl restart nginx
+gotoaws ec2 run --all -- uname -a`,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			command := []string{}
+			if i := cmd.ArgsLenAtDash(); i != -1 {
+				command = args[i:]
+			}
+
+			if len(command) == 0 {
+				return errors.New("command is missing")
+			}
+
 			cfg, err := internal.NewConfigFromFlags()
 			if err != nil {
 				return err
 			}
 
-			inst, err := findInstance(cfg, opts.target)
-			if err != nil {
-				return err
+			input := &ec2.RunCommandInput{
+				Command:        command,
+				Tags:           opts.tags,
+				All:            opts.all,
+				DocumentName:   opts.document,
+				MaxConcurrency: opts.maxConcurrency,
+				MaxErrors:      opts.maxErrors,
 			}
 
-			command := []string{}
-			if i := cmd.ArgsLenAtDash(); i != -1 {
-				command = args[i:]
+			var batches []commandBatch
+
+			if opts.all || len(opts.tags) > 0 {
+				scope, err := newScope(cfg)
+				if err != nil {
+					return err
+				}
+
+				for _, c := range scope {
+					batches = append(batches, commandBatch{cfg: c, input: input})
+				}
+			} else {
+				instances, configs, err := findInstances(cfg, opts.targets, opts.last)
+				if err != nil {
+					return err
+				}
+
+				if input.DocumentName == "" && allWindows(instances) {
+					input.DocumentName = "AWS-RunPowerShellScript"
+				}
+
+				batches = newBatches(instances, configs, input)
+
+				if len(instances) == 1 && !internal.IsStructuredOutput() {
+					return runSingle(batches[0].cfg, batches[0].input)
+				}
 			}
 
-			if len(command) == 0 {
-				return errors.New("command is missing")
+			var printFn func(res ec2.InvocationResult)
+			if !internal.IsStructuredOutput() {
+				printFn = printResult
 			}
 
-			runner, err := ec2.NewCommandRunner(cfg, inst, command)
+			results, err := runBatches(batches, printFn)
 			if err != nil {
 				return err
 			}
 
-			res, err := runner.Result()
-			if err != nil {
+			if err := printResults(results); err != nil {
 				return err
 			}
 
-			fmt.Fprintln(os.Stdout, res)
+			failed := 0
+
+			for _, res := range results {
+				if res.Status != ec2.InvocationStatusSuccess {
+					failed++
+				}
+			}
+
+			if failed > 0 {
+				return fmt.Errorf("command failed on %d of %d instances", failed, len(results))
+			}
 
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVarP(&opts.target, "target", "t", "", "name|ID|IP|DNS of the instance")
+	cmd.Flags().StringArrayVarP(&opts.targets, "target", "t", nil, "name|ID|IP|DNS of the instance (repeatable)")
+	cmd.Flags().StringToStringVarP(&opts.tags, "tags", "", nil, "run on all instances with the tag Key=Value (repeatable, all must match)")
+	cmd.Flags().BoolVarP(&opts.all, "all", "", false, "run on all managed instances")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "run on the previous instance of the profile and region")
+	cmd.Flags().StringVarP(&opts.document, "document", "", "", "ssm document to run (default \"AWS-RunShellScript\")")
+	cmd.Flags().StringVarP(&opts.maxConcurrency, "max-concurrency", "", "", "number or percentage of instances running the command at the same time")
+	cmd.Flags().StringVarP(&opts.maxErrors, "max-errors", "", "", "number or percentage of errors after which the command is not sent to further instances")
+
+	cmd.MarkFlagsMutuallyExclusive("target", "tags", "all", "last")
 
 	return cmd
 }
+
+// findInstances resolves all targets. Without targets the previous instance is used
+// with last, otherwise an instance is chosen interactively. The configs belong to the
+// account and region of each instance.
+func findInstances(cfg *config.Config, targets []string, last bool) ([]ec2.Instance, []*config.Config, error) {
+	if len(targets) == 0 {
+		inst, instCfg, err := findInstance(cfg, "", last)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return []ec2.Instance{*inst}, []*config.Config{instCfg}, nil
+	}
+
+	scope, err := newScope(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	seen := make(map[string]bool)
+
+	var (
+		instances []ec2.Instance
+		configs   []*config.Config
+	)
+
+	for _, target := range targets {
+		inst, instCfg, err := findInstanceInScope(scope, target)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if !seen[inst.ID] {
+			seen[inst.ID] = true
+			instances = append(instances, *inst)
+			configs = append(configs, instCfg)
+		}
+	}
+
+	return instances, configs, nil
+}
+
+// commandBatch is a command sent to one account and region.
+type commandBatch struct {
+	cfg   *config.Config
+	input *ec2.RunCommandInput
+}
+
+// newBatches groups the instances by account and region.
+func newBatches(instances []ec2.Instance, configs []*config.Config, input *ec2.RunCommandInput) []commandBatch {
+	var batches []commandBatch
+
+	index := make(map[*config.Config]int)
+
+	for i, inst := range instances {
+		j, ok := index[configs[i]]
+		if !ok {
+			batchInput := *input
+			batchInput.InstanceIDs = nil
+
+			j = len(batches)
+			index[configs[i]] = j
+			batches = append(batches, commandBatch{cfg: configs[i], input: &batchInput})
+		}
+
+		batches[j].input.InstanceIDs = append(batches[j].input.InstanceIDs, inst.ID)
+	}
+
+	return batches
+}
+
+// runBatches sends the batches concurrently and waits for all results.
+func runBatches(batches []commandBatch, printFn func(res ec2.InvocationResult)) ([]ec2.InvocationResult, error) {
+	inputs := make(map[*config.Config]*ec2.RunCommandInput, len(batches))
+	scope := make([]*config.Config, 0, len(batches))
+
+	for _, b := range batches {
+		inputs[b.cfg] = b.input
+		scope = append(scope, b.cfg)
+	}
+
+	var mu sync.Mutex
+
+	if printFn != nil {
+		fn := printFn
+		printFn = func(res ec2.InvocationResult) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			fn(res)
+		}
+	}
+
+	results := internal.Discover(scope, func(cfg *config.Config) ([]ec2.InvocationResult, error) {
+		runner, err := ec2.NewCommandRunner(cfg, inputs[cfg])
+		if err != nil {
+			return nil, err
+		}
+
+		if len(batches) > 1 {
+			internal.PrintInfof("Command %s sent (%s %s)", runner.CommandID(), cfg.Account, cfg.Region)
+		} else {
+			internal.PrintInfof("Command %s sent", runner.CommandID())
+		}
+
+		return runner.Results(printFn)
+	})
+
+	var all []ec2.InvocationResult
+
+	for _, r := range results {
+		if r.Err != nil {
+			return nil, r.Err
+		}
+
+		all = append(all, r.Items...)
+	}
+
+	return all, nil
+}
+
+func allWindows(instances []ec2.Instance) bool {
+	for _, inst := range instances {
+		if inst.Platform != "Windows" {
+			return false
+		}
+	}
+
+	return len(instances) > 0
+}
+
+// runSingle prints the plain output of a command on one instance.
+func runSingle(cfg *config.Config, input *ec2.RunCommandInput) error {
+	runner, err := ec2.NewCommandRunner(cfg, input)
+	if err != nil {
+		return err
+	}
+
+	results, err := runner.Results(nil)
+	if err != nil {
+		return err
+	}
+
+	if len(results) == 0 {
+		return errors.New("command did not run on the instance")
+	}
+
+	if results[0].Status != ec2.InvocationStatusSuccess {
+		return errors.New(results[0].Error)
+	}
+
+	fmt.Fprintln(os.Stdout, results[0].Output)
+
+	return nil
+}
+
+func printResult(res ec2.InvocationResult) {
+	if res.Status == ec2.InvocationStatusSuccess {
+		internal.PrintInfof("%s (%s): %s", res.InstanceID, res.InstanceName, res.Status)
+	} else {
+		internal.PrintErrorf("%s (%s): %s (%s)", res.InstanceID, res.InstanceName, res.Status, res.StatusDetails)
+	}
+
+	if res.Output != "" {
+		fmt.Fprintln(os.Stdout, res.Output)
+	}
+
+	if res.Error != "" && res.Error != res.StatusDetails {
+		fmt.Fprintln(os.Stderr, res.Error)
+	}
+}
+
+// printResults prints the results in the output format, the summary table for table and text.
+func printResults(results []ec2.InvocationResult) error {
+	table := &internal.Table{Header: []string{"INSTANCE", "NAME", "STATUS", "CODE", "DETAILS"}}
+	for _, res := range results {
+		table.AddRow(res.InstanceID, res.InstanceName, res.Status, res.ResponseCode, res.StatusDetails)
+	}
+
+	return internal.PrintOutput(results, table)
+}
@@ -0,0 +1,89 @@
+package ec2
+
+import (
+	"errors"
+
+	cmdconfig "github.com/hupe1980/gotoaws/cmd/config"
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/config"
+	"github.com/hupe1980/gotoaws/pkg/ec2"
+	"github.com/spf13/viper"
+)
+
+// newScope returns the accounts and regions selected by --profiles, --accounts and --all-regions.
+func newScope(cfg *config.Config) ([]*config.Config, error) {
+	input := &internal.ScopeInput{
+		Profiles:   viper.GetStringSlice("profiles"),
+		Region:     viper.GetString("region"),
+		AllRegions: viper.GetBool("all-regions"),
+	}
+
+	if names := viper.GetStringSlice("accounts"); len(names) > 0 {
+		accounts, err := cmdconfig.FindAccounts(names)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range accounts {
+			input.RoleARNs = append(input.RoleARNs, accounts[i].RoleARN())
+		}
+	}
+
+	scope, err := internal.NewScope(cfg, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.IsMulti() {
+		internal.PrintInfof("Searching %d accounts and regions", len(scope))
+	}
+
+	return scope, nil
+}
+
+// findInScope runs fn in every account and region. Failures are reported unless no instance was found at all.
+func findInScope(scope []*config.Config, fn func(finder ec2.InstanceFinder) ([]ec2.Instance, error)) ([]ec2.Instance, error) {
+	results := internal.Discover(scope, func(cfg *config.Config) ([]ec2.Instance, error) {
+		return fn(ec2.NewInstanceFinder(cfg))
+	})
+
+	var (
+		instances []ec2.Instance
+		failed    []error
+	)
+
+	for _, r := range results {
+		if r.Err != nil {
+			if !errors.Is(r.Err, ec2.ErrNoInstances) {
+				failed = append(failed, r.Err)
+
+				internal.PrintErrorf("%s (%s): %s", r.Config.Account, r.Config.Region, r.Err)
+			}
+
+			continue
+		}
+
+		instances = append(instances, r.Items...)
+	}
+
+	if len(instances) == 0 {
+		if len(failed) > 0 {
+			return nil, failed[0]
+		}
+
+		return nil, ec2.ErrNoInstances
+	}
+
+	return instances, nil
+}
+
+// scopeConfig returns the config of the account and region of the instance.
+func scopeConfig(scope []*config.Config, inst *ec2.Instance) *config.Config {
+	for _, cfg := range scope {
+		if cfg.Account == inst.Account && cfg.Region == inst.Region {
+			return cfg
+		}
+	}
+
+	return scope[0]
+}
@@ -1,51 +1,48 @@
 package ec2
 
 import (
-	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/hupe1980/gotoaws/internal"
 	"github.com/hupe1980/gotoaws/pkg/ec2"
 	"github.com/spf13/cobra"
 )
 
 type scpOptions struct {
+	sshAuthOptions
 	target    string
-	port      string
-	user      string
-	identity  string
+	last      bool
 	receiving bool
 }
 
 func newSCPCmd() *cobra.Command {
 	opts := &scpOptions{}
 	cmd := &cobra.Command{
-		Use:           "scp [source(s)] [target]",
-		Short:         "SCP over Session Manager",
-		Example:       "gotoaws ec2 scp file.txt /opt/ -t myserver -i key.pem",
+		Use:   "scp [source(s)] [target]",
+		Short: "SCP over Session Manager",
+		Example: `gotoaws ec2 scp file.txt /opt/ -t myserver -i key.pem
+gotoaws ec2 scp file.txt /opt/ -t myserver --instance-connect --agent`,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		Args:          cobra.MinimumNArgs(2),
 		RunE: func(_ *cobra.Command, args []string) error {
+			if err := opts.validate(); err != nil {
+				return err
+			}
+
 			cfg, err := internal.NewConfigFromFlags()
 			if err != nil {
 				return err
 			}
 
-			inst, err := findInstance(cfg, opts.target)
+			inst, cfg, err := findInstance(cfg, opts.target, opts.last)
 			if err != nil {
 				return err
 			}
 
-			docName := "AWS-StartSSHSession"
-			input := &ssm.StartSessionInput{
-				DocumentName: &docName,
-				Parameters:   map[string][]string{"portNumber": {opts.port}},
-				Target:       &inst.ID,
-			}
-			session, err := ec2.NewSession(cfg, input)
+			session, sshInput, closeSession, err := openSSHSession(cfg, inst, &opts.sshAuthOptions)
 			if err != nil {
 				return err
 			}
-			defer session.Close()
+			defer closeSession()
 
 			pos := len(args) - 1
 
@@ -55,12 +52,10 @@ func newSCPCmd() *cobra.Command {
 			}
 
 			if err := session.RunSCP(&ec2.RunSCPInput{
-				User:       opts.user,
-				InstanceID: inst.ID,
-				Identity:   opts.identity,
-				Sources:    args[:pos],
-				Target:     args[pos],
-				Mode:       mode,
+				SSHInput: *sshInput,
+				Sources:  args[:pos],
+				Target:   args[pos],
+				Mode:     mode,
 			}); err != nil {
 				return err
 			}
@@ -71,13 +66,16 @@ func newSCPCmd() *cobra.Command {
 
 	cmd.Flags().BoolVarP(&opts.receiving, "recv", "R", false, "receive files from target")
 	cmd.Flags().StringVarP(&opts.target, "target", "t", "", "name|ID|IP|DNS of the instance")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "reconnect to the previous instance of the profile and region")
+	cmd.MarkFlagsMutuallyExclusive("target", "last")
 	cmd.Flags().StringVarP(&opts.port, "port", "p", "22", "SSH port to us")
 	cmd.Flags().StringVarP(&opts.user, "user", "l", "ec2-user", "SCP user to us")
-	cmd.Flags().StringVarP(&opts.identity, "identity", "i", "", "file from which the identity (private key) for public key authentication is read (required)")
-
-	if err := cmd.MarkFlagRequired("identity"); err != nil {
-		panic(err)
-	}
+	cmd.Flags().StringVarP(&opts.identity, "identity", "i", "", "file from which the identity (private key) for public key authentication is read, in addition to the keys of the ssh agent")
+	cmd.Flags().StringVarP(&opts.knownHosts, "known-hosts", "", "", "known_hosts file, host keys are stored by instance ID (default \"~/.ssh/known_hosts\")")
+	cmd.Flags().BoolVarP(&opts.strictHostKeyChecking, "strict-host-key-checking", "", false, "reject unknown host keys instead of adding them to the known_hosts file")
+	cmd.Flags().BoolVarP(&opts.instanceConnect, "instance-connect", "", false, "push an ephemeral key with ec2 instance connect instead of --identity")
+	cmd.Flags().BoolVarP(&opts.agent, "agent", "", false, "add the ephemeral key to the ssh agent while it is valid")
+	cmd.MarkFlagsMutuallyExclusive("identity", "instance-connect")
 
 	return cmd
 }
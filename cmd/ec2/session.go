@@ -9,14 +9,19 @@ import (
 
 type sessionOptions struct {
 	target string
+	last   bool
+	record string
 }
 
 func newSessionCmd() *cobra.Command {
 	opts := &sessionOptions{}
 	cmd := &cobra.Command{
-		Use:           "session",
-		Short:         "Start a session",
-		Example:       "gotoaws ec2 session -t myserver",
+		Use:   "session",
+		Short: "Start a session",
+		Example: `gotoaws ec2 session -t myserver
+gotoaws ec2 session --last
+gotoaws ec2 session --all-regions --accounts all
+gotoaws ec2 session -t myserver --record session.cast`,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(_ *cobra.Command, _ []string) error {
@@ -25,7 +30,7 @@ func newSessionCmd() *cobra.Command {
 				return err
 			}
 
-			inst, err := findInstance(cfg, opts.target)
+			inst, cfg, err := findInstance(cfg, opts.target, opts.last)
 			if err != nil {
 				return err
 			}
@@ -37,7 +42,17 @@ func newSessionCmd() *cobra.Command {
 			}
 			defer session.Close()
 
-			if err := session.RunPlugin(); err != nil {
+			if opts.record != "" {
+				rec, err := internal.NewSessionRecorder(cfg, opts.record, inst.ID)
+				if err != nil {
+					return err
+				}
+				defer rec.Close()
+
+				return session.RecordShell(rec.Recorder)
+			}
+
+			if err := session.RunShell(); err != nil {
 				return err
 			}
 			return nil
@@ -45,6 +60,9 @@ func newSessionCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringVarP(&opts.target, "target", "t", "", "name|ID|IP|DNS of the instance")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "reconnect to the previous instance of the profile and region")
+	cmd.Flags().StringVarP(&opts.record, "record", "", "", "record the session to a file in asciicast v2 format")
+	cmd.MarkFlagsMutuallyExclusive("target", "last")
 
 	return cmd
 }
@@ -1,76 +1,89 @@
 package ec2
 
 import (
+	"errors"
 	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/hupe1980/gotoaws/internal"
 	"github.com/hupe1980/gotoaws/pkg/ec2"
 	"github.com/spf13/cobra"
+	"golang.org/x/crypto/ssh"
 )
 
 type sshOptions struct {
-	target   string
-	port     string
-	user     string
-	identity string
-	fwd      string
+	sshAuthOptions
+	target       string
+	last         bool
+	fwd          []string
+	noCommand    bool
+	forwardAgent bool
 }
 
 func newSSHCmd() *cobra.Command {
 	opts := &sshOptions{}
 	cmd := &cobra.Command{
-		Use:           "ssh [command]",
-		Short:         "SSH over Session Manager",
-		Example:       "gotoaws ssh -t myserver -i key.pem",
+		Use:   "ssh [command]",
+		Short: "SSH over Session Manager",
+		Example: `gotoaws ec2 ssh -t myserver -i key.pem
+gotoaws ec2 ssh -t myserver --instance-connect uname -a
+gotoaws ec2 ssh -t myserver -N -L 5432:db.internal:5432 -L 8080:localhost:80`,
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		RunE: func(_ *cobra.Command, args []string) error {
-			cfg, err := internal.NewConfigFromFlags()
-			if err != nil {
+			if err := opts.validate(); err != nil {
 				return err
 			}
 
-			inst, err := findInstance(cfg, opts.target)
+			cfg, err := internal.NewConfigFromFlags()
 			if err != nil {
 				return err
 			}
 
-			docName := "AWS-StartSSHSession"
-			input := &ssm.StartSessionInput{
-				DocumentName: &docName,
-				Parameters:   map[string][]string{"portNumber": {opts.port}},
-				Target:       &inst.ID,
-			}
-			session, err := ec2.NewSession(cfg, input)
+			inst, cfg, err := findInstance(cfg, opts.target, opts.last)
 			if err != nil {
 				return err
 			}
-			defer session.Close()
 
-			if err := session.RunSSH(&ec2.RunSSHInput{
-				User:                opts.user,
-				InstanceID:          inst.ID,
-				Identity:            opts.identity,
-				LocalPortForwarding: opts.fwd,
-				Command:             strings.Join(args, " "),
-			}); err != nil {
+			session, sshInput, closeSession, err := openSSHSession(cfg, inst, &opts.sshAuthOptions)
+			if err != nil {
 				return err
 			}
+			defer closeSession()
 
-			return nil
+			return exitStatus(session.RunSSH(&ec2.RunSSHInput{
+				SSHInput:      *sshInput,
+				LocalForwards: opts.fwd,
+				Command:       strings.Join(args, " "),
+				NoCommand:     opts.noCommand,
+				ForwardAgent:  opts.forwardAgent,
+			}))
 		},
 	}
 
 	cmd.Flags().StringVarP(&opts.target, "target", "t", "", "name|ID|IP|DNS of the instance")
-	cmd.Flags().StringVarP(&opts.fwd, "lforward", "L", "", "local port forwarding")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "reconnect to the previous instance of the profile and region")
+	cmd.MarkFlagsMutuallyExclusive("target", "last")
+	cmd.Flags().StringArrayVarP(&opts.fwd, "lforward", "L", nil, "local port forwarding [bind_address:]port:host:hostport (repeatable)")
+	cmd.Flags().BoolVarP(&opts.noCommand, "no-command", "N", false, "do not run a command, only forward ports")
+	cmd.Flags().BoolVarP(&opts.forwardAgent, "forward-agent", "A", false, "forward the ssh agent to the instance")
+	cmd.Flags().StringVarP(&opts.knownHosts, "known-hosts", "", "", "known_hosts file, host keys are stored by instance ID (default \"~/.ssh/known_hosts\")")
+	cmd.Flags().BoolVarP(&opts.strictHostKeyChecking, "strict-host-key-checking", "", false, "reject unknown host keys instead of adding them to the known_hosts file")
 	cmd.Flags().StringVarP(&opts.port, "port", "p", "22", "SSH port to us")
 	cmd.Flags().StringVarP(&opts.user, "user", "l", "ec2-user", "SSH user to us")
-	cmd.Flags().StringVarP(&opts.identity, "identity", "i", "", "file from which the identity (private key) for public key authentication is read (required)")
+	cmd.Flags().StringVarP(&opts.identity, "identity", "i", "", "file from which the identity (private key) for public key authentication is read, in addition to the keys of the ssh agent")
+	cmd.Flags().BoolVarP(&opts.instanceConnect, "instance-connect", "", false, "push an ephemeral key with ec2 instance connect instead of --identity")
+	cmd.Flags().BoolVarP(&opts.agent, "agent", "", false, "add the ephemeral key to the ssh agent while it is valid")
+	cmd.MarkFlagsMutuallyExclusive("identity", "instance-connect")
 
-	if err := cmd.MarkFlagRequired("identity"); err != nil {
-		panic(err)
+	return cmd
+}
+
+// exitStatus ends gotoaws with the exit status of the remote command like ssh does.
+func exitStatus(err error) error {
+	var exitErr *ssh.ExitError
+	if errors.As(err, &exitErr) {
+		return &internal.ExitError{Code: exitErr.ExitStatus()}
 	}
 
-	return cmd
+	return err
 }
@@ -0,0 +1,183 @@
+package ec2
+
+import (
+	"fmt"
+	"strings"
+
+	cmdconfig "github.com/hupe1980/gotoaws/cmd/config"
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/config"
+	"github.com/hupe1980/gotoaws/pkg/ec2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+type sshConfigOptions struct {
+	file            string
+	prefix          string
+	user            string
+	identity        string
+	instanceConnect bool
+	print           bool
+	remove          bool
+}
+
+func newSSHConfigCmd() *cobra.Command {
+	opts := &sshConfigOptions{}
+	cmd := &cobra.Command{
+		Use:   "ssh-config",
+		Short: "Write ssh config entries that connect through gotoaws",
+		Example: `gotoaws ec2 ssh-config
+gotoaws ec2 ssh-config --profiles dev,prod --all-regions --prefix aws-
+gotoaws ec2 ssh-config --instance-connect
+gotoaws ec2 ssh-config --remove`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			path := opts.file
+			if path == "" {
+				var err error
+				if path, err = ec2.DefaultSSHConfig(); err != nil {
+					return err
+				}
+			}
+
+			if opts.remove {
+				changed, err := ec2.WriteSSHConfig(path, nil)
+				if err != nil {
+					return err
+				}
+
+				if changed {
+					internal.PrintInfof("Removed the gotoaws entries from %s", path)
+				}
+
+				return nil
+			}
+
+			cfg, err := internal.NewConfigFromFlags()
+			if err != nil {
+				return err
+			}
+
+			scope, err := newScope(cfg)
+			if err != nil {
+				return err
+			}
+
+			instances, err := findInScope(scope, func(finder ec2.InstanceFinder) ([]ec2.Instance, error) {
+				return finder.Find()
+			})
+			if err != nil {
+				return err
+			}
+
+			hosts, err := opts.hosts(scope, instances)
+			if err != nil {
+				return err
+			}
+
+			if opts.print {
+				fmt.Print(ec2.RenderSSHConfig(hosts))
+				return nil
+			}
+
+			changed, err := ec2.WriteSSHConfig(path, hosts)
+			if err != nil {
+				return err
+			}
+
+			if !changed {
+				internal.PrintInfof("%s is up to date", path)
+				return nil
+			}
+
+			internal.PrintInfof("Wrote %d hosts to %s", len(hosts), path)
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.file, "file", "f", "", "ssh config file (default \"~/.ssh/config\")")
+	cmd.Flags().StringVarP(&opts.prefix, "prefix", "", "", "prefix of the host aliases")
+	cmd.Flags().StringVarP(&opts.user, "user", "l", "ec2-user", "SSH user of the hosts")
+	cmd.Flags().StringVarP(&opts.identity, "identity", "i", "", "identity file of the hosts")
+	cmd.Flags().BoolVarP(&opts.instanceConnect, "instance-connect", "", false, "push an ephemeral key with ec2 instance connect on every connection")
+	cmd.Flags().BoolVarP(&opts.print, "print", "", false, "print the entries instead of writing them")
+	cmd.Flags().BoolVarP(&opts.remove, "remove", "", false, "remove the entries written by gotoaws")
+	cmd.MarkFlagsMutuallyExclusive("identity", "instance-connect")
+	cmd.MarkFlagsMutuallyExclusive("print", "remove")
+
+	return cmd
+}
+
+// hosts returns an entry for every instance but windows instances. The proxy command
+// selects the profile, region and roles the instance was found with.
+func (opts *sshConfigOptions) hosts(scope []*config.Config, instances []ec2.Instance) ([]ec2.SSHConfigHost, error) {
+	accountRoles := make(map[string]string)
+
+	if names := viper.GetStringSlice("accounts"); len(names) > 0 {
+		accounts, err := cmdconfig.FindAccounts(names)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range accounts {
+			accountRoles[accounts[i].ID] = accounts[i].RoleARN()
+		}
+	}
+
+	var linux []ec2.Instance
+
+	for _, inst := range instances {
+		if inst.Platform != "Windows" {
+			linux = append(linux, inst)
+		}
+	}
+
+	aliases := ec2.SSHHostAliases(linux, opts.prefix)
+	hosts := make([]ec2.SSHConfigHost, 0, len(linux))
+
+	for i, inst := range linux {
+		cfg := scopeConfig(scope, &inst)
+
+		args := []string{"gotoaws", "ec2", "proxy", "--target", "%h", "--port", "%p"}
+
+		if cfg.Profile != "" {
+			args = append(args, "--profile", cfg.Profile)
+		}
+
+		args = append(args, "--region", cfg.Region)
+
+		roles := viper.GetStringSlice("role-chain")
+		if arn := viper.GetString("role-arn"); arn != "" {
+			roles = append([]string{arn}, roles...)
+		}
+
+		if arn, ok := accountRoles[inst.Account]; ok {
+			roles = append(roles, arn)
+		}
+
+		if len(roles) > 0 {
+			args = append(args, "--role-arn", roles[0])
+		}
+
+		if len(roles) > 1 {
+			args = append(args, "--role-chain", strings.Join(roles[1:], ","))
+		}
+
+		if opts.instanceConnect {
+			args = append(args, "--instance-connect", "--user", "%r")
+		}
+
+		hosts = append(hosts, ec2.SSHConfigHost{
+			Alias:        aliases[i],
+			InstanceID:   inst.ID,
+			User:         opts.user,
+			IdentityFile: opts.identity,
+			ProxyCommand: strings.Join(args, " "),
+		})
+	}
+
+	return hosts, nil
+}
@@ -0,0 +1,79 @@
+package ec2
+
+import (
+	"errors"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/hupe1980/gotoaws/pkg/config"
+	"github.com/hupe1980/gotoaws/pkg/ec2"
+	"golang.org/x/crypto/ssh"
+)
+
+// sshAuthOptions are the flags shared by the commands using ssh over the session.
+type sshAuthOptions struct {
+	port                  string
+	user                  string
+	identity              string
+	instanceConnect       bool
+	agent                 bool
+	knownHosts            string
+	strictHostKeyChecking bool
+}
+
+func (opts *sshAuthOptions) validate() error {
+	if opts.agent && !opts.instanceConnect {
+		return errors.New("--agent requires --instance-connect")
+	}
+
+	return nil
+}
+
+// openSSHSession starts an AWS-StartSSHSession session with the instance and returns
+// the ssh input of the options. With instance connect an ephemeral key is pushed.
+// The returned function closes the session and the key.
+func openSSHSession(cfg *config.Config, inst *ec2.Instance, opts *sshAuthOptions) (ec2.Session, *ec2.SSHInput, func(), error) {
+	docName := "AWS-StartSSHSession"
+	input := &ssm.StartSessionInput{
+		DocumentName: &docName,
+		Parameters:   map[string][]string{"portNumber": {opts.port}},
+		Target:       &inst.ID,
+	}
+
+	session, err := ec2.NewSession(cfg, input)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	closeFn := func() { _ = session.Close() }
+
+	var signers []ssh.Signer
+
+	if opts.instanceConnect {
+		key, err := ec2.PushEphemeralKey(cfg, &ec2.PushKeyInput{
+			InstanceID:       inst.ID,
+			AvailabilityZone: inst.AvailabilityZone,
+			User:             opts.user,
+			UseAgent:         opts.agent,
+		})
+		if err != nil {
+			closeFn()
+			return nil, nil, nil, err
+		}
+
+		closeFn = func() {
+			_ = key.Close()
+			_ = session.Close()
+		}
+
+		signers = append(signers, key.Signer)
+	}
+
+	return session, &ec2.SSHInput{
+		User:                  opts.user,
+		InstanceID:            inst.ID,
+		Identity:              opts.identity,
+		Signers:               signers,
+		KnownHosts:            opts.knownHosts,
+		StrictHostKeyChecking: opts.strictHostKeyChecking,
+	}, closeFn, nil
+}
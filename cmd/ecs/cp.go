@@ -0,0 +1,69 @@
+package ecs
+
+import (
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/ecs"
+	"github.com/spf13/cobra"
+)
+
+type cpOptions struct {
+	containerOptions
+	receiving bool
+}
+
+func newCPCmd() *cobra.Command {
+	opts := &cpOptions{}
+	cmd := &cobra.Command{
+		Use:   "cp [source] [target]",
+		Short: "Copy files and directories to and from a container",
+		Example: `gotoaws ecs cp --cluster demo-cluster ./config /app/config
+gotoaws ecs cp --cluster demo-cluster --task 1234 -R /var/log/app ./logs/
+gotoaws ecs cp --service web --newest ./config /app/config
+gotoaws ecs cp --last dump.sql /tmp/`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			cfg, err := internal.NewConfigFromFlags()
+			if err != nil {
+				return err
+			}
+
+			container, err := opts.findTarget(cfg)
+			if err != nil {
+				return err
+			}
+
+			mode := ecs.CopyModeSending
+			if opts.receiving {
+				mode = ecs.CopyModeReceiving
+			}
+
+			if err := ecs.Copy(cfg, &ecs.CopyInput{
+				Container: container,
+				Source:    args[0],
+				Target:    args[1],
+				Mode:      mode,
+			}); err != nil {
+				return err
+			}
+
+			internal.PrintInfof("Copied %s to %s", args[0], args[1])
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.receiving, "recv", "R", false, "receive files from the container")
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "", "", "arn or name of the cluster (default \"all clusters\")")
+	cmd.Flags().StringVarP(&opts.service, "service", "", "", "arn or name of the service to choose a running task of")
+	cmd.Flags().StringVarP(&opts.task, "task", "", "", "arn or id of the task")
+	cmd.Flags().StringVarP(&opts.container, "container", "", "", "name of the container. A container name only needs to be specified for tasks containing multiple containers")
+	cmd.Flags().BoolVarP(&opts.newest, "newest", "", false, "choose the newest task instead of asking")
+	cmd.Flags().BoolVarP(&opts.random, "random", "", false, "choose a random task instead of asking")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "reconnect to the previous container of the profile and region")
+	cmd.MarkFlagsMutuallyExclusive("task", "service", "last")
+	cmd.MarkFlagsMutuallyExclusive("newest", "random", "last")
+
+	return cmd
+}
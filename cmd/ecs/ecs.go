@@ -1,12 +1,18 @@
 package ecs
 
 import (
+	"errors"
 	"fmt"
+	"math/rand/v2"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/internal/history"
+	"github.com/hupe1980/gotoaws/internal/picker"
 	"github.com/hupe1980/gotoaws/pkg/config"
 	"github.com/hupe1980/gotoaws/pkg/ecs"
-	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 )
 
@@ -18,62 +24,366 @@ func NewECSCmd() *cobra.Command {
 	}
 
 	cmd.AddCommand(
+		newCPCmd(),
 		newExecCmd(),
+		newFwdCmd(),
+		newListCmd(),
+		newLogsCmd(),
+		newRunCmd(),
 	)
 
 	return cmd
 }
 
-func findContainer(cfg *config.Config, cluster string, task string, cname string) (string, string, error) {
+// containerOptions are the flags shared by the commands connecting to a container.
+type containerOptions struct {
+	cluster   string
+	service   string
+	task      string
+	container string
+	newest    bool
+	random    bool
+	last      bool
+}
+
+// findTarget resolves the container and records it in the history. With last the
+// previous container of the profile and region is used, including its cluster.
+func (opts *containerOptions) findTarget(cfg *config.Config) (*ecs.Container, error) {
+	var (
+		container *ecs.Container
+		err       error
+	)
+
+	if opts.last {
+		container, err = findLastContainer(cfg)
+	} else {
+		container, err = opts.lookupContainer(cfg)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	// The history is a convenience, failing to write it must not fail the command
+	_ = history.Add(history.Entry{
+		Kind:      history.KindECSContainer,
+		Profile:   cfg.Profile,
+		Region:    cfg.Region,
+		Account:   cfg.Account,
+		Cluster:   container.Cluster,
+		Task:      container.Task,
+		Container: container.Name,
+	})
+
+	return container, nil
+}
+
+func findLastContainer(cfg *config.Config) (*ecs.Container, error) {
+	container, err := lastContainer(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := connectable(container); err != nil {
+		return nil, err
+	}
+
+	internal.PrintInfof("Reconnecting to %s/%s/%s", container.Cluster, container.Task, container.Name)
+
+	return container, nil
+}
+
+// lastContainer returns the previous container of the profile and region, even if
+// no session can be started in it.
+func lastContainer(cfg *config.Config) (*ecs.Container, error) {
+	entry, err := history.Last(history.KindECSContainer, cfg.Profile, cfg.Region)
+	if err != nil {
+		return nil, err
+	}
+
+	containers, err := ecs.NewContainerFinder(cfg).FindByIdentifier(entry.Cluster, entry.Task, entry.Container)
+	if err != nil {
+		return nil, fmt.Errorf("previous container %s is no longer available: %w", entry.Target(), err)
+	}
+
+	return &containers[0], nil
+}
+
+func (opts *containerOptions) lookupContainer(cfg *config.Config) (*ecs.Container, error) {
+	var (
+		containers []ecs.Container
+		err        error
+	)
+
 	finder := ecs.NewContainerFinder(cfg)
-	if task != "" {
-		containers, err := finder.FindByIdentifier(cluster, task, cname)
+
+	switch {
+	case opts.task != "":
+		containers, err = finder.FindByIdentifier(opts.cluster, opts.task, opts.container)
+	case opts.service != "":
+		containers, err = finder.FindByService(opts.cluster, opts.service)
+	default:
+		containers, err = drillDown(cfg, opts.cluster)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.container != "" {
+		containers = filterContainers(containers, opts.container)
+		if len(containers) == 0 {
+			return nil, fmt.Errorf("no container %s found: %w", opts.container, ecs.ErrNoContainers)
+		}
+	}
+
+	if opts.newest || opts.random {
+		if containers = selectTask(containers, opts.newest); len(containers) == 0 {
+			return nil, errors.New("no task with a connectable container found")
+		}
+
+		internal.PrintInfof("Selected task %s", containers[0].Task)
+	}
+
+	if len(containers) > 1 {
+		return chooseContainer(containers)
+	}
+
+	if err := connectable(&containers[0]); err != nil {
+		return nil, err
+	}
+
+	return &containers[0], nil
+}
+
+// drillDown lets the user choose the cluster, if it is empty, and the service of
+// the containers.
+func drillDown(cfg *config.Config, cluster string) ([]ecs.Container, error) {
+	clusterFinder := ecs.NewClusterFinder(cfg)
+
+	if cluster == "" {
+		clusters, err := clusterFinder.Find("")
 		if err != nil {
-			return "", "", err
+			return nil, err
 		}
 
-		if len(containers) > 1 {
-			return chooseContainer(containers)
+		c := &clusters[0]
+		if len(clusters) > 1 {
+			if c, err = chooseCluster(clusters); err != nil {
+				return nil, err
+			}
 		}
 
-		return containers[0].Task, containers[0].Name, nil
+		cluster = c.Name
 	}
 
-	containers, err := finder.Find(cluster)
+	services, err := clusterFinder.FindServices(cluster)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
-	return chooseContainer(containers)
+	finder := ecs.NewContainerFinder(cfg)
+
+	if len(services) == 0 {
+		return finder.Find(cluster)
+	}
+
+	service, err := chooseService(services)
+	if err != nil {
+		return nil, err
+	}
+
+	if service == nil {
+		return finder.Find(cluster)
+	}
+
+	return finder.FindByService(cluster, service.Name)
 }
 
-func chooseContainer(containers []ecs.Container) (string, string, error) {
-	templates := &promptui.SelectTemplates{
-		Active:   fmt.Sprintf(`%s {{ .Name | cyan | bold }} ({{ .Task }})`, promptui.IconSelect),
-		Inactive: `   {{ .Name | cyan }} ({{ .Task }})`,
-		Selected: fmt.Sprintf(`%s {{ "Container" }}: {{ .Name | cyan }} ({{ .Task }})`, promptui.IconGood),
+func filterContainers(containers []ecs.Container, name string) []ecs.Container {
+	var filtered []ecs.Container
+
+	for _, c := range containers {
+		if c.Name == name {
+			filtered = append(filtered, c)
+		}
+	}
+
+	return filtered
+}
+
+// selectTask returns the containers of the newest or a random task with a connectable container.
+func selectTask(containers []ecs.Container, newest bool) []ecs.Container {
+	var tasks []string
+
+	started := make(map[string]time.Time)
+
+	for _, c := range containers {
+		if _, ok := started[c.Task]; !ok && c.Unavailable == "" {
+			tasks = append(tasks, c.Task)
+			started[c.Task] = c.StartedAt
+		}
+	}
+
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	task := tasks[rand.IntN(len(tasks))] // nolint: gosec // no security relevance
+	if newest {
+		task = tasks[0]
+		for _, t := range tasks[1:] {
+			if started[t].After(started[task]) {
+				task = t
+			}
+		}
+	}
+
+	var selected []ecs.Container
+
+	for _, c := range containers {
+		if c.Task == task {
+			selected = append(selected, c)
+		}
+	}
+
+	return selected
+}
+
+func chooseCluster(clusters []ecs.Cluster) (*ecs.Cluster, error) {
+	items := make([]picker.Item, 0, len(clusters))
+
+	for _, c := range clusters {
+		items = append(items, picker.Item{
+			Key:         c.ARN,
+			Label:       c.Name,
+			Description: fmt.Sprintf("(%d tasks, %d services)", c.RunningTasks, c.ActiveServices),
+			Fields:      []string{c.ARN},
+		})
 	}
 
-	searcher := func(input string, index int) bool {
-		container := containers[index]
-		name := strings.Replace(strings.ToLower(container.Name), " ", "", -1)
-		input = strings.Replace(strings.ToLower(input), " ", "", -1)
+	p := &picker.Picker{
+		Label:    "Choose a cluster",
+		Kind:     "ecs-cluster",
+		Selected: "Cluster",
+	}
+
+	i, err := p.Pick(items)
+	if err != nil {
+		return nil, err
+	}
+
+	return &clusters[i], nil
+}
+
+// chooseService returns nil if all tasks of the cluster are chosen, including
+// tasks that were not started by a service.
+func chooseService(services []ecs.Service) (*ecs.Service, error) {
+	items := make([]picker.Item, 0, len(services)+1)
+
+	items = append(items, picker.Item{
+		Key:         "*",
+		Label:       "All tasks",
+		Description: "(including standalone tasks)",
+	})
 
-		return strings.Contains(name, input)
+	for _, s := range services {
+		items = append(items, picker.Item{
+			Key:         s.ARN,
+			Label:       s.Name,
+			Description: fmt.Sprintf("(%d/%d running, %s)", s.RunningCount, s.DesiredCount, s.TaskDefinition),
+			Fields:      []string{s.ARN, s.TaskDefinition},
+			Details: []picker.Detail{
+				{Name: "Exec", Value: strconv.FormatBool(s.ExecEnabled)},
+			},
+		})
 	}
 
-	prompt := promptui.Select{
-		Label:     "Choose a container",
-		Items:     containers,
-		Templates: templates,
-		Size:      15,
-		Searcher:  searcher,
+	p := &picker.Picker{
+		Label:    "Choose a service",
+		Kind:     "ecs-service",
+		Selected: "Service",
 	}
 
-	i, _, err := prompt.Run()
+	i, err := p.Pick(items)
 	if err != nil {
-		return "", "", err
+		return nil, err
+	}
+
+	if i == 0 {
+		return nil, nil
+	}
+
+	return &services[i-1], nil
+}
+
+func chooseContainer(containers []ecs.Container) (*ecs.Container, error) {
+	container, err := pickContainer(containers)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := connectable(container); err != nil {
+		return nil, err
+	}
+
+	return container, nil
+}
+
+func pickContainer(containers []ecs.Container) (*ecs.Container, error) {
+	items := make([]picker.Item, 0, len(containers))
+
+	for _, c := range containers {
+		description := fmt.Sprintf("(%s)", c.Task)
+		if c.Service != "" {
+			description = fmt.Sprintf("(%s, %s)", c.Task, c.Service)
+		}
+
+		if c.Unavailable != "" {
+			description = fmt.Sprintf("%s unavailable: %s", description, c.Unavailable)
+		}
+
+		started := ""
+		if !c.StartedAt.IsZero() {
+			started = c.StartedAt.Local().Format(time.RFC3339)
+		}
+
+		items = append(items, picker.Item{
+			Key:         fmt.Sprintf("%s/%s", c.Task, c.Name),
+			Label:       c.Name,
+			Description: description,
+			Fields:      []string{c.Task, c.Service, c.TaskDefinition, c.RuntimeID},
+			Details: []picker.Detail{
+				{Name: "Cluster", Value: c.Cluster},
+				{Name: "Group", Value: c.Group},
+				{Name: "Task definition", Value: c.TaskDefinition},
+				{Name: "Launch type", Value: c.LaunchType},
+				{Name: "Status", Value: strings.TrimSpace(fmt.Sprintf("%s %s", c.LastStatus, c.HealthStatus))},
+				{Name: "Started", Value: started},
+				{Name: "Exec agent", Value: c.ExecAgentStatus},
+			},
+		})
+	}
+
+	p := &picker.Picker{
+		Label:    "Choose a container",
+		Kind:     "ecs-container",
+		Selected: "Container",
+	}
+
+	i, err := p.Pick(items)
+	if err != nil {
+		return nil, err
+	}
+
+	return &containers[i], nil
+}
+
+// connectable returns why no session can be started in the container, if any.
+func connectable(c *ecs.Container) error {
+	if c.Unavailable != "" {
+		return fmt.Errorf("cannot connect to container %s of task %s: %s", c.Name, c.Task, c.Unavailable)
 	}
 
-	return containers[i].Task, containers[i].Name, nil
+	return nil
 }
@@ -0,0 +1,55 @@
+package ecs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/ecs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSelectTask(t *testing.T) {
+	now := time.Now()
+
+	containers := []ecs.Container{
+		{Task: "old", Name: "app", StartedAt: now.Add(-time.Hour)},
+		{Task: "new", Name: "app", StartedAt: now},
+		{Task: "new", Name: "sidecar", StartedAt: now},
+		{Task: "newest", Name: "app", StartedAt: now.Add(time.Hour), Unavailable: "exec agent is pending"},
+	}
+
+	selected := selectTask(containers, true)
+	assert.Equal(t, []ecs.Container{containers[1], containers[2]}, selected)
+
+	for i := 0; i < 10; i++ {
+		selected = selectTask(containers, false)
+		assert.NotEqual(t, "newest", selected[0].Task)
+	}
+
+	assert.Nil(t, selectTask(containers[3:], true))
+}
+
+func TestOneContainerPerTask(t *testing.T) {
+	containers := []ecs.Container{
+		{Task: "1", Name: "app"},
+		{Task: "1", Name: "sidecar"},
+		{Task: "2", Name: "app"},
+	}
+
+	selected, err := oneContainerPerTask(containers, "app")
+	assert.NoError(t, err)
+	assert.Equal(t, []ecs.Container{containers[0], containers[2]}, selected)
+
+	_, err = oneContainerPerTask(containers, "")
+	assert.EqualError(t, err, "task 1 has more than one container (app, sidecar), choose one with --container")
+
+	_, err = oneContainerPerTask(containers, "db")
+	assert.ErrorIs(t, err, ecs.ErrNoContainers)
+}
+
+func TestExitStatus(t *testing.T) {
+	assert.NoError(t, exitStatus([]*ecs.RunResult{{ExitCode: 0}}))
+	assert.Equal(t, &internal.ExitError{Code: 3}, exitStatus([]*ecs.RunResult{{ExitCode: 0}, {ExitCode: 3}, {ExitCode: -1}}))
+	assert.Equal(t, &internal.ExitError{Code: 255}, exitStatus([]*ecs.RunResult{{ExitCode: -1}, {ExitCode: 3}}))
+}
@@ -1,19 +1,17 @@
 package ecs
 
 import (
+	"fmt"
 	"strings"
 
-	"github.com/aws/aws-sdk-go-v2/aws"
-	aws_ecs "github.com/aws/aws-sdk-go-v2/service/ecs"
 	"github.com/hupe1980/gotoaws/internal"
 	"github.com/hupe1980/gotoaws/pkg/ecs"
 	"github.com/spf13/cobra"
 )
 
 type execOptions struct {
-	cluster   string
-	task      string
-	container string
+	containerOptions
+	record string
 }
 
 func newExecCmd() *cobra.Command {
@@ -23,14 +21,18 @@ func newExecCmd() *cobra.Command {
 		Short:         "Execute a command in a container",
 		SilenceUsage:  true,
 		SilenceErrors: true,
-		Example:       "gotoaws ecs exec --cluster demo-cluster",
+		Example: `gotoaws ecs exec --cluster demo-cluster
+gotoaws ecs exec --service web --newest -- /bin/bash
+gotoaws ecs exec --task arn:aws:ecs:eu-central-1:123456789012:task/demo-cluster/0123456789abcdef0
+gotoaws ecs exec --last
+gotoaws ecs exec --cluster demo-cluster --record exec.cast`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := internal.NewConfigFromFlags()
 			if err != nil {
 				return err
 			}
 
-			task, container, err := findContainer(cfg, opts.cluster, opts.task, opts.container)
+			container, err := opts.findTarget(cfg)
 			if err != nil {
 				return err
 			}
@@ -40,29 +42,40 @@ func newExecCmd() *cobra.Command {
 				command = args[i:]
 			}
 
-			input := &aws_ecs.ExecuteCommandInput{
-				Interactive: true,
-				Command:     aws.String(strings.Join(command, " ")),
-				Cluster:     &opts.cluster,
-				Task:        &task,
-				Container:   &container,
-			}
-			session, err := ecs.NewSession(cfg, input)
+			session, err := ecs.NewSession(cfg, container, strings.Join(command, " "))
 			if err != nil {
 				return err
 			}
 			defer session.Close()
 
-			if err := session.RunPlugin(); err != nil {
+			if opts.record != "" {
+				rec, err := internal.NewSessionRecorder(cfg, opts.record, fmt.Sprintf("%s/%s/%s", container.Cluster, container.Task, container.Name))
+				if err != nil {
+					return err
+				}
+				defer rec.Close()
+
+				return session.RecordShell(rec.Recorder)
+			}
+
+			if err := session.RunShell(); err != nil {
 				return err
 			}
 			return nil
 		},
 	}
 
-	cmd.Flags().StringVarP(&opts.cluster, "cluster", "", "default", "arn or name of the cluster")
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "", "", "arn or name of the cluster (default \"all clusters\")")
+	cmd.Flags().StringVarP(&opts.service, "service", "", "", "arn or name of the service to choose a running task of")
 	cmd.Flags().StringVarP(&opts.task, "task", "", "", "arn or id of the task")
 	cmd.Flags().StringVarP(&opts.container, "container", "", "", "name of the container. A container name only needs to be specified for tasks containing multiple containers")
+	cmd.Flags().BoolVarP(&opts.newest, "newest", "", false, "choose the newest task instead of asking")
+	cmd.Flags().BoolVarP(&opts.random, "random", "", false, "choose a random task instead of asking")
+
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "reconnect to the previous container of the profile and region")
+	cmd.Flags().StringVarP(&opts.record, "record", "", "", "record the session to a file in asciicast v2 format")
+	cmd.MarkFlagsMutuallyExclusive("task", "service", "last")
+	cmd.MarkFlagsMutuallyExclusive("newest", "random", "last")
 
 	return cmd
 }
@@ -0,0 +1,135 @@
+package ecs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/config"
+	"github.com/hupe1980/gotoaws/pkg/ec2"
+	"github.com/hupe1980/gotoaws/pkg/ecs"
+	"github.com/spf13/cobra"
+)
+
+type fwdOptions struct {
+	containerOptions
+	remotePortNumber string
+	remoteHost       string
+	localPortNumber  string
+	specs            []string
+	reconnect        bool
+	keepAlive        time.Duration
+}
+
+func newFwdCmd() *cobra.Command {
+	opts := &fwdOptions{}
+	cmd := &cobra.Command{
+		Use:   "fwd",
+		Short: "Port forwarding",
+		Example: `gotoaws ecs fwd --cluster demo-cluster -l 8080 -r 8080
+gotoaws ecs fwd --cluster demo-cluster --task 1234 -l 5432 -r 5432 -H xxx.rds.amazonaws.com
+gotoaws ecs fwd --cluster demo-cluster -L 5432:xxx.rds.amazonaws.com:5432 -L 6379:xxx.cache.amazonaws.com:6379
+gotoaws ecs fwd --service bastion --random -L 5432:xxx.rds.amazonaws.com:5432
+gotoaws ecs fwd --last -L 5432:xxx.rds.amazonaws.com:5432 --keepalive 1m`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			specs, err := internal.ParseForwardSpecs(opts.specs, opts.localPortNumber, opts.remoteHost, opts.remotePortNumber)
+			if err != nil {
+				return err
+			}
+
+			cfg, err := internal.NewConfigFromFlags()
+			if err != nil {
+				return err
+			}
+
+			container, err := opts.findTarget(cfg)
+			if err != nil {
+				return err
+			}
+
+			if container.RuntimeID == "" {
+				return fmt.Errorf("container %s of task %s has no runtime id yet", container.Name, container.Task)
+			}
+
+			forwarders := make([]*ec2.Forwarder, 0, len(specs))
+
+			for _, spec := range specs {
+				session, err := ec2.NewSession(cfg, spec.StartSessionInput(container.Target()))
+				if err != nil {
+					return err
+				}
+				defer session.Close()
+
+				forwarders = append(forwarders, opts.newForwarder(cfg, container, session, spec))
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-sigs
+				cancel()
+			}()
+
+			return internal.RunForwarders(ctx, specs, forwarders)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "", "", "arn or name of the cluster (default \"all clusters\")")
+	cmd.Flags().StringVarP(&opts.service, "service", "", "", "arn or name of the service to choose a running task of")
+	cmd.Flags().StringVarP(&opts.task, "task", "", "", "arn or id of the task")
+	cmd.Flags().StringVarP(&opts.container, "container", "", "", "name of the container. A container name only needs to be specified for tasks containing multiple containers")
+	cmd.Flags().BoolVarP(&opts.newest, "newest", "", false, "choose the newest task instead of asking")
+	cmd.Flags().BoolVarP(&opts.random, "random", "", false, "choose a random task instead of asking")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "reconnect to the previous container of the profile and region")
+	cmd.MarkFlagsMutuallyExclusive("task", "service", "last")
+	cmd.MarkFlagsMutuallyExclusive("newest", "random", "last")
+	cmd.Flags().StringVarP(&opts.remotePortNumber, "remote", "r", "", "remote port to forward to")
+	cmd.Flags().StringVarP(&opts.remoteHost, "host", "H", "", "remote host to forward to")
+	cmd.Flags().StringVarP(&opts.localPortNumber, "local", "l", "", "local port to use")
+	cmd.Flags().StringArrayVarP(&opts.specs, "forward", "L", nil, "local:host:remote or local:remote port forwarding (repeatable)")
+	cmd.Flags().BoolVarP(&opts.reconnect, "reconnect", "", true, "restart dropped sessions")
+	cmd.Flags().DurationVarP(&opts.keepAlive, "keepalive", "", 0, "interval of keepalive messages to prevent idle timeouts (e.g. 1m)")
+
+	return cmd
+}
+
+func (opts *fwdOptions) newForwarder(cfg *config.Config, container *ecs.Container, session ec2.Session, spec *ec2.ForwardSpec) *ec2.Forwarder {
+	if !opts.reconnect {
+		return internal.NewForwarder(session, spec, opts.keepAlive, nil)
+	}
+
+	return internal.NewForwarder(session, spec, opts.keepAlive, func() (ec2.Session, error) {
+		// A stopped task does not come back, so only the same container is retried
+		containers, err := ecs.NewContainerFinder(cfg).FindByIdentifier(container.Cluster, container.Task, container.Name)
+		if errors.Is(err, ecs.ErrNoContainers) {
+			return nil, fmt.Errorf("task %s: %w", container.Task, ec2.ErrTargetUnavailable)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		if err := connectable(&containers[0]); err != nil {
+			return nil, fmt.Errorf("%w: %s", ec2.ErrTargetUnavailable, err)
+		}
+
+		session, err := ec2.NewSession(cfg, spec.StartSessionInput(containers[0].Target()))
+		if err != nil {
+			return nil, err
+		}
+
+		internal.PrintInfof("[%s] Reconnected to %s/%s", spec, container.Task, container.Name)
+
+		return session, nil
+	})
+}
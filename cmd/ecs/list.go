@@ -0,0 +1,64 @@
+package ecs
+
+import (
+	"time"
+
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/ecs"
+	"github.com/spf13/cobra"
+)
+
+type listOptions struct {
+	cluster string
+	service string
+}
+
+func newListCmd() *cobra.Command {
+	opts := &listOptions{}
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List running containers and whether they are connectable",
+		Example: `gotoaws ecs list
+gotoaws ecs list --cluster my-cluster --output json
+gotoaws ecs list --service arn:aws:ecs:eu-central-1:123456789012:service/my-cluster/web`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.NewConfigFromFlags()
+			if err != nil {
+				return err
+			}
+
+			finder := ecs.NewContainerFinder(cfg)
+
+			var containers []ecs.Container
+
+			if opts.service != "" {
+				containers, err = finder.FindByService(opts.cluster, opts.service)
+			} else {
+				containers, err = finder.Find(opts.cluster)
+			}
+
+			if err != nil {
+				return err
+			}
+
+			table := &internal.Table{Header: []string{"CLUSTER", "TASK", "CONTAINER", "SERVICE", "TASK DEFINITION", "LAUNCH TYPE", "STATUS", "HEALTH", "STARTED", "EXEC AGENT", "UNAVAILABLE"}}
+			for _, c := range containers {
+				started := ""
+				if !c.StartedAt.IsZero() {
+					started = c.StartedAt.Local().Format(time.RFC3339)
+				}
+
+				table.AddRow(c.Cluster, c.Task, c.Name, c.Service, c.TaskDefinition, c.LaunchType, c.LastStatus, c.HealthStatus, started, c.ExecAgentStatus, c.Unavailable)
+			}
+
+			return internal.PrintOutput(containers, table)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "", "", "arn or name of the cluster (default \"all clusters\")")
+	cmd.Flags().StringVarP(&opts.service, "service", "", "", "arn or name of the service")
+
+	return cmd
+}
@@ -0,0 +1,182 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/config"
+	"github.com/hupe1980/gotoaws/pkg/ecs"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// prefixColors tell the containers apart when their logs are interleaved.
+var prefixColors = []func(interface{}) string{
+	promptui.Styler(promptui.FGCyan),
+	promptui.Styler(promptui.FGGreen),
+	promptui.Styler(promptui.FGMagenta),
+	promptui.Styler(promptui.FGYellow),
+	promptui.Styler(promptui.FGBlue),
+	promptui.Styler(promptui.FGRed),
+}
+
+type logsOptions struct {
+	cluster       string
+	service       string
+	tasks         []string
+	container     string
+	last          bool
+	follow        bool
+	since         time.Duration
+	filterPattern string
+}
+
+func newLogsCmd() *cobra.Command {
+	opts := &logsOptions{}
+	cmd := &cobra.Command{
+		Use:   "logs",
+		Short: "Print the cloudwatch logs of containers",
+		Example: `gotoaws ecs logs --cluster demo-cluster
+gotoaws ecs logs --service web --container app --since 1h --follow
+gotoaws ecs logs --task 1234 --task 5678 --filter-pattern ERROR
+gotoaws ecs logs --last -f`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.NewConfigFromFlags()
+			if err != nil {
+				return err
+			}
+
+			containers, err := opts.findContainers(cfg)
+			if err != nil {
+				return err
+			}
+
+			streams, err := ecs.NewLogStreamFinder(cfg).Find(containers, func(err error) {
+				internal.PrintErrorf("%s, skipping it", err)
+			})
+			if err != nil {
+				return err
+			}
+
+			input := &ecs.LogsInput{
+				Streams:       streams,
+				FilterPattern: opts.filterPattern,
+				Follow:        opts.follow,
+				Writer:        newLogWriter(streams, term.IsTerminal(int(os.Stdout.Fd()))),
+			}
+
+			if opts.since > 0 {
+				input.StartTime = time.Now().Add(-opts.since)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-sigs
+				cancel()
+			}()
+
+			return ecs.NewLogReader(cfg).Read(ctx, input)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "", "", "arn or name of the cluster (default \"all clusters\")")
+	cmd.Flags().StringVarP(&opts.service, "service", "", "", "arn or name of the service to print the logs of all running tasks of")
+	cmd.Flags().StringArrayVarP(&opts.tasks, "task", "", nil, "arn or id of the task (repeatable)")
+	cmd.Flags().StringVarP(&opts.container, "container", "", "", "name of the container (default \"all containers\")")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "print the logs of the previous container of the profile and region")
+	cmd.Flags().BoolVarP(&opts.follow, "follow", "f", false, "wait for new log events")
+	cmd.Flags().DurationVarP(&opts.since, "since", "", 0, "print the log events of the duration (e.g. 10m, 1h) instead of all")
+	cmd.Flags().StringVarP(&opts.filterPattern, "filter-pattern", "", "", "print the log events matching the cloudwatch logs filter pattern")
+	cmd.MarkFlagsMutuallyExclusive("task", "service", "last")
+
+	return cmd
+}
+
+// findContainers returns the containers of the service or tasks. Without either a
+// single container is chosen like for exec, which does not need to be connectable.
+func (opts *logsOptions) findContainers(cfg *config.Config) ([]ecs.Container, error) {
+	if opts.last {
+		container, err := lastContainer(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		return []ecs.Container{*container}, nil
+	}
+
+	finder := ecs.NewContainerFinder(cfg)
+
+	var (
+		containers []ecs.Container
+		err        error
+	)
+
+	switch {
+	case opts.service != "":
+		containers, err = finder.FindByService(opts.cluster, opts.service)
+	case len(opts.tasks) > 0:
+		for _, task := range opts.tasks {
+			found, ferr := finder.FindByIdentifier(opts.cluster, task, opts.container)
+			if ferr != nil {
+				return nil, fmt.Errorf("task %s: %w", task, ferr)
+			}
+
+			containers = append(containers, found...)
+		}
+	default:
+		containers, err = drillDown(cfg, opts.cluster)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.container != "" {
+		if containers = filterContainers(containers, opts.container); len(containers) == 0 {
+			return nil, fmt.Errorf("no container %s found: %w", opts.container, ecs.ErrNoContainers)
+		}
+	}
+
+	if opts.service == "" && len(opts.tasks) == 0 && len(containers) > 1 {
+		container, err := pickContainer(containers)
+		if err != nil {
+			return nil, err
+		}
+
+		return []ecs.Container{*container}, nil
+	}
+
+	return containers, nil
+}
+
+// newLogWriter prints the events with a [task/<id>/<container>] prefix. On a
+// terminal every container gets its own color.
+func newLogWriter(streams []ecs.LogStream, colored bool) func(e *ecs.LogEvent) {
+	prefixes := make(map[string]string, len(streams))
+
+	for i, s := range streams {
+		prefix := fmt.Sprintf("[task/%s/%s]", s.Task, s.Container)
+		if colored {
+			prefix = prefixColors[i%len(prefixColors)](prefix)
+		}
+
+		prefixes[s.Stream] = prefix
+	}
+
+	return func(e *ecs.LogEvent) {
+		fmt.Fprintln(os.Stdout, prefixes[e.Stream.Stream], strings.TrimSuffix(e.Message, "\n"))
+	}
+}
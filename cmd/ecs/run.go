@@ -0,0 +1,256 @@
+package ecs
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/config"
+	"github.com/hupe1980/gotoaws/pkg/ecs"
+	"github.com/spf13/cobra"
+)
+
+// maxRuns limits the sessions started at the same time for the tasks of a service.
+const maxRuns = 10
+
+type runOptions struct {
+	cluster   string
+	service   string
+	tasks     []string
+	container string
+	last      bool
+}
+
+func newRunCmd() *cobra.Command {
+	opts := &runOptions{}
+	cmd := &cobra.Command{
+		Use:   "run [flags] -- COMMAND [args...]",
+		Short: "Run a command in containers and capture its output",
+		Example: `gotoaws ecs run --cluster demo-cluster -- env
+gotoaws ecs run --service web --container app -- ./manage.py migrate --check
+gotoaws ecs run --task 1234 --task 5678 --output json -- cat /app/VERSION
+gotoaws ecs run --last -- date`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			command := []string{}
+			if i := cmd.ArgsLenAtDash(); i != -1 {
+				command = args[i:]
+			}
+
+			if len(command) == 0 {
+				return errors.New("command is missing")
+			}
+
+			cfg, err := internal.NewConfigFromFlags()
+			if err != nil {
+				return err
+			}
+
+			containers, err := opts.findContainers(cfg)
+			if err != nil {
+				return err
+			}
+
+			if len(containers) == 1 && !internal.IsStructuredOutput() {
+				return runSingle(cfg, &containers[0], strings.Join(command, " "))
+			}
+
+			var printFn func(res *ecs.RunResult)
+			if !internal.IsStructuredOutput() {
+				printFn = printRunResult
+			}
+
+			results := runAll(cfg, containers, strings.Join(command, " "), printFn)
+
+			table := &internal.Table{Header: []string{"CLUSTER", "TASK", "CONTAINER", "EXIT CODE"}}
+			for _, res := range results {
+				table.AddRow(res.Cluster, res.Task, res.Container, res.ExitCode)
+			}
+
+			if err := internal.PrintOutput(results, table); err != nil {
+				return err
+			}
+
+			return exitStatus(results)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.cluster, "cluster", "", "", "arn or name of the cluster (default \"all clusters\")")
+	cmd.Flags().StringVarP(&opts.service, "service", "", "", "arn or name of the service to run the command in all running tasks of")
+	cmd.Flags().StringArrayVarP(&opts.tasks, "task", "", nil, "arn or id of the task (repeatable)")
+	cmd.Flags().StringVarP(&opts.container, "container", "", "", "name of the container. A container name only needs to be specified for tasks containing multiple containers")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "run in the previous container of the profile and region")
+	cmd.MarkFlagsMutuallyExclusive("task", "service", "last")
+
+	return cmd
+}
+
+// findContainers returns the container of every task. Without a service or several
+// tasks a single container is resolved like for exec.
+func (opts *runOptions) findContainers(cfg *config.Config) ([]ecs.Container, error) {
+	if opts.service == "" && len(opts.tasks) <= 1 {
+		target := &containerOptions{cluster: opts.cluster, container: opts.container, last: opts.last}
+		if len(opts.tasks) == 1 {
+			target.task = opts.tasks[0]
+		}
+
+		container, err := target.findTarget(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		return []ecs.Container{*container}, nil
+	}
+
+	finder := ecs.NewContainerFinder(cfg)
+
+	var containers []ecs.Container
+
+	if opts.service != "" {
+		var err error
+		if containers, err = finder.FindByService(opts.cluster, opts.service); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, task := range opts.tasks {
+			found, err := finder.FindByIdentifier(opts.cluster, task, opts.container)
+			if err != nil {
+				return nil, fmt.Errorf("task %s: %w", task, err)
+			}
+
+			containers = append(containers, found...)
+		}
+	}
+
+	return oneContainerPerTask(containers, opts.container)
+}
+
+// oneContainerPerTask returns the container with the name of every task. Without a
+// name every task must have a single container.
+func oneContainerPerTask(containers []ecs.Container, name string) ([]ecs.Container, error) {
+	if name != "" {
+		if containers = filterContainers(containers, name); len(containers) == 0 {
+			return nil, fmt.Errorf("no container %s found: %w", name, ecs.ErrNoContainers)
+		}
+
+		return containers, nil
+	}
+
+	names := make(map[string][]string)
+
+	for _, c := range containers {
+		names[c.Task] = append(names[c.Task], c.Name)
+
+		if len(names[c.Task]) > 1 {
+			return nil, fmt.Errorf("task %s has more than one container (%s), choose one with --container", c.Task, strings.Join(names[c.Task], ", "))
+		}
+	}
+
+	return containers, nil
+}
+
+// runSingle streams the output of the command and exits with its exit status.
+func runSingle(cfg *config.Config, container *ecs.Container, command string) error {
+	res, err := ecs.Run(cfg, &ecs.RunInput{
+		Container: container,
+		Command:   command,
+		Stdout:    os.Stdout,
+		Stderr:    os.Stderr,
+	})
+	if err != nil {
+		return err
+	}
+
+	if res.ExitCode != 0 {
+		return &internal.ExitError{Code: res.ExitCode}
+	}
+
+	return nil
+}
+
+// runAll runs the command in the containers concurrently. Containers that cannot be
+// connected to and failed sessions are reported with the exit code -1.
+func runAll(cfg *config.Config, containers []ecs.Container, command string, printFn func(res *ecs.RunResult)) []*ecs.RunResult {
+	results := make([]*ecs.RunResult, len(containers))
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+	)
+
+	sem := make(chan struct{}, maxRuns)
+
+	for i := range containers {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			c := &containers[i]
+
+			res, err := runContainer(cfg, c, command)
+			if err != nil {
+				res = &ecs.RunResult{Cluster: c.Cluster, Task: c.Task, Container: c.Name, ExitCode: -1, Error: err.Error()}
+			}
+
+			results[i] = res
+
+			if printFn != nil {
+				mu.Lock()
+				defer mu.Unlock()
+
+				printFn(res)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
+func runContainer(cfg *config.Config, c *ecs.Container, command string) (*ecs.RunResult, error) {
+	if err := connectable(c); err != nil {
+		return nil, err
+	}
+
+	return ecs.Run(cfg, &ecs.RunInput{Container: c, Command: command})
+}
+
+func printRunResult(res *ecs.RunResult) {
+	if res.ExitCode == 0 {
+		internal.PrintInfof("%s/%s: exit code %d", res.Task, res.Container, res.ExitCode)
+	} else {
+		internal.PrintErrorf("%s/%s: exit code %d", res.Task, res.Container, res.ExitCode)
+	}
+
+	if res.Output != "" {
+		fmt.Fprintln(os.Stdout, strings.TrimSuffix(res.Output, "\n"))
+	}
+
+	if res.Error != "" {
+		fmt.Fprintln(os.Stderr, strings.TrimSuffix(res.Error, "\n"))
+	}
+}
+
+// exitStatus returns the exit status of the first task that failed. Like ssh, 255
+// reports that the command did not finish.
+func exitStatus(results []*ecs.RunResult) error {
+	for _, res := range results {
+		switch {
+		case res.ExitCode == -1:
+			return &internal.ExitError{Code: 255}
+		case res.ExitCode != 0:
+			return &internal.ExitError{Code: res.ExitCode}
+		}
+	}
+
+	return nil
+}
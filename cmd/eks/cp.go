@@ -0,0 +1,76 @@
+package eks
+
+import (
+	"context"
+
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/eks"
+	"github.com/spf13/cobra"
+)
+
+type cpOptions struct {
+	podOptions
+	receiving bool
+}
+
+func newCPCmd() *cobra.Command {
+	opts := &cpOptions{}
+	cmd := &cobra.Command{
+		Use:   "cp [source] [target]",
+		Short: "Copy files and directories to and from a container",
+		Example: `gotoaws eks cp --cluster gotoaws --role cluster-admin --pod nginx ./html /usr/share/nginx/html
+gotoaws eks cp --cluster gotoaws --role cluster-admin --pod nginx -R /var/log/nginx ./logs/
+gotoaws eks cp --last dump.sql /tmp/`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(2),
+		RunE: func(_ *cobra.Command, args []string) error {
+			cfg, err := internal.NewConfigFromFlags()
+			if err != nil {
+				return err
+			}
+
+			cluster, pod, err := opts.findTarget(cfg)
+			if err != nil {
+				return err
+			}
+
+			client, err := eks.NewKubeclient(cfg, cluster, opts.role)
+			if err != nil {
+				return err
+			}
+
+			mode := eks.CopyModeSending
+			if opts.receiving {
+				mode = eks.CopyModeReceiving
+			}
+
+			if err := client.Copy(context.Background(), &eks.CopyInput{
+				Namespace: pod.Namespace,
+				PodName:   pod.Name,
+				Container: pod.Container,
+				Source:    args[0],
+				Target:    args[1],
+				Mode:      mode,
+			}); err != nil {
+				return err
+			}
+
+			internal.PrintInfof("Copied %s to %s", args[0], args[1])
+
+			return nil
+		},
+	}
+
+	cmd.Flags().BoolVarP(&opts.receiving, "recv", "R", false, "receive files from the container")
+	cmd.Flags().StringVarP(&opts.clusterName, "cluster", "", "", "arn or name of the cluster")
+	cmd.Flags().StringVarP(&opts.role, "role", "", "", "arn or name of the role")
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "namespace of the pod (default \"all namespaces\"")
+	cmd.Flags().StringVarP(&opts.pod, "pod", "p", "", "name of the pod")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "reconnect to the previous pod of the profile and region")
+	cmd.Flags().StringVarP(&opts.container, "container", "c", "", "name of the container")
+
+	cmd.MarkFlagsMutuallyExclusive("pod", "last")
+
+	return cmd
+}
@@ -2,12 +2,12 @@ package eks
 
 import (
 	"fmt"
-	"strings"
 
 	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/internal/history"
+	"github.com/hupe1980/gotoaws/internal/picker"
 	"github.com/hupe1980/gotoaws/pkg/config"
 	"github.com/hupe1980/gotoaws/pkg/eks"
-	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 )
 
@@ -22,13 +22,92 @@ func NewEKSCmd() *cobra.Command {
 		newUpdateKubeconfigCmd(),
 		newGetTokenCmd(),
 		newExecCmd(),
+		newCPCmd(),
 		newFwdCmd(),
 		newLogsCmd(),
+		newListCmd(),
 	)
 
 	return cmd
 }
 
+// podOptions are the flags shared by the commands connecting to a pod.
+type podOptions struct {
+	clusterName string
+	role        string
+	namespace   string
+	pod         string
+	container   string
+	last        bool
+}
+
+// findTarget resolves the cluster and pod and records them in the history. With last the
+// previous pod of the profile and region is used. Its role applies unless --role is set.
+func (opts *podOptions) findTarget(cfg *config.Config) (*eks.Cluster, *eks.Pod, error) {
+	var (
+		cluster *eks.Cluster
+		pod     *eks.Pod
+		err     error
+	)
+
+	if opts.last {
+		cluster, pod, err = opts.findLastTarget(cfg)
+	} else {
+		if cluster, err = findCluster(cfg, opts.clusterName); err == nil {
+			pod, err = findPod(cfg, cluster, opts.role, opts.namespace, opts.pod, opts.container)
+		}
+	}
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The history is a convenience, failing to write it must not fail the command
+	_ = history.Add(history.Entry{
+		Kind:      history.KindEKSPod,
+		Profile:   cfg.Profile,
+		Region:    cfg.Region,
+		Account:   cfg.Account,
+		Cluster:   cluster.Name,
+		Role:      opts.role,
+		Namespace: pod.Namespace,
+		Pod:       pod.Name,
+		Container: pod.Container,
+	})
+
+	return cluster, pod, nil
+}
+
+func (opts *podOptions) findLastTarget(cfg *config.Config) (*eks.Cluster, *eks.Pod, error) {
+	entry, err := history.Last(history.KindEKSPod, cfg.Profile, cfg.Region)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if opts.role == "" {
+		opts.role = entry.Role
+	}
+
+	clusters, err := eks.NewClusterFinder(cfg).Find(entry.Cluster)
+	if err != nil {
+		return nil, nil, fmt.Errorf("previous pod %s is no longer available: %w", entry.Target(), err)
+	}
+
+	finder, err := eks.NewPodFinder(cfg, &clusters[0], opts.role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pods, err := finder.FindByIdentifier(entry.Namespace, entry.Pod, entry.Container)
+	if err != nil {
+		return nil, nil, fmt.Errorf("previous pod %s is no longer available: %w", entry.Target(), err)
+	}
+
+	internal.PrintInfof("Reconnecting to %s", entry.Target())
+
+	return &clusters[0], &pods[0], nil
+}
+
 func findCluster(cfg *config.Config, clusterName string) (*eks.Cluster, error) {
 	finder := eks.NewClusterFinder(cfg)
 
@@ -44,31 +123,25 @@ func findCluster(cfg *config.Config, clusterName string) (*eks.Cluster, error) {
 	return &clusters[0], nil
 }
 
-// nolint: dupl // ok
 func chooseCluster(clusters []eks.Cluster) (*eks.Cluster, error) {
-	templates := &promptui.SelectTemplates{
-		Active:   fmt.Sprintf(`%s {{ .Name | cyan | bold }} ({{ .Version }})`, promptui.IconSelect),
-		Inactive: `   {{ .Name | cyan }} ({{ .Version }})`,
-		Selected: fmt.Sprintf(`%s {{ "Cluster" }}: {{ .Name | cyan }} ({{ .Version }})`, promptui.IconGood),
-	}
-
-	searcher := func(input string, index int) bool {
-		cluster := clusters[index]
-		name := strings.Replace(strings.ToLower(cluster.Name), " ", "", -1)
-		input = strings.Replace(strings.ToLower(input), " ", "", -1)
-
-		return strings.Contains(name, input)
+	items := make([]picker.Item, 0, len(clusters))
+
+	for _, c := range clusters {
+		items = append(items, picker.Item{
+			Key:         c.ARN,
+			Label:       c.Name,
+			Description: fmt.Sprintf("(%s)", c.Version),
+			Fields:      []string{c.ARN, c.Endpoint},
+		})
 	}
 
-	prompt := promptui.Select{
-		Label:     "Choose a cluster",
-		Items:     clusters,
-		Templates: templates,
-		Size:      15,
-		Searcher:  searcher,
+	p := &picker.Picker{
+		Label:    "Choose a cluster",
+		Kind:     "eks-cluster",
+		Selected: "Cluster",
 	}
 
-	i, _, err := prompt.Run()
+	i, err := p.Pick(items)
 	if err != nil {
 		return nil, err
 	}
@@ -111,31 +184,28 @@ func findPod(cfg *config.Config, cluster *eks.Cluster, role, namespace, podName,
 	return choosePod(pods)
 }
 
-// nolint: dupl // ok
 func choosePod(pods []eks.Pod) (*eks.Pod, error) {
-	templates := &promptui.SelectTemplates{
-		Active:   fmt.Sprintf(`%s {{ "pod" | cyan | bold }}/{{ .Name | cyan | bold }}/{{ .Container | cyan | bold }} ({{ .Namespace }})`, promptui.IconSelect),
-		Inactive: `   {{ "pod" | cyan }}/{{ .Name | cyan }}/{{ .Container | cyan }} ({{ .Namespace }})`,
-		Selected: fmt.Sprintf(`%s {{ "Pod" }}: {{ "pod" | cyan }}/{{ .Name | cyan }}/{{ .Container | cyan }} ({{ .Namespace }})`, promptui.IconGood),
-	}
-
-	searcher := func(input string, index int) bool {
-		pod := pods[index]
-		name := strings.Replace(strings.ToLower(pod.Name), " ", "", -1)
-		input = strings.Replace(strings.ToLower(input), " ", "", -1)
-
-		return strings.Contains(name, input)
+	items := make([]picker.Item, 0, len(pods))
+
+	for _, p := range pods {
+		items = append(items, picker.Item{
+			Key:         fmt.Sprintf("%s/%s/%s", p.Namespace, p.Name, p.Container),
+			Label:       fmt.Sprintf("pod/%s/%s", p.Name, p.Container),
+			Description: fmt.Sprintf("(%s)", p.Namespace),
+			Fields:      []string{p.Namespace},
+			Details: []picker.Detail{
+				{Name: "Ports", Value: formatPorts(p.ContainerPorts)},
+			},
+		})
 	}
 
-	prompt := promptui.Select{
-		Label:     "Choose a pod",
-		Items:     pods,
-		Templates: templates,
-		Size:      15,
-		Searcher:  searcher,
+	p := &picker.Picker{
+		Label:    "Choose a pod",
+		Kind:     "eks-pod",
+		Selected: "Pod",
 	}
 
-	i, _, err := prompt.Run()
+	i, err := p.Pick(items)
 	if err != nil {
 		return nil, err
 	}
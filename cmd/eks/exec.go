@@ -2,6 +2,7 @@ package eks
 
 import (
 	"context"
+	"fmt"
 
 	"github.com/hupe1980/gotoaws/internal"
 	"github.com/hupe1980/gotoaws/pkg/eks"
@@ -9,11 +10,8 @@ import (
 )
 
 type execOptions struct {
-	clusterName string
-	role        string
-	namespace   string
-	pod         string
-	container   string
+	podOptions
+	record string
 }
 
 func newExecCmd() *cobra.Command {
@@ -26,7 +24,9 @@ func newExecCmd() *cobra.Command {
 		Example: `gotoaws eks exec --cluster gotoaws --role cluster-admin
 gotoaws eks exec --cluster gotoaws --role cluster-admin -- /bin/sh
 gotoaws eks exec --cluster gotoaws --role cluster-admin -- cat /etc/passwd
-gotoaws eks exec --cluster gotoaws --role cluster-admin --namespace default --pod nginx -- date`,
+gotoaws eks exec --cluster gotoaws --role cluster-admin --namespace default --pod nginx -- date
+gotoaws eks exec --last
+gotoaws eks exec --cluster gotoaws --role cluster-admin --pod nginx --record exec.cast`,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			cfg, err := internal.NewConfigFromFlags()
 			if err != nil {
@@ -38,7 +38,7 @@ gotoaws eks exec --cluster gotoaws --role cluster-admin --namespace default --po
 				command = args[i:]
 			}
 
-			cluster, err := findCluster(cfg, opts.clusterName)
+			cluster, pod, err := opts.findTarget(cfg)
 			if err != nil {
 				return err
 			}
@@ -48,17 +48,24 @@ gotoaws eks exec --cluster gotoaws --role cluster-admin --namespace default --po
 				return err
 			}
 
-			pod, err := findPod(cfg, cluster, opts.role, opts.namespace, opts.pod, opts.container)
-			if err != nil {
-				return err
-			}
-
-			return client.Exec(context.Background(), &eks.ExecInput{
+			input := &eks.ExecInput{
 				Namespace: pod.Namespace,
 				PodName:   pod.Name,
 				Container: pod.Container,
 				Command:   command,
-			})
+			}
+
+			if opts.record != "" {
+				rec, err := internal.NewSessionRecorder(cfg, opts.record, fmt.Sprintf("%s/%s/%s/%s", cluster.Name, pod.Namespace, pod.Name, pod.Container))
+				if err != nil {
+					return err
+				}
+				defer rec.Close()
+
+				input.Recorder = rec.Recorder
+			}
+
+			return client.Exec(context.Background(), input)
 		},
 	}
 
@@ -66,7 +73,11 @@ gotoaws eks exec --cluster gotoaws --role cluster-admin --namespace default --po
 	cmd.Flags().StringVarP(&opts.role, "role", "", "", "arn or name of the role")
 	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "namespace of the pod (default \"all namespaces\"")
 	cmd.Flags().StringVarP(&opts.pod, "pod", "p", "", "name of the pod")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "reconnect to the previous pod of the profile and region")
 	cmd.Flags().StringVarP(&opts.container, "container", "c", "", "name of the container")
+	cmd.Flags().StringVarP(&opts.record, "record", "", "", "record the session to a file in asciicast v2 format")
+
+	cmd.MarkFlagsMutuallyExclusive("pod", "last")
 
 	return cmd
 }
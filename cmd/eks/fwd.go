@@ -1,24 +1,30 @@
 package eks
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"net"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/internal/backoff"
+	"github.com/hupe1980/gotoaws/pkg/config"
 	"github.com/hupe1980/gotoaws/pkg/eks"
 	"github.com/spf13/cobra"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/tools/portforward"
 )
 
 type fwdOptions struct {
-	clusterName string
-	role        string
-	namespace   string
-	pod         string
-	container   string
-	remotePort  int32
-	localPort   int32
+	podOptions
+	remotePort int32
+	localPort  int32
+	reconnect  bool
+	keepAlive  time.Duration
 }
 
 func newFwdCmd() *cobra.Command {
@@ -29,14 +35,15 @@ func newFwdCmd() *cobra.Command {
 		SilenceUsage:  true,
 		SilenceErrors: true,
 		Example: `gotoaws eks fwd --cluster gotoaws --role cluster-admin --pod nginx
-gotoaws eks fwd --cluster gotoaws --role cluster-admin --pod nginx --local 8000 --remote 80`,
+gotoaws eks fwd --cluster gotoaws --role cluster-admin --pod nginx --local 8000 --remote 80
+gotoaws eks fwd --cluster gotoaws --role cluster-admin --pod nginx --local 8000 --remote 80 --keepalive 30s`,
 		RunE: func(_ *cobra.Command, _ []string) error {
 			cfg, err := internal.NewConfigFromFlags()
 			if err != nil {
 				return err
 			}
 
-			cluster, err := findCluster(cfg, opts.clusterName)
+			cluster, pod, err := opts.findTarget(cfg)
 			if err != nil {
 				return err
 			}
@@ -46,11 +53,6 @@ gotoaws eks fwd --cluster gotoaws --role cluster-admin --pod nginx --local 8000
 				return err
 			}
 
-			pod, err := findPod(cfg, cluster, opts.role, opts.namespace, opts.pod, opts.container)
-			if err != nil {
-				return err
-			}
-
 			containerPort := opts.remotePort
 			if containerPort == 0 {
 				if len(pod.ContainerPorts) > 0 {
@@ -60,31 +62,89 @@ gotoaws eks fwd --cluster gotoaws --role cluster-admin --pod nginx --local 8000
 				}
 			}
 
-			stopCh := make(chan struct{}, 1)
-			readyCh := make(chan struct{})
+			localPort := opts.localPort
+			if localPort == 0 && opts.reconnect {
+				// Reconnects must restore the listener on the same port
+				if localPort, err = freePort(); err != nil {
+					return err
+				}
+			}
 
-			go func() {
-				<-readyCh
-				internal.PrintInfo("Port forwarding is ready")
-			}()
+			stopCh := make(chan struct{})
 
 			sigs := make(chan os.Signal, 1)
 			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
 			go func() {
 				<-sigs
-				if stopCh != nil {
-					close(stopCh)
-				}
+				close(stopCh)
 			}()
 
-			return client.RunPortForward(&eks.PortForwardInput{
-				Namespace:     pod.Namespace,
-				PodName:       pod.Name,
-				LocalPort:     opts.localPort,
-				ContainerPort: containerPort,
-				StopCh:        stopCh,
-				ReadyCh:       readyCh,
-			})
+			b := backoff.New()
+
+			for {
+				readyCh := make(chan struct{})
+
+				go func() {
+					select {
+					case <-readyCh:
+						internal.PrintInfo("Port forwarding is ready")
+					case <-stopCh:
+					}
+				}()
+
+				err = client.RunPortForward(&eks.PortForwardInput{
+					Namespace:     pod.Namespace,
+					PodName:       pod.Name,
+					LocalPort:     localPort,
+					ContainerPort: containerPort,
+					StopCh:        stopCh,
+					ReadyCh:       readyCh,
+					KeepAlive:     opts.keepAlive,
+				})
+
+				select {
+				case <-stopCh:
+					return nil
+				default:
+				}
+
+				select {
+				case <-readyCh:
+					// The forwarding was established, so start over with a short delay
+					b.Reset()
+				default:
+				}
+
+				if !opts.reconnect {
+					return err
+				}
+
+				if err == nil {
+					err = portforward.ErrLostConnectionToPod
+				}
+
+				for {
+					internal.PrintErrorf("Port forwarding dropped (%s), reconnecting (attempt %d)", err, b.Attempt()+1)
+
+					if err = waitBackoff(b, stopCh); err != nil {
+						return nil
+					}
+
+					// A new client comes with a fresh token
+					newClient, newPod, rerr := reconnect(cfg, cluster, opts.role, pod)
+					if rerr == nil {
+						client, pod = newClient, newPod
+						break
+					}
+
+					// A replacement pod gets a new name, so a deleted pod is not retried
+					if apierrors.IsNotFound(rerr) {
+						return fmt.Errorf("pod %s/%s no longer exists: %w", pod.Namespace, pod.Name, rerr)
+					}
+
+					err = rerr
+				}
+			}
 		},
 	}
 
@@ -92,8 +152,58 @@ gotoaws eks fwd --cluster gotoaws --role cluster-admin --pod nginx --local 8000
 	cmd.Flags().StringVarP(&opts.role, "role", "", "", "arn or name of the role")
 	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "namespace of the pod (default \"all namespaces\"")
 	cmd.Flags().StringVarP(&opts.pod, "pod", "p", "", "name of the pod")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "reconnect to the previous pod of the profile and region")
 	cmd.Flags().Int32VarP(&opts.remotePort, "remote", "r", 0, "the container port")
 	cmd.Flags().Int32VarP(&opts.localPort, "local", "l", 0, "the local port")
+	cmd.Flags().BoolVarP(&opts.reconnect, "reconnect", "", true, "restart dropped port forwardings")
+	cmd.Flags().DurationVarP(&opts.keepAlive, "keepalive", "", 0, "interval of pings to the api server (default 5s)")
+
+	cmd.MarkFlagsMutuallyExclusive("pod", "last")
 
 	return cmd
 }
+
+// reconnect looks up the pod again with a new client.
+func reconnect(cfg *config.Config, cluster *eks.Cluster, role string, pod *eks.Pod) (*eks.Kubeclient, *eks.Pod, error) {
+	client, err := eks.NewKubeclient(cfg, cluster, role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	finder, err := eks.NewPodFinder(cfg, cluster, role)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	pods, err := finder.FindByIdentifier(pod.Namespace, pod.Name, pod.Container)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return client, &pods[0], nil
+}
+
+func waitBackoff(b *backoff.Backoff, stopCh <-chan struct{}) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-stopCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return b.Wait(ctx)
+}
+
+func freePort() (int32, error) {
+	ln, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		return 0, err
+	}
+	defer ln.Close()
+
+	return int32(ln.Addr().(*net.TCPAddr).Port), nil
+}
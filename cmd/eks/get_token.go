@@ -35,12 +35,12 @@ func newGetTokenCmd() *cobra.Command {
 			if opts.role != "" {
 				t, err = gen.GetWithRole(opts.cluster, opts.role)
 				if err != nil {
-					return nil
+					return err
 				}
 			} else {
 				t, err = gen.Get(opts.cluster)
 				if err != nil {
-					return nil
+					return err
 				}
 			}
 
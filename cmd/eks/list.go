@@ -0,0 +1,119 @@
+package eks
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/eks"
+	"github.com/spf13/cobra"
+)
+
+func newListCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:          "list",
+		Short:        "List clusters and pods",
+		SilenceUsage: true,
+	}
+
+	cmd.AddCommand(
+		newListClustersCmd(),
+		newListPodsCmd(),
+	)
+
+	return cmd
+}
+
+func newListClustersCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "clusters",
+		Short: "List active clusters",
+		Example: `gotoaws eks list clusters
+gotoaws eks list clusters --output json`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.NewConfigFromFlags()
+			if err != nil {
+				return err
+			}
+
+			clusters, err := eks.NewClusterFinder(cfg).Find("")
+			if err != nil {
+				return err
+			}
+
+			table := &internal.Table{Header: []string{"NAME", "VERSION", "ENDPOINT"}}
+			for _, c := range clusters {
+				table.AddRow(c.Name, c.Version, c.Endpoint)
+			}
+
+			return internal.PrintOutput(clusters, table)
+		},
+	}
+
+	return cmd
+}
+
+type listPodsOptions struct {
+	clusterName   string
+	role          string
+	namespace     string
+	labelSelector string
+}
+
+func newListPodsCmd() *cobra.Command {
+	opts := &listPodsOptions{}
+	cmd := &cobra.Command{
+		Use:   "pods",
+		Short: "List running pods",
+		Example: `gotoaws eks list pods --cluster gotoaws --role cluster-admin
+gotoaws eks list pods --cluster gotoaws --role cluster-admin --namespace kube-system --output yaml`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			cfg, err := internal.NewConfigFromFlags()
+			if err != nil {
+				return err
+			}
+
+			cluster, err := findCluster(cfg, opts.clusterName)
+			if err != nil {
+				return err
+			}
+
+			finder, err := eks.NewPodFinder(cfg, cluster, opts.role)
+			if err != nil {
+				return err
+			}
+
+			pods, err := finder.Find(opts.namespace, opts.labelSelector)
+			if err != nil {
+				return err
+			}
+
+			table := &internal.Table{Header: []string{"NAMESPACE", "POD", "CONTAINER", "PORTS"}}
+			for _, p := range pods {
+				table.AddRow(p.Namespace, p.Name, p.Container, formatPorts(p.ContainerPorts))
+			}
+
+			return internal.PrintOutput(pods, table)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.clusterName, "cluster", "", "", "arn or name of the cluster")
+	cmd.Flags().StringVarP(&opts.role, "role", "", "", "arn or name of the role")
+	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "namespace of the pods (default \"all namespaces\")")
+	cmd.Flags().StringVarP(&opts.labelSelector, "selector", "l", "", "label selector to filter the pods")
+
+	return cmd
+}
+
+func formatPorts(ports []eks.ContainerPort) string {
+	s := make([]string, 0, len(ports))
+	for _, p := range ports {
+		s = append(s, fmt.Sprintf("%d/%s", p.Port, p.Protocol))
+	}
+
+	return strings.Join(s, ",")
+}
@@ -13,11 +13,7 @@ import (
 )
 
 type logsOptions struct {
-	clusterName string
-	role        string
-	namespace   string
-	pod         string
-	container   string
+	podOptions
 }
 
 func newLogsCmd() *cobra.Command {
@@ -35,7 +31,7 @@ gotoaws eks logs --cluster gotoaws --role cluster-admin --pod nginx --container
 				return err
 			}
 
-			cluster, err := findCluster(cfg, opts.clusterName)
+			cluster, pod, err := opts.findTarget(cfg)
 			if err != nil {
 				return err
 			}
@@ -45,11 +41,6 @@ gotoaws eks logs --cluster gotoaws --role cluster-admin --pod nginx --container
 				return err
 			}
 
-			pod, err := findPod(cfg, cluster, opts.role, opts.namespace, opts.pod, opts.container)
-			if err != nil {
-				return err
-			}
-
 			ctx, cancel := context.WithCancel(context.Background())
 
 			sigs := make(chan os.Signal, 1)
@@ -75,7 +66,10 @@ gotoaws eks logs --cluster gotoaws --role cluster-admin --pod nginx --container
 	cmd.Flags().StringVarP(&opts.role, "role", "", "", "arn or name of the role")
 	cmd.Flags().StringVarP(&opts.namespace, "namespace", "n", "", "namespace of the pod (default for finder \"all namespaces\"")
 	cmd.Flags().StringVarP(&opts.pod, "pod", "p", "", "name of the pod")
+	cmd.Flags().BoolVarP(&opts.last, "last", "", false, "reconnect to the previous pod of the profile and region")
 	cmd.Flags().StringVarP(&opts.container, "container", "c", "", "name of the container")
 
+	cmd.MarkFlagsMutuallyExclusive("pod", "last")
+
 	return cmd
 }
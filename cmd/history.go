@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/internal/history"
+	"github.com/spf13/cobra"
+)
+
+type historyOptions struct {
+	kind  string
+	clear bool
+}
+
+func newHistoryCmd() *cobra.Command {
+	opts := &historyOptions{}
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "List the previously connected targets",
+		Long: `List the previously connected targets, most recent first.
+Use --last on ec2 session|ssh|scp|fwd|run, ecs exec and eks exec|fwd|logs to reconnect
+to the most recent target of the profile and region.`,
+		Example: `gotoaws history
+gotoaws history --kind ec2-instance --output json
+gotoaws history --clear`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			if opts.clear {
+				if err := history.Clear(); err != nil {
+					return err
+				}
+
+				internal.PrintInfo("History cleared")
+
+				return nil
+			}
+
+			entries, err := history.List()
+			if err != nil {
+				return err
+			}
+
+			switch history.Kind(opts.kind) {
+			case "":
+			case history.KindEC2Instance, history.KindECSContainer, history.KindEKSPod:
+				filtered := []history.Entry{}
+
+				for _, e := range entries {
+					if e.Kind == history.Kind(opts.kind) {
+						filtered = append(filtered, e)
+					}
+				}
+
+				entries = filtered
+			default:
+				return fmt.Errorf("unsupported kind %q (ec2-instance|ecs-container|eks-pod)", opts.kind)
+			}
+
+			table := &internal.Table{Header: []string{"TIME", "KIND", "PROFILE", "REGION", "TARGET"}}
+			for _, e := range entries {
+				table.AddRow(e.Time.Local().Format(time.RFC3339), e.Kind, e.Profile, e.Region, e.Target())
+			}
+
+			return internal.PrintOutput(entries, table)
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.kind, "kind", "", "", "only list targets of a kind (ec2-instance|ecs-container|eks-pod)")
+	cmd.Flags().BoolVarP(&opts.clear, "clear", "", false, "remove all entries")
+
+	return cmd
+}
@@ -0,0 +1,279 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aws_config "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/internal/picker"
+	"github.com/hupe1980/gotoaws/pkg/sso"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const defaultSSORegion = "us-east-1"
+
+type loginOptions struct {
+	startURL     string
+	ssoRegion    string
+	ssoSession   string
+	account      string
+	role         string
+	writeProfile string
+	noBrowser    bool
+	force        bool
+}
+
+func newLoginCmd() *cobra.Command {
+	opts := &loginOptions{}
+	cmd := &cobra.Command{
+		Use:   "login",
+		Short: "Log in to AWS IAM Identity Center (SSO)",
+		Long: `Log in to AWS IAM Identity Center with the device authorization flow and choose an account
+and permission set. The token is cached in ~/.aws/sso/cache like aws sso login does, so
+profiles with sso settings work without the AWS CLI. Without --start-url the sso settings
+of --profile are used.`,
+		Example: `gotoaws login --start-url https://my-org.awsapps.com/start --sso-region eu-central-1 --write-profile dev
+gotoaws login --profile dev
+gotoaws login --profile dev --account prod --role ReadOnly --write-profile prod`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(_ *cobra.Command, _ []string) error {
+			preset, err := opts.resolve()
+			if err != nil {
+				return err
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-sigs
+				cancel()
+			}()
+
+			client := sso.NewClient(aws.Config{}, opts.ssoRegion)
+
+			token, err := client.Login(ctx, &sso.LoginInput{
+				StartURL:    opts.startURL,
+				SessionName: opts.ssoSession,
+				Force:       opts.force,
+				Authorize: func(url, code string) error {
+					internal.PrintInfof("Confirm the code %s at %s", code, url)
+
+					if !opts.noBrowser {
+						if err := internal.OpenBrowser(url); err != nil {
+							internal.PrintErrorf("Cannot open the browser: %v", err)
+						}
+					}
+
+					return nil
+				},
+			})
+			if err != nil {
+				return err
+			}
+
+			internal.PrintInfof("Logged in to %s until %s", opts.startURL, token.ExpiresAt.Local().Format(time.RFC3339))
+
+			// The profile already names the account and role
+			if preset && opts.writeProfile == "" {
+				return nil
+			}
+
+			account, err := chooseSSOAccount(ctx, client, token, opts.account)
+			if err != nil {
+				return err
+			}
+
+			role, err := chooseSSORole(ctx, client, token, account.ID, opts.role)
+			if err != nil {
+				return err
+			}
+
+			if opts.writeProfile == "" {
+				internal.PrintInfof("Use --write-profile to save %s (%s) with %s as profile", account.Name, account.ID, role.Name)
+				return nil
+			}
+
+			err = sso.WriteProfile(&sso.ProfileInput{
+				Profile:     opts.writeProfile,
+				SessionName: opts.ssoSession,
+				StartURL:    opts.startURL,
+				SSORegion:   opts.ssoRegion,
+				AccountID:   account.ID,
+				RoleName:    role.Name,
+				Region:      viper.GetString("region"),
+			})
+			if err != nil {
+				return err
+			}
+
+			internal.PrintInfof("Profile %s written to %s", opts.writeProfile, sso.ConfigFile())
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.startURL, "start-url", "", "", "start url of the portal, e.g. https://my-org.awsapps.com/start")
+	cmd.Flags().StringVarP(&opts.ssoRegion, "sso-region", "", "", "region of IAM Identity Center (default --region or us-east-1)")
+	cmd.Flags().StringVarP(&opts.ssoSession, "sso-session", "", "gotoaws", "name of the sso-session with --start-url")
+	cmd.Flags().StringVarP(&opts.account, "account", "", "", "id or name of the account")
+	cmd.Flags().StringVarP(&opts.role, "role", "", "", "name of the permission set")
+	cmd.Flags().StringVarP(&opts.writeProfile, "write-profile", "", "", "create or update the profile in the shared config file")
+	cmd.Flags().BoolVarP(&opts.noBrowser, "no-browser", "", false, "only print the verification url")
+	cmd.Flags().BoolVarP(&opts.force, "force", "", false, "log in even if the cached token is valid")
+
+	return cmd
+}
+
+// resolve reads the sso settings of the profile if no start url is given. It reports
+// whether the profile names the account and role.
+func (opts *loginOptions) resolve() (bool, error) {
+	preset := false
+
+	if opts.startURL == "" {
+		profile := viper.GetString("profile")
+		if profile == "" {
+			profile = "default"
+		}
+
+		sc, err := aws_config.LoadSharedConfigProfile(context.Background(), profile)
+		if err != nil {
+			return false, fmt.Errorf("cannot load profile %s: %w", profile, err)
+		}
+
+		if sc.SSOSession != nil {
+			opts.ssoSession = sc.SSOSession.Name
+			opts.startURL = sc.SSOSession.SSOStartURL
+			opts.ssoRegion = sc.SSOSession.SSORegion
+		} else {
+			// Legacy profiles cache the token by start url
+			opts.ssoSession = ""
+			opts.startURL = sc.SSOStartURL
+			opts.ssoRegion = sc.SSORegion
+		}
+
+		if opts.startURL == "" {
+			return false, fmt.Errorf("profile %s has no sso settings, use --start-url", profile)
+		}
+
+		if opts.account == "" && opts.role == "" && sc.SSOAccountID != "" && sc.SSORoleName != "" {
+			opts.account = sc.SSOAccountID
+			opts.role = sc.SSORoleName
+			preset = true
+		}
+	}
+
+	if opts.ssoRegion == "" {
+		opts.ssoRegion = viper.GetString("region")
+	}
+
+	if opts.ssoRegion == "" {
+		opts.ssoRegion = defaultSSORegion
+	}
+
+	return preset, nil
+}
+
+func chooseSSOAccount(ctx context.Context, client sso.Client, token *sso.Token, identifier string) (*sso.Account, error) {
+	accounts, err := client.Accounts(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(accounts) == 0 {
+		return nil, errors.New("no accounts assigned")
+	}
+
+	if identifier != "" {
+		for i, a := range accounts {
+			if a.ID == identifier || strings.EqualFold(a.Name, identifier) {
+				return &accounts[i], nil
+			}
+		}
+
+		return nil, fmt.Errorf("account %s is not assigned", identifier)
+	}
+
+	if len(accounts) == 1 {
+		return &accounts[0], nil
+	}
+
+	items := make([]picker.Item, 0, len(accounts))
+	for _, a := range accounts {
+		items = append(items, picker.Item{
+			Key:         a.ID,
+			Label:       a.Name,
+			Description: a.ID,
+			Fields:      []string{a.ID, a.Email},
+			Details:     []picker.Detail{{Name: "Email", Value: a.Email}},
+		})
+	}
+
+	p := &picker.Picker{
+		Label:    "Choose an account",
+		Kind:     "sso-account",
+		Selected: "Account",
+	}
+
+	i, err := p.Pick(items)
+	if err != nil {
+		return nil, err
+	}
+
+	return &accounts[i], nil
+}
+
+func chooseSSORole(ctx context.Context, client sso.Client, token *sso.Token, accountID, name string) (*sso.Role, error) {
+	roles, err := client.Roles(ctx, token, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roles) == 0 {
+		return nil, fmt.Errorf("no permission sets assigned in account %s", accountID)
+	}
+
+	if name != "" {
+		for i, r := range roles {
+			if strings.EqualFold(r.Name, name) {
+				return &roles[i], nil
+			}
+		}
+
+		return nil, fmt.Errorf("permission set %s is not assigned in account %s", name, accountID)
+	}
+
+	if len(roles) == 1 {
+		return &roles[0], nil
+	}
+
+	items := make([]picker.Item, 0, len(roles))
+	for _, r := range roles {
+		items = append(items, picker.Item{Key: r.AccountID + "/" + r.Name, Label: r.Name})
+	}
+
+	p := &picker.Picker{
+		Label:    "Choose a permission set",
+		Kind:     "sso-role",
+		Selected: "Permission set",
+	}
+
+	i, err := p.Pick(items)
+	if err != nil {
+		return nil, err
+	}
+
+	return &roles[i], nil
+}
@@ -0,0 +1,100 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hupe1980/gotoaws/internal"
+	"github.com/hupe1980/gotoaws/pkg/recording"
+	"github.com/spf13/cobra"
+)
+
+type replayOptions struct {
+	speed     float64
+	idleLimit time.Duration
+	info      bool
+}
+
+func newReplayCmd() *cobra.Command {
+	opts := &replayOptions{}
+	cmd := &cobra.Command{
+		Use:   "replay [file]",
+		Short: "Play back a recorded session",
+		Long: `Play back a session recorded with --record of ec2 session, ecs exec or eks exec.
+Recordings are asciicast v2 files and can be played with asciinema as well.`,
+		Example: `gotoaws replay session.cast
+gotoaws replay session.cast --speed 2 --idle-limit 1s
+gotoaws replay session.cast --info --output json`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		Args:          cobra.ExactArgs(1),
+		RunE: func(_ *cobra.Command, args []string) error {
+			f, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+
+			rec, err := recording.Read(f)
+			if err != nil {
+				return err
+			}
+
+			if opts.info {
+				return printRecordingInfo(rec)
+			}
+
+			if meta := rec.Header.Metadata; meta != nil {
+				internal.PrintInfof("Target: %s (%s, %s)", meta.Target, meta.Account, meta.Region)
+				internal.PrintInfof("Principal: %s", meta.Principal)
+				internal.PrintInfof("Recorded: %s (%s)", meta.Start.Local().Format(time.RFC3339), rec.Duration().Round(time.Second))
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			sigs := make(chan os.Signal, 1)
+			signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+			go func() {
+				<-sigs
+				cancel()
+			}()
+
+			err = recording.Play(ctx, rec, os.Stdout, &recording.PlayInput{
+				Speed:     opts.speed,
+				IdleLimit: opts.idleLimit,
+			})
+			if err != nil && ctx.Err() == nil {
+				return err
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Float64VarP(&opts.speed, "speed", "", 1, "playback speed factor")
+	cmd.Flags().DurationVarP(&opts.idleLimit, "idle-limit", "", 0, "limit pauses between outputs to this duration")
+	cmd.Flags().BoolVarP(&opts.info, "info", "", false, "print the metadata of the recording instead of playing it")
+
+	return cmd
+}
+
+func printRecordingInfo(rec *recording.Recording) error {
+	meta := rec.Header.Metadata
+	if meta == nil {
+		meta = &recording.Metadata{Start: time.Unix(rec.Header.Timestamp, 0)}
+	}
+
+	end := ""
+	if meta.End != nil {
+		end = meta.End.Local().Format(time.RFC3339)
+	}
+
+	table := &internal.Table{Header: []string{"TARGET", "SESSION", "ACCOUNT", "REGION", "PRINCIPAL", "START", "END"}}
+	table.AddRow(meta.Target, meta.SessionID, meta.Account, meta.Region, meta.Principal, meta.Start.Local().Format(time.RFC3339), end)
+
+	return internal.PrintOutput(meta, table)
+}
@@ -19,6 +19,11 @@ import (
 func Execute(version string) {
 	rootCmd := newRootCmd(version)
 	if err := rootCmd.Execute(); err != nil {
+		var exitErr *internal.ExitError
+		if errors.As(err, &exitErr) {
+			os.Exit(exitErr.Code)
+		}
+
 		var ae smithy.APIError
 		if errors.As(err, &ae) {
 			internal.PrintError(ae.ErrorMessage())
@@ -33,7 +38,7 @@ func Execute(version string) {
 func newRootCmd(version string) *cobra.Command {
 	var cfgFile string
 
-	cobra.OnInitialize(initConfig(cfgFile))
+	cobra.OnInitialize(initConfig(&cfgFile))
 
 	cmd := &cobra.Command{
 		Use:     "gotoaws",
@@ -42,7 +47,10 @@ func newRootCmd(version string) *cobra.Command {
 		Long: `gotoaws is an interactive CLI tool that you can use to connect to your AWS resources 
 (EC2, ECS container) using the AWS Systems Manager Session Manager. 
 It provides secure and auditable resource management without the need to open inbound 
-ports, maintain bastion hosts, or manage SSH keys.`,
+ports, maintain bastion hosts, or manage SSH keys.
+
+Sessions with KMS encryption (a KMS key in the Session Manager preferences) are not
+supported, use the session-manager-plugin of the AWS CLI for them.`,
 		SilenceErrors: true,
 	}
 
@@ -50,6 +58,13 @@ ports, maintain bastion hosts, or manage SSH keys.`,
 	cmd.PersistentFlags().String("region", "", "AWS region")
 	cmd.PersistentFlags().Duration("timeout", time.Second*15, "timeout for network requests")
 	cmd.PersistentFlags().Bool("silent", false, "run gotoaws without printing logs")
+	cmd.PersistentFlags().StringP("output", "o", "table", "output format of list and run results (json|yaml|table|text)")
+	cmd.PersistentFlags().String("role-arn", "", "arn of a role to assume with the credentials of the profile")
+	cmd.PersistentFlags().StringSlice("role-chain", nil, "arns of roles to assume in order after --role-arn")
+	cmd.PersistentFlags().String("external-id", "", "external id passed to the assumed roles")
+	cmd.PersistentFlags().String("session-name", "", "role session name shown in cloudtrail (default \"gotoaws\")")
+	cmd.PersistentFlags().Duration("duration", 0, "duration of the role sessions")
+	cmd.PersistentFlags().String("mfa-serial", "", "serial number or arn of the mfa device")
 	cmd.PersistentFlags().StringVar(&cfgFile, "config", "", "config file (default \"$HOME/.config/configstore/gotoaws.json\")")
 
 	cmd.AddCommand(
@@ -57,6 +72,11 @@ ports, maintain bastion hosts, or manage SSH keys.`,
 		ecs.NewECSCmd(),
 		eks.NewEKSCmd(),
 		config.NewConfigCmd(),
+		newConnectCmd(),
+		newHistoryCmd(),
+		newCacheCmd(),
+		newLoginCmd(),
+		newReplayCmd(),
 		newCompletionCmd(),
 	)
 
@@ -72,13 +92,21 @@ ports, maintain bastion hosts, or manage SSH keys.`,
 	err = viper.BindPFlag("silent", cmd.PersistentFlags().Lookup("silent"))
 	cobra.CheckErr(err)
 
+	err = viper.BindPFlag("output", cmd.PersistentFlags().Lookup("output"))
+	cobra.CheckErr(err)
+
+	for _, name := range []string{"role-arn", "role-chain", "external-id", "session-name", "duration", "mfa-serial"} {
+		err = viper.BindPFlag(name, cmd.PersistentFlags().Lookup(name))
+		cobra.CheckErr(err)
+	}
+
 	return cmd
 }
 
-func initConfig(cfgFile string) func() {
+func initConfig(cfgFile *string) func() {
 	return func() {
-		if cfgFile != "" {
-			viper.SetConfigFile(cfgFile)
+		if *cfgFile != "" {
+			viper.SetConfigFile(*cfgFile)
 		} else {
 			home, err := os.UserHomeDir()
 			cobra.CheckErr(err)
@@ -88,8 +116,13 @@ func initConfig(cfgFile string) func() {
 			viper.SetConfigName("gotoaws")
 		}
 
-		if err := viper.ReadInConfig(); err == nil {
+		if err := viper.ReadInConfig(); err == nil && !isCompletionRequest() {
 			internal.PrintInfof("Using config file: %s", viper.ConfigFileUsed())
 		}
 	}
 }
+
+// isCompletionRequest reports whether the shell asks for completions. Its output must not contain logs.
+func isCompletionRequest() bool {
+	return len(os.Args) > 1 && (os.Args[1] == cobra.ShellCompRequestCmd || os.Args[1] == cobra.ShellCompNoDescRequestCmd)
+}
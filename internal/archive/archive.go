@@ -0,0 +1,225 @@
+// Package archive streams files and directories as tar archives for copies
+// into and out of containers.
+package archive
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Write writes src as tar archive to w. The entries are named after name instead
+// of the base of src, so the copy can be renamed at the target. Links are stored
+// as links, other special files are skipped.
+func Write(w io.Writer, src, name string) error {
+	// A link given as source is copied as what it points to
+	root, err := filepath.EvalSymlinks(src)
+	if err != nil {
+		return err
+	}
+
+	tw := tar.NewWriter(w)
+
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+
+		var link string
+
+		switch {
+		case info.Mode()&os.ModeSymlink != 0:
+			if link, err = os.Readlink(p); err != nil {
+				return err
+			}
+		case !info.IsDir() && !info.Mode().IsRegular():
+			return nil
+		}
+
+		hdr, err := tar.FileInfoHeader(info, filepath.ToSlash(link))
+		if err != nil {
+			return err
+		}
+
+		hdr.Name = path.Join(name, filepath.ToSlash(rel))
+		if info.IsDir() {
+			hdr.Name += "/"
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		f, err := os.Open(p) // nolint: gosec // path is provided by the user
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = io.Copy(tw, f)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	return tw.Close()
+}
+
+// Extract extracts the archive of r to dst. All entries must be prefix or below it,
+// prefix itself is extracted as dst. Entries and links that would end up outside of
+// dst are rejected.
+func Extract(r io.Reader, prefix, dst string) error {
+	tr := tar.NewReader(r)
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		rel, err := entryPath(hdr.Name, prefix)
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dst, filepath.FromSlash(rel))
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := prepare(dst, target); err != nil {
+				return err
+			}
+
+			if err := os.MkdirAll(target, hdr.FileInfo().Mode().Perm()|0o700); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := prepare(dst, target); err != nil {
+				return err
+			}
+
+			if err := extractFile(tr, target, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		case tar.TypeSymlink:
+			if path.IsAbs(hdr.Linkname) || escapes(path.Join(path.Dir(rel), hdr.Linkname)) {
+				return fmt.Errorf("link %s to %s points outside of the target", hdr.Name, hdr.Linkname)
+			}
+
+			if err := prepare(dst, target); err != nil {
+				return err
+			}
+
+			if err := os.Symlink(hdr.Linkname, target); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// entryPath returns the slash separated path of the entry below prefix.
+func entryPath(name, prefix string) (string, error) {
+	if path.IsAbs(name) || escapes(name) {
+		return "", fmt.Errorf("entry %s points outside of the target", name)
+	}
+
+	name = path.Clean(name)
+
+	if name == prefix {
+		return ".", nil
+	}
+
+	if rel := strings.TrimPrefix(name, prefix+"/"); rel != name {
+		return rel, nil
+	}
+
+	return "", fmt.Errorf("unexpected entry %s in the archive", name)
+}
+
+func escapes(name string) bool {
+	name = path.Clean(name)
+	return name == ".." || strings.HasPrefix(name, "../")
+}
+
+// prepare creates the parent of target and removes an existing link at target, so
+// nothing is written through a link. Below dst the existing part of the parent must
+// resolve to a path within dst, which also covers links pointing to other links.
+func prepare(dst, target string) error {
+	parent := filepath.Dir(target)
+
+	if target != filepath.Clean(dst) {
+		if err := os.MkdirAll(dst, 0o755); err != nil {
+			return err
+		}
+
+		existing := parent
+		for _, err := os.Lstat(existing); err != nil; _, err = os.Lstat(existing) {
+			existing = filepath.Dir(existing)
+		}
+
+		if !within(dst, existing) {
+			return fmt.Errorf("%s points outside of the target", target)
+		}
+	}
+
+	if err := os.MkdirAll(parent, 0o755); err != nil {
+		return err
+	}
+
+	if info, err := os.Lstat(target); err == nil && info.Mode()&os.ModeSymlink != 0 {
+		return os.Remove(target)
+	}
+
+	return nil
+}
+
+// within reports whether p resolves to dst or a path below it.
+func within(dst, p string) bool {
+	realDst, err := filepath.EvalSymlinks(dst)
+	if err != nil {
+		return false
+	}
+
+	realPath, err := filepath.EvalSymlinks(p)
+	if err != nil {
+		return false
+	}
+
+	rel, err := filepath.Rel(realDst, realPath)
+
+	return err == nil && !escapes(filepath.ToSlash(rel))
+}
+
+func extractFile(r io.Reader, target string, perm os.FileMode) error {
+	f, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm) // nolint: gosec // target is checked by prepare
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(f, r); err != nil { // nolint: gosec // the size is bounded by the copied source
+		_ = f.Close()
+		return err
+	}
+
+	return f.Close()
+}
@@ -0,0 +1,157 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type entry struct {
+	name     string
+	typeflag byte
+	content  string
+	linkname string
+}
+
+func newArchive(t *testing.T, entries ...entry) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	tw := tar.NewWriter(&buf)
+
+	for _, e := range entries {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.linkname,
+			Mode:     0o644,
+			Size:     int64(len(e.content)),
+		}))
+
+		_, err := tw.Write([]byte(e.content))
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, tw.Close())
+
+	return &buf
+}
+
+func TestWriteExtract(t *testing.T) {
+	t.Run("directory", func(t *testing.T) {
+		src := filepath.Join(t.TempDir(), "app")
+		require.NoError(t, os.MkdirAll(filepath.Join(src, "bin"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(src, "bin", "run.sh"), []byte("run"), 0o750))
+		require.NoError(t, os.Symlink("bin/run.sh", filepath.Join(src, "run")))
+
+		var buf bytes.Buffer
+
+		require.NoError(t, Write(&buf, src, "release"))
+
+		dst := filepath.Join(t.TempDir(), "copy")
+		require.NoError(t, Extract(&buf, "release", dst))
+
+		b, err := os.ReadFile(filepath.Join(dst, "run"))
+		require.NoError(t, err)
+		assert.Equal(t, "run", string(b))
+
+		info, err := os.Stat(filepath.Join(dst, "bin", "run.sh"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o750), info.Mode().Perm())
+
+		link, err := os.Readlink(filepath.Join(dst, "run"))
+		require.NoError(t, err)
+		assert.Equal(t, "bin/run.sh", link)
+	})
+
+	t.Run("file", func(t *testing.T) {
+		src := filepath.Join(t.TempDir(), "notes.txt")
+		require.NoError(t, os.WriteFile(src, []byte("notes"), 0o600))
+
+		var buf bytes.Buffer
+
+		require.NoError(t, Write(&buf, src, "renamed.txt"))
+
+		dst := filepath.Join(t.TempDir(), "renamed.txt")
+		require.NoError(t, Extract(&buf, "renamed.txt", dst))
+
+		b, err := os.ReadFile(dst)
+		require.NoError(t, err)
+		assert.Equal(t, "notes", string(b))
+	})
+}
+
+func TestExtractRejects(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []entry
+		err     string
+	}{
+		{
+			name:    "parent",
+			entries: []entry{{name: "app/../../evil", typeflag: tar.TypeReg, content: "x"}},
+			err:     "points outside of the target",
+		},
+		{
+			name:    "absolute",
+			entries: []entry{{name: "/etc/evil", typeflag: tar.TypeReg, content: "x"}},
+			err:     "points outside of the target",
+		},
+		{
+			name:    "other prefix",
+			entries: []entry{{name: "other/file", typeflag: tar.TypeReg, content: "x"}},
+			err:     "unexpected entry",
+		},
+		{
+			name:    "absolute link",
+			entries: []entry{{name: "app/passwd", typeflag: tar.TypeSymlink, linkname: "/etc/passwd"}},
+			err:     "points outside of the target",
+		},
+		{
+			name:    "relative link",
+			entries: []entry{{name: "app/up", typeflag: tar.TypeSymlink, linkname: "../.."}},
+			err:     "points outside of the target",
+		},
+		{
+			name: "link chain",
+			entries: []entry{
+				{name: "app/x/", typeflag: tar.TypeDir},
+				{name: "app/x/b", typeflag: tar.TypeSymlink, linkname: ".."},
+				{name: "app/x/c", typeflag: tar.TypeSymlink, linkname: "b/.."},
+				{name: "app/x/c/evil", typeflag: tar.TypeReg, content: "x"},
+			},
+			err: "points outside of the target",
+		},
+		{
+			name: "through link",
+			entries: []entry{
+				{name: "app/x/b", typeflag: tar.TypeSymlink, linkname: ".."},
+				{name: "app/x/c", typeflag: tar.TypeSymlink, linkname: "b/.."},
+				{name: "app/x/c/dir/", typeflag: tar.TypeDir},
+			},
+			err: "points outside of the target",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parent := t.TempDir()
+			dst := filepath.Join(parent, "app")
+
+			err := Extract(newArchive(t, tt.entries...), "app", dst)
+			assert.ErrorContains(t, err, tt.err)
+
+			matches, _ := filepath.Glob(filepath.Join(parent, "*"))
+			for _, m := range matches {
+				assert.NotEqual(t, "evil", filepath.Base(m))
+				assert.NotEqual(t, "dir", filepath.Base(m))
+			}
+		})
+	}
+}
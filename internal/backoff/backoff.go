@@ -0,0 +1,70 @@
+// Package backoff computes exponentially growing delays between retries.
+package backoff
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	defaultInitial = time.Second
+	defaultMax     = 30 * time.Second
+	defaultFactor  = 2
+)
+
+// Backoff returns delays starting at Initial that grow by Factor up to Max.
+type Backoff struct {
+	Initial time.Duration
+	Max     time.Duration
+	Factor  float64
+
+	attempt int
+}
+
+// New returns a Backoff starting at 1s with a maximum of 30s.
+func New() *Backoff {
+	return &Backoff{
+		Initial: defaultInitial,
+		Max:     defaultMax,
+		Factor:  defaultFactor,
+	}
+}
+
+// Next returns the delay before the next attempt.
+func (b *Backoff) Next() time.Duration {
+	d := float64(b.Initial)
+	for i := 0; i < b.attempt; i++ {
+		d *= b.Factor
+		if d >= float64(b.Max) {
+			d = float64(b.Max)
+			break
+		}
+	}
+
+	b.attempt++
+
+	return time.Duration(d)
+}
+
+// Attempt returns the number of delays handed out since the last reset.
+func (b *Backoff) Attempt() int {
+	return b.attempt
+}
+
+// Reset starts over with the initial delay.
+func (b *Backoff) Reset() {
+	b.attempt = 0
+}
+
+// Wait sleeps for the next delay. It returns early with the context error if the context is done.
+func (b *Backoff) Wait(ctx context.Context) error {
+	t := time.NewTimer(b.Next())
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
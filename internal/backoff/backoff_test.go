@@ -0,0 +1,39 @@
+package backoff
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	t.Run("next", func(t *testing.T) {
+		b := New()
+
+		for _, expected := range []time.Duration{1, 2, 4, 8, 16, 30, 30} {
+			assert.Equal(t, expected*time.Second, b.Next())
+		}
+
+		assert.Equal(t, 7, b.Attempt())
+
+		b.Reset()
+		assert.Equal(t, time.Second, b.Next())
+	})
+
+	t.Run("wait canceled", func(t *testing.T) {
+		b := New()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		assert.ErrorIs(t, b.Wait(ctx), context.Canceled)
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		b := &Backoff{Initial: time.Millisecond, Max: time.Millisecond, Factor: 2}
+
+		assert.NoError(t, b.Wait(context.Background()))
+	})
+}
@@ -0,0 +1,22 @@
+package internal
+
+import (
+	"os/exec"
+	"runtime"
+)
+
+// OpenBrowser opens the url in the default browser of the user.
+func OpenBrowser(url string) error {
+	var cmd *exec.Cmd
+
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", url)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		cmd = exec.Command("xdg-open", url)
+	}
+
+	return cmd.Start()
+}
@@ -10,7 +10,7 @@ func NewConfigFromFlags() (*config.Config, error) {
 	region := viper.GetString("region")
 	timeout := viper.GetDuration("timeout")
 
-	cfg, err := config.NewConfig(profile, region, timeout)
+	cfg, err := config.NewConfigWithCredentials(profile, region, timeout, credentialsFromFlags())
 	if err != nil {
 		return nil, err
 	}
@@ -19,3 +19,27 @@ func NewConfigFromFlags() (*config.Config, error) {
 
 	return cfg, nil
 }
+
+// credentialsFromFlags returns nil if no role or mfa device is configured.
+func credentialsFromFlags() *config.CredentialsInput {
+	var roleARNs []string
+	if roleARN := viper.GetString("role-arn"); roleARN != "" {
+		roleARNs = append(roleARNs, roleARN)
+	}
+
+	roleARNs = append(roleARNs, viper.GetStringSlice("role-chain")...)
+
+	input := &config.CredentialsInput{
+		RoleARNs:    roleARNs,
+		ExternalID:  viper.GetString("external-id"),
+		SessionName: viper.GetString("session-name"),
+		Duration:    viper.GetDuration("duration"),
+		MFASerial:   viper.GetString("mfa-serial"),
+	}
+
+	if len(input.RoleARNs) == 0 && input.MFASerial == "" && input.SessionName == "" {
+		return nil
+	}
+
+	return input
+}
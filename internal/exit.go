@@ -0,0 +1,13 @@
+package internal
+
+import "fmt"
+
+// ExitError ends gotoaws with the code without printing an error, e.g. with the
+// exit status of a remote command whose output was already printed.
+type ExitError struct {
+	Code int
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/hupe1980/gotoaws/pkg/ec2"
+)
+
+// ParseForwardSpecs parses the specs of the --forward flag, or the single forwarding of
+// the --local, --host and --remote flags.
+func ParseForwardSpecs(specs []string, local, host, remote string) ([]*ec2.ForwardSpec, error) {
+	if len(specs) > 0 {
+		if local != "" || remote != "" || host != "" {
+			return nil, errors.New("--forward cannot be combined with --local, --remote and --host")
+		}
+
+		parsed := make([]*ec2.ForwardSpec, 0, len(specs))
+
+		for _, s := range specs {
+			spec, err := ec2.ParseForwardSpec(s)
+			if err != nil {
+				return nil, err
+			}
+
+			parsed = append(parsed, spec)
+		}
+
+		return parsed, nil
+	}
+
+	if local == "" || remote == "" {
+		return nil, errors.New("either --forward or --local and --remote are required")
+	}
+
+	s := fmt.Sprintf("%s:%s", local, remote)
+	if host != "" {
+		s = fmt.Sprintf("%s:%s:%s", local, host, remote)
+	}
+
+	spec, err := ec2.ParseForwardSpec(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return []*ec2.ForwardSpec{spec}, nil
+}
+
+// NewForwarder returns the forwarder of the spec, which reports its connections and
+// reconnects. Without a reconnect function the forwarder stops when the session drops.
+func NewForwarder(session ec2.Session, spec *ec2.ForwardSpec, keepAlive time.Duration, reconnect func() (ec2.Session, error)) *ec2.Forwarder {
+	input := &ec2.ForwarderInput{
+		LocalPort: spec.LocalPort,
+		KeepAlive: keepAlive,
+		OnConnOpen: func(c ec2.ConnStats) {
+			PrintInfof("[%s] Connection #%d from %s opened", spec, c.ID, c.RemoteAddr)
+		},
+		OnConnClose: func(c ec2.ConnStats) {
+			PrintInfof("[%s] Connection #%d from %s closed (sent %d bytes, received %d bytes)", spec, c.ID, c.RemoteAddr, c.BytesSent, c.BytesReceived)
+		},
+	}
+
+	if reconnect != nil {
+		input.OnReconnect = func(attempt int, err error) {
+			PrintErrorf("[%s] Session dropped (%s), reconnecting (attempt %d)", spec, err, attempt)
+		}
+		input.Reconnect = reconnect
+	}
+
+	return ec2.NewForwarder(session, input)
+}
+
+// RunForwarders runs all forwarders until the context is canceled. If one of
+// them fails, the others are torn down as well.
+func RunForwarders(ctx context.Context, specs []*ec2.ForwardSpec, forwarders []*ec2.Forwarder) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+
+	for i, fwd := range forwarders {
+		wg.Add(1)
+
+		go func(spec *ec2.ForwardSpec, fwd *ec2.Forwarder) {
+			defer wg.Done()
+
+			go func() {
+				select {
+				case <-fwd.Ready():
+					PrintInfof("Forwarding from %s -> %s", fwd.Addr(), remote(spec))
+				case <-ctx.Done():
+				}
+			}()
+
+			if err := fwd.Start(ctx); err != nil {
+				once.Do(func() {
+					firstErr = fmt.Errorf("%s: %w", spec, err)
+				})
+
+				cancel()
+
+				return
+			}
+
+			stats := fwd.Stats()
+			PrintInfof("[%s] Forwarded %d connections (sent %d bytes, received %d bytes)", spec, stats.TotalConns, stats.BytesSent, stats.BytesReceived)
+		}(specs[i], fwd)
+	}
+
+	wg.Wait()
+
+	return firstErr
+}
+
+func remote(spec *ec2.ForwardSpec) string {
+	if spec.RemoteHost == "" {
+		return spec.RemotePort
+	}
+
+	return fmt.Sprintf("%s:%s", spec.RemoteHost, spec.RemotePort)
+}
@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/hupe1980/gotoaws/pkg/ec2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseForwardSpecs(t *testing.T) {
+	t.Run("forward flags", func(t *testing.T) {
+		specs, err := ParseForwardSpecs([]string{"5432:db.internal:5432", "8080:80"}, "", "", "")
+		require.NoError(t, err)
+		assert.Equal(t, []*ec2.ForwardSpec{
+			{LocalPort: "5432", RemoteHost: "db.internal", RemotePort: "5432"},
+			{LocalPort: "8080", RemotePort: "80"},
+		}, specs)
+	})
+
+	t.Run("single forwarding", func(t *testing.T) {
+		specs, err := ParseForwardSpecs(nil, "5432", "db.internal", "5432")
+		require.NoError(t, err)
+		assert.Equal(t, []*ec2.ForwardSpec{{LocalPort: "5432", RemoteHost: "db.internal", RemotePort: "5432"}}, specs)
+	})
+
+	t.Run("combined", func(t *testing.T) {
+		_, err := ParseForwardSpecs([]string{"8080:80"}, "8080", "", "80")
+		assert.ErrorContains(t, err, "cannot be combined")
+	})
+
+	t.Run("missing", func(t *testing.T) {
+		_, err := ParseForwardSpecs(nil, "8080", "", "")
+		assert.ErrorContains(t, err, "required")
+	})
+}
@@ -0,0 +1,172 @@
+// Package history persists the targets resolved by the connect commands.
+package history
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const maxEntries = 100
+
+// Kind is the type of a resolved target.
+type Kind string
+
+const (
+	KindEC2Instance  Kind = "ec2-instance"
+	KindECSContainer Kind = "ecs-container"
+	KindEKSPod       Kind = "eks-pod"
+)
+
+// Entry is a resolved target.
+type Entry struct {
+	Time    time.Time `json:"time"`
+	Kind    Kind      `json:"kind"`
+	Profile string    `json:"profile,omitempty"`
+	Region  string    `json:"region,omitempty"`
+	Account string    `json:"account,omitempty"`
+
+	// InstanceID and Name of an ec2 instance
+	InstanceID string `json:"instanceId,omitempty"`
+	Name       string `json:"name,omitempty"`
+
+	// Cluster of an ecs task or eks pod
+	Cluster string `json:"cluster,omitempty"`
+
+	// Task of an ecs container
+	Task string `json:"task,omitempty"`
+
+	// Role, Namespace and Pod of an eks container
+	Role      string `json:"role,omitempty"`
+	Namespace string `json:"namespace,omitempty"`
+	Pod       string `json:"pod,omitempty"`
+
+	// Container of an ecs task or eks pod
+	Container string `json:"container,omitempty"`
+}
+
+// Target returns a short description of the target.
+func (e *Entry) Target() string {
+	switch e.Kind {
+	case KindEC2Instance:
+		if e.Name != "" {
+			return fmt.Sprintf("%s (%s)", e.Name, e.InstanceID)
+		}
+
+		return e.InstanceID
+	case KindECSContainer:
+		return fmt.Sprintf("%s/%s/%s", e.Cluster, e.Task, e.Container)
+	case KindEKSPod:
+		return fmt.Sprintf("%s/%s/%s/%s", e.Cluster, e.Namespace, e.Pod, e.Container)
+	default:
+		return ""
+	}
+}
+
+// historyFile returns the history file next to the config file.
+var historyFile = func() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config", "configstore", "gotoaws-history.json"), nil
+}
+
+// List returns all entries, most recent first.
+func List() ([]Entry, error) {
+	path, err := historyFile()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []Entry{}, nil
+		}
+
+		return nil, err
+	}
+
+	var entries []Entry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return nil, fmt.Errorf("invalid history file %s: %w", path, err)
+	}
+
+	return entries, nil
+}
+
+// Add puts the entry at the top of the history. An older entry for the same target is removed.
+func Add(entry Entry) error {
+	entries, err := List()
+	if err != nil {
+		// Start over instead of failing the command
+		entries = nil
+	}
+
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+
+	result := []Entry{entry}
+
+	for _, e := range entries {
+		if !e.sameTarget(&entry) && len(result) < maxEntries {
+			result = append(result, e)
+		}
+	}
+
+	return write(result)
+}
+
+// Last returns the most recent entry of a kind for the profile and region.
+func Last(kind Kind, profile, region string) (*Entry, error) {
+	entries, err := List()
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range entries {
+		e := &entries[i]
+		if e.Kind == kind && e.Profile == profile && e.Region == region {
+			return e, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no previous %s in history for profile %q and region %s", kind, profile, region)
+}
+
+// Clear removes all entries.
+func Clear() error {
+	return write([]Entry{})
+}
+
+func (e *Entry) sameTarget(o *Entry) bool {
+	a, b := *e, *o
+	a.Time, b.Time = time.Time{}, time.Time{}
+	a.Name, b.Name = "", ""
+
+	return a == b
+}
+
+func write(entries []Entry) error {
+	path, err := historyFile()
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(entries, "", " ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o600)
+}
@@ -0,0 +1,51 @@
+package history
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "history.json")
+
+	defer func(fn func() (string, error)) { historyFile = fn }(historyFile)
+	historyFile = func() (string, error) { return path, nil }
+
+	entries, err := List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+
+	_, err = Last(KindEC2Instance, "prod", "eu-central-1")
+	assert.Error(t, err)
+
+	t0 := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	require.NoError(t, Add(Entry{Time: t0, Kind: KindEC2Instance, Profile: "prod", Region: "eu-central-1", InstanceID: "i-1", Name: "web"}))
+	require.NoError(t, Add(Entry{Time: t0.Add(time.Minute), Kind: KindEC2Instance, Profile: "dev", Region: "eu-central-1", InstanceID: "i-2"}))
+	require.NoError(t, Add(Entry{Time: t0.Add(2 * time.Minute), Kind: KindECSContainer, Profile: "prod", Region: "eu-central-1", Cluster: "default", Task: "abc", Container: "app"}))
+
+	last, err := Last(KindEC2Instance, "prod", "eu-central-1")
+	require.NoError(t, err)
+	assert.Equal(t, "i-1", last.InstanceID)
+	assert.Equal(t, "web (i-1)", last.Target())
+
+	// reconnecting to the same target moves it to the top
+	require.NoError(t, Add(Entry{Time: t0.Add(3 * time.Minute), Kind: KindEC2Instance, Profile: "prod", Region: "eu-central-1", InstanceID: "i-1", Name: "web"}))
+
+	entries, err = List()
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, "i-1", entries[0].InstanceID)
+	assert.Equal(t, t0.Add(3*time.Minute), entries[0].Time)
+	assert.Equal(t, "default/abc/app", entries[1].Target())
+
+	require.NoError(t, Clear())
+
+	entries, err = List()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,105 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+	"sigs.k8s.io/yaml"
+)
+
+// OutputFormat is the format of the --output flag.
+type OutputFormat string
+
+const (
+	OutputJSON  OutputFormat = "json"
+	OutputYAML  OutputFormat = "yaml"
+	OutputTable OutputFormat = "table"
+	OutputText  OutputFormat = "text"
+)
+
+// Table is the tabular representation of a result for the table and text formats.
+type Table struct {
+	Header []string
+	Rows   [][]string
+}
+
+// AddRow appends a row of values formatted with %v.
+func (t *Table) AddRow(values ...interface{}) {
+	row := make([]string, len(values))
+	for i, v := range values {
+		row[i] = fmt.Sprint(v)
+	}
+
+	t.Rows = append(t.Rows, row)
+}
+
+func OutputFormatFromFlags() (OutputFormat, error) {
+	format := OutputFormat(strings.ToLower(viper.GetString("output")))
+
+	switch format {
+	case "":
+		return OutputTable, nil
+	case OutputJSON, OutputYAML, OutputTable, OutputText:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported output format %q (json|yaml|table|text)", format)
+	}
+}
+
+// IsStructuredOutput reports whether the output is meant for machines. Logs are
+// written to stderr in that case.
+func IsStructuredOutput() bool {
+	format, _ := OutputFormatFromFlags()
+	return format == OutputJSON || format == OutputYAML
+}
+
+// PrintOutput writes v to stdout in the format of the --output flag. The table is used for the table and text formats.
+func PrintOutput(v interface{}, table *Table) error {
+	format, err := OutputFormatFromFlags()
+	if err != nil {
+		return err
+	}
+
+	return printOutput(os.Stdout, format, v, table)
+}
+
+func printOutput(w io.Writer, format OutputFormat, v interface{}, table *Table) error {
+	switch format {
+	case OutputJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(v)
+	case OutputYAML:
+		b, err := yaml.Marshal(v)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(b)
+
+		return err
+	case OutputText:
+		for _, row := range table.Rows {
+			if _, err := fmt.Fprintln(w, strings.Join(row, "\t")); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	default:
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, strings.Join(table.Header, "\t"))
+
+		for _, row := range table.Rows {
+			fmt.Fprintln(tw, strings.Join(row, "\t"))
+		}
+
+		return tw.Flush()
+	}
+}
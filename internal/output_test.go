@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPrintOutput(t *testing.T) {
+	type item struct {
+		Name string `json:"name"`
+		ID   string `json:"id"`
+	}
+
+	items := []item{{Name: "web", ID: "i-1"}, {Name: "database", ID: "i-2"}}
+
+	table := &Table{Header: []string{"NAME", "ID"}}
+	for _, i := range items {
+		table.AddRow(i.Name, i.ID)
+	}
+
+	for format, expected := range map[OutputFormat]string{
+		OutputJSON:  "[\n  {\n    \"name\": \"web\",\n    \"id\": \"i-1\"\n  },\n  {\n    \"name\": \"database\",\n    \"id\": \"i-2\"\n  }\n]\n",
+		OutputYAML:  "- id: i-1\n  name: web\n- id: i-2\n  name: database\n",
+		OutputTable: "NAME      ID\nweb       i-1\ndatabase  i-2\n",
+		OutputText:  "web\ti-1\ndatabase\ti-2\n",
+	} {
+		var b bytes.Buffer
+
+		assert.NoError(t, printOutput(&b, format, items, table))
+		assert.Equal(t, expected, b.String(), format)
+	}
+}
@@ -0,0 +1,178 @@
+package picker
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+const (
+	scoreMatch        = 1
+	scoreConsecutive  = 5
+	scoreWordBoundary = 8
+	scoreStart        = 10
+	scoreLabel        = 2
+	penaltyGap        = 1
+	maxGapPenalty     = 10
+)
+
+// match scores a fuzzy match of pattern in text. The pattern matches if its runes appear
+// in text in order. Consecutive runes and runes at word boundaries score higher.
+// It returns the positions of the matched runes in text.
+func match(pattern, text string) (int, []int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(text)
+	lower := []rune(strings.ToLower(text))
+
+	if len(p) == 0 {
+		return 0, nil, true
+	}
+
+	if len(lower) != len(t) {
+		// Lowercasing changed the length, fall back to matching the original runes
+		lower = t
+	}
+
+	bestScore := -1
+
+	var bestPositions []int
+
+	for start := range lower {
+		if lower[start] != p[0] {
+			continue
+		}
+
+		score, positions, ok := matchFrom(p, t, lower, start)
+		if ok && score > bestScore {
+			bestScore = score
+			bestPositions = positions
+		}
+	}
+
+	if bestScore < 0 {
+		return 0, nil, false
+	}
+
+	return bestScore, bestPositions, true
+}
+
+func matchFrom(p, t, lower []rune, start int) (int, []int, bool) {
+	positions := make([]int, 0, len(p))
+	score := 0
+	j := 0
+
+	for i := start; i < len(lower) && j < len(p); i++ {
+		if lower[i] != p[j] {
+			continue
+		}
+
+		score += scoreMatch
+
+		switch {
+		case i == 0:
+			score += scoreStart
+		case isBoundary(t[i-1], t[i]):
+			score += scoreWordBoundary
+		}
+
+		if len(positions) > 0 {
+			gap := i - positions[len(positions)-1] - 1
+			if gap == 0 {
+				score += scoreConsecutive
+			} else {
+				score -= min(gap*penaltyGap, maxGapPenalty)
+			}
+		}
+
+		positions = append(positions, i)
+		j++
+	}
+
+	return score, positions, j == len(p)
+}
+
+func isBoundary(prev, cur rune) bool {
+	if !unicode.IsLetter(prev) && !unicode.IsDigit(prev) {
+		return true
+	}
+
+	return unicode.IsLower(prev) && unicode.IsUpper(cur)
+}
+
+type result struct {
+	index     int
+	score     int
+	positions []int
+}
+
+// rank returns the items matching all words of the query, best matches first.
+// Recently used items come first among equal scores and without a query.
+func rank(query string, items []Item, recent []string) []result {
+	recentRank := make(map[string]int, len(recent))
+	for i, key := range recent {
+		recentRank[key] = len(recent) - i
+	}
+
+	words := strings.Fields(query)
+	results := make([]result, 0, len(items))
+
+	for i, item := range items {
+		res, ok := matchItem(words, item)
+		if !ok {
+			continue
+		}
+
+		res.index = i
+		results = append(results, res)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.score != b.score {
+			return a.score > b.score
+		}
+
+		return recentRank[items[a.index].Key] > recentRank[items[b.index].Key]
+	})
+
+	return results
+}
+
+func matchItem(words []string, item Item) (result, bool) {
+	var res result
+
+	seen := make(map[int]bool)
+
+	for _, w := range words {
+		best, positions, ok := match(w, item.Label)
+		if ok {
+			best += scoreLabel
+		} else {
+			best = -1
+		}
+
+		for _, f := range item.Fields {
+			if score, _, ok := match(w, f); ok && score > best {
+				best = score
+				positions = nil
+			}
+		}
+
+		if best < 0 {
+			return result{}, false
+		}
+
+		res.score += best
+
+		for _, p := range positions {
+			if !seen[p] {
+				seen[p] = true
+				res.positions = append(res.positions, p)
+			}
+		}
+	}
+
+	sort.Ints(res.positions)
+
+	return res, true
+}
@@ -0,0 +1,75 @@
+package picker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	t.Run("subsequence", func(t *testing.T) {
+		_, positions, ok := match("wb", "web-server")
+		assert.True(t, ok)
+		assert.Equal(t, []int{0, 2}, positions)
+
+		_, _, ok = match("bw", "web-server")
+		assert.False(t, ok)
+	})
+
+	t.Run("case insensitive", func(t *testing.T) {
+		_, _, ok := match("PROD", "my-prod-db")
+		assert.True(t, ok)
+	})
+
+	t.Run("prefers consecutive and boundaries", func(t *testing.T) {
+		consecutive, _, _ := match("db", "prod-db")
+		scattered, _, _ := match("db", "dashboard")
+		assert.Greater(t, consecutive, scattered)
+
+		_, positions, _ := match("srv", "web-srv")
+		assert.Equal(t, []int{4, 5, 6}, positions)
+	})
+}
+
+func TestRank(t *testing.T) {
+	items := []Item{
+		{Key: "i-1", Label: "dashboard", Fields: []string{"i-1", "10.0.0.1"}},
+		{Key: "i-2", Label: "prod-db", Fields: []string{"i-2", "10.0.0.2", "Env=prod"}},
+		{Key: "i-3", Label: "staging-db", Fields: []string{"i-3", "10.0.1.3", "Env=staging"}},
+	}
+
+	t.Run("empty query keeps order with recent first", func(t *testing.T) {
+		results := rank("", items, []string{"i-3"})
+		assert.Equal(t, []int{2, 0, 1}, indexes(results))
+	})
+
+	t.Run("best match first", func(t *testing.T) {
+		results := rank("db", items, nil)
+		assert.Equal(t, []int{1, 2, 0}, indexes(results))
+		assert.Equal(t, []int{5, 6}, results[0].positions)
+	})
+
+	t.Run("searches fields", func(t *testing.T) {
+		results := rank("10.0.1", items, nil)
+		assert.Equal(t, []int{2, 0}, indexes(results))
+		assert.Empty(t, results[0].positions)
+	})
+
+	t.Run("all words must match", func(t *testing.T) {
+		results := rank("db env=prod", items, nil)
+		assert.Equal(t, []int{1}, indexes(results))
+	})
+
+	t.Run("no match", func(t *testing.T) {
+		assert.Empty(t, rank("xyz", items, nil))
+	})
+}
+
+func indexes(results []result) []int {
+	idx := make([]int, 0, len(results))
+	for _, r := range results {
+		idx = append(idx, r.index)
+	}
+
+	return idx
+}
@@ -0,0 +1,310 @@
+// Package picker implements the interactive fuzzy selection used by all commands.
+package picker
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/chzyer/readline"
+	"github.com/manifoldco/promptui"
+	"github.com/manifoldco/promptui/screenbuf"
+)
+
+const (
+	defaultSize = 15
+
+	hideCursor = "\033[?25l"
+	showCursor = "\033[?25h"
+)
+
+var (
+	faint     = promptui.Styler(promptui.FGFaint)
+	cyan      = promptui.Styler(promptui.FGCyan)
+	cyanBold  = promptui.Styler(promptui.FGCyan, promptui.FGBold)
+	highlight = promptui.Styler(promptui.FGYellow, promptui.FGBold, promptui.FGUnderline)
+)
+
+// Item is an entry of the picker.
+type Item struct {
+	// Key identifies the item in the recently used selections, e.g. the instance ID
+	Key string
+
+	// Label is the text shown in the list
+	Label string
+
+	// Description is shown faint next to the label
+	Description string
+
+	// Fields are searched in addition to the label, e.g. IDs, IPs and tags
+	Fields []string
+
+	// Details are shown below the list for the active item
+	Details []Detail
+}
+
+// Detail is a named value shown for the active item.
+type Detail struct {
+	Name  string
+	Value string
+}
+
+// Picker lets the user choose an item with a fuzzy search over all fields.
+type Picker struct {
+	// Label of the prompt, e.g. "Choose an instance"
+	Label string
+
+	// Kind names the recently used selections, e.g. "ec2-instance". Empty disables them.
+	Kind string
+
+	// Selected is printed with the chosen item, e.g. "Instance"
+	Selected string
+
+	// Size is the number of visible items
+	Size int
+
+	Stdin  io.ReadCloser
+	Stdout io.WriteCloser
+}
+
+// Pick shows the items and returns the index of the chosen one.
+func (p *Picker) Pick(items []Item) (int, error) {
+	if len(items) == 0 {
+		return 0, errors.New("nothing to choose from")
+	}
+
+	var recent []string
+	if p.Kind != "" {
+		recent = loadRecent(p.Kind)
+	}
+
+	index, err := p.run(items, recent)
+	if err != nil {
+		return 0, err
+	}
+
+	if p.Kind != "" {
+		// Remembering the selection is best effort
+		_ = addRecent(p.Kind, items[index].Key)
+	}
+
+	return index, nil
+}
+
+func (p *Picker) run(items []Item, recent []string) (int, error) {
+	size := p.Size
+	if size <= 0 {
+		size = defaultSize
+	}
+
+	stdout := p.Stdout
+	if stdout == nil {
+		// Keep stdout clean for the results of the command
+		stdout = os.Stderr
+	}
+
+	c := &readline.Config{
+		Stdin:  p.Stdin,
+		Stdout: stdout,
+	}
+
+	if err := c.Init(); err != nil {
+		return 0, err
+	}
+
+	c.Stdin = readline.NewCancelableStdin(c.Stdin)
+	c.HistoryLimit = -1
+	c.UniqueEditLine = true
+
+	rl, err := readline.NewEx(c)
+	if err != nil {
+		return 0, err
+	}
+
+	_, _ = rl.Write([]byte(hideCursor))
+	sb := screenbuf.New(rl)
+
+	s := &state{items: items, recent: recent, size: size}
+	s.search()
+
+	c.SetListener(func(line []rune, pos int, key rune) ([]rune, int, bool) {
+		switch key {
+		case readline.CharEnter:
+			return nil, 0, true
+		case readline.CharNext:
+			s.move(1)
+		case readline.CharPrev:
+			s.move(-1)
+		case readline.CharForward:
+			s.move(size)
+		case readline.CharBackward:
+			s.move(-size)
+		case readline.CharBackspace, readline.CharCtrlH:
+			if len(s.query) > 0 {
+				s.query = s.query[:len(s.query)-1]
+				s.search()
+			}
+		default:
+			if unicode.IsPrint(key) {
+				s.query = append(s.query, key)
+				s.search()
+			}
+		}
+
+		p.render(sb, s)
+
+		return nil, 0, true
+	})
+
+	for {
+		_, err = rl.Readline()
+		if err != nil {
+			break
+		}
+
+		if len(s.results) > 0 {
+			break
+		}
+	}
+
+	if err != nil {
+		if errors.Is(err, readline.ErrInterrupt) || err.Error() == "Interrupt" {
+			err = promptui.ErrInterrupt
+		} else if errors.Is(err, io.EOF) {
+			err = promptui.ErrEOF
+		}
+
+		sb.Reset()
+		_, _ = sb.WriteString("")
+		_ = sb.Flush()
+		_, _ = rl.Write([]byte(showCursor))
+		rl.Close()
+
+		return 0, err
+	}
+
+	item := items[s.results[s.cursor].index]
+
+	sb.Reset()
+	_, _ = sb.WriteString(fmt.Sprintf("%s %s: %s %s", promptui.IconGood, p.Selected, cyan(item.Label), item.Description))
+	_ = sb.Flush()
+	_, _ = rl.Write([]byte(showCursor))
+	rl.Close()
+
+	return s.results[s.cursor].index, nil
+}
+
+func (p *Picker) render(sb *screenbuf.ScreenBuf, s *state) {
+	_, _ = sb.WriteString(fmt.Sprintf("%s %s: %s", promptui.IconInitial, p.Label, string(s.query)))
+
+	end := min(s.start+s.size, len(s.results))
+
+	for i := s.start; i < end; i++ {
+		res := s.results[i]
+		item := s.items[res.index]
+
+		prefix := "  "
+		style := cyan
+
+		if i == s.cursor {
+			prefix = promptui.IconSelect + " "
+			style = cyanBold
+		}
+
+		line := prefix + highlightLabel(item.Label, res.positions, style)
+		if item.Description != "" {
+			line += " " + faint(item.Description)
+		}
+
+		if s.isRecent(item.Key) {
+			line += " " + faint("(recent)")
+		}
+
+		_, _ = sb.WriteString(line)
+	}
+
+	if len(s.results) == 0 {
+		_, _ = sb.WriteString("")
+		_, _ = sb.WriteString("No results")
+	} else {
+		details := s.items[s.results[s.cursor].index].Details
+		if len(details) > 0 {
+			_, _ = sb.WriteString("")
+		}
+
+		for _, d := range details {
+			_, _ = sb.WriteString(fmt.Sprintf("%s %s", faint(d.Name+":"), d.Value))
+		}
+	}
+
+	_ = sb.Flush()
+}
+
+func highlightLabel(label string, positions []int, style func(interface{}) string) string {
+	if len(positions) == 0 {
+		return style(label)
+	}
+
+	matched := make(map[int]bool, len(positions))
+	for _, p := range positions {
+		matched[p] = true
+	}
+
+	var b strings.Builder
+
+	for i, r := range []rune(label) {
+		if matched[i] {
+			b.WriteString(highlight(string(r)))
+		} else {
+			b.WriteString(style(string(r)))
+		}
+	}
+
+	return b.String()
+}
+
+// state is the search state of a running picker.
+type state struct {
+	items   []Item
+	recent  []string
+	size    int
+	query   []rune
+	results []result
+	cursor  int
+	start   int
+}
+
+func (s *state) search() {
+	s.results = rank(string(s.query), s.items, s.recent)
+	s.cursor = 0
+	s.start = 0
+}
+
+func (s *state) move(n int) {
+	if len(s.results) == 0 {
+		return
+	}
+
+	s.cursor = max(0, min(s.cursor+n, len(s.results)-1))
+
+	if s.cursor < s.start {
+		s.start = s.cursor
+	}
+
+	if s.cursor >= s.start+s.size {
+		s.start = s.cursor - s.size + 1
+	}
+}
+
+func (s *state) isRecent(key string) bool {
+	for _, k := range s.recent {
+		if k == key {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,87 @@
+package picker
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+const maxRecent = 10
+
+// recentFile returns the file of the recently used selections next to the config file.
+var recentFile = func() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config", "configstore", "gotoaws-recent.json"), nil
+}
+
+func readRecent() (map[string][]string, error) {
+	path, err := recentFile()
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return map[string][]string{}, nil
+		}
+
+		return nil, err
+	}
+
+	recent := map[string][]string{}
+	if err := json.Unmarshal(b, &recent); err != nil {
+		return nil, err
+	}
+
+	return recent, nil
+}
+
+// loadRecent returns the keys of the recently used selections of a kind, most recent first.
+func loadRecent(kind string) []string {
+	recent, err := readRecent()
+	if err != nil {
+		return nil
+	}
+
+	return recent[kind]
+}
+
+// addRecent moves key to the top of the recently used selections of a kind.
+func addRecent(kind, key string) error {
+	recent, err := readRecent()
+	if err != nil {
+		recent = map[string][]string{}
+	}
+
+	keys := []string{key}
+
+	for _, k := range recent[kind] {
+		if k != key && len(keys) < maxRecent {
+			keys = append(keys, k)
+		}
+	}
+
+	recent[kind] = keys
+
+	b, err := json.MarshalIndent(recent, "", " ")
+	if err != nil {
+		return err
+	}
+
+	path, err := recentFile()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o600)
+}
@@ -0,0 +1,33 @@
+package picker
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "recent.json")
+
+	defer func(fn func() (string, error)) { recentFile = fn }(recentFile)
+	recentFile = func() (string, error) { return path, nil }
+
+	assert.Empty(t, loadRecent("ec2-instance"))
+
+	assert.NoError(t, addRecent("ec2-instance", "i-1"))
+	assert.NoError(t, addRecent("ec2-instance", "i-2"))
+	assert.NoError(t, addRecent("ec2-instance", "i-1"))
+	assert.NoError(t, addRecent("eks-pod", "default/nginx/nginx"))
+
+	assert.Equal(t, []string{"i-1", "i-2"}, loadRecent("ec2-instance"))
+	assert.Equal(t, []string{"default/nginx/nginx"}, loadRecent("eks-pod"))
+
+	for i := 0; i < 20; i++ {
+		assert.NoError(t, addRecent("ec2-instance", fmt.Sprintf("i-%d", i)))
+	}
+
+	assert.Len(t, loadRecent("ec2-instance"), maxRecent)
+	assert.Equal(t, "i-19", loadRecent("ec2-instance")[0])
+}
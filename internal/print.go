@@ -2,6 +2,7 @@ package internal
 
 import (
 	"fmt"
+	"io"
 	"os"
 
 	"github.com/manifoldco/promptui"
@@ -11,14 +12,14 @@ import (
 func PrintInfo(a ...interface{}) {
 	silent := viper.GetBool("silent")
 	if !silent {
-		fmt.Fprintf(os.Stdout, "%s %s\n", promptui.IconGood, fmt.Sprint(a...))
+		fmt.Fprintf(infoWriter(), "%s %s\n", promptui.IconGood, fmt.Sprint(a...))
 	}
 }
 
 func PrintInfof(format string, a ...interface{}) {
 	silent := viper.GetBool("silent")
 	if !silent {
-		fmt.Fprintf(os.Stdout, "%s %s\n", promptui.IconGood, fmt.Sprintf(format, a...))
+		fmt.Fprintf(infoWriter(), "%s %s\n", promptui.IconGood, fmt.Sprintf(format, a...))
 	}
 }
 
@@ -29,3 +30,12 @@ func PrintError(a ...interface{}) {
 func PrintErrorf(format string, a ...interface{}) {
 	fmt.Fprintf(os.Stderr, "%s %s\n", promptui.IconBad, fmt.Sprintf(format, a...))
 }
+
+// infoWriter keeps stdout clean for json and yaml output.
+func infoWriter() io.Writer {
+	if IsStructuredOutput() {
+		return os.Stderr
+	}
+
+	return os.Stdout
+}
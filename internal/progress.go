@@ -0,0 +1,112 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"golang.org/x/term"
+)
+
+// ProgressBar renders the progress and throughput of a file transfer.
+type ProgressBar struct {
+	w      io.Writer
+	name   string
+	total  int64
+	offset int64
+	start  time.Time
+	drawn  time.Time
+	now    func() time.Time
+}
+
+// NewProgressBar returns a progress bar on stderr, or nil if stderr is no terminal or
+// gotoaws runs silent. The offset is the part of a resumed transfer that is not counted
+// in the throughput. All methods can be called on nil.
+func NewProgressBar(name string, total, offset int64) *ProgressBar {
+	if viper.GetBool("silent") || !term.IsTerminal(int(os.Stderr.Fd())) {
+		return nil
+	}
+
+	return newProgressBar(os.Stderr, name, total, offset, time.Now)
+}
+
+func newProgressBar(w io.Writer, name string, total, offset int64, now func() time.Time) *ProgressBar {
+	return &ProgressBar{
+		w:      w,
+		name:   name,
+		total:  total,
+		offset: offset,
+		start:  now(),
+		now:    now,
+	}
+}
+
+// Set updates the transferred bytes. The bar is redrawn at most ten times per second.
+func (b *ProgressBar) Set(n int64) {
+	if b == nil {
+		return
+	}
+
+	if now := b.now(); now.Sub(b.drawn) >= 100*time.Millisecond {
+		b.drawn = now
+		b.draw(n)
+	}
+}
+
+// Finish draws the completed transfer and ends the line.
+func (b *ProgressBar) Finish() {
+	if b == nil {
+		return
+	}
+
+	b.draw(b.total)
+	fmt.Fprintln(b.w)
+}
+
+// Stop ends the line of an incomplete transfer.
+func (b *ProgressBar) Stop() {
+	if b == nil {
+		return
+	}
+
+	fmt.Fprintln(b.w)
+}
+
+func (b *ProgressBar) draw(n int64) {
+	const width = 20
+
+	percent := int64(100)
+	if b.total > 0 {
+		percent = n * 100 / b.total
+	}
+
+	done := int(percent * width / 100)
+	bar := strings.Repeat("=", done) + strings.Repeat(" ", width-done)
+
+	var rate int64
+	if elapsed := b.now().Sub(b.start).Seconds(); elapsed > 0 {
+		rate = int64(float64(n-b.offset) / elapsed)
+	}
+
+	fmt.Fprintf(b.w, "\r%s [%s] %3d%% %s %s/s\x1b[K", b.name, bar, percent, FormatBytes(n), FormatBytes(rate))
+}
+
+// FormatBytes formats n with a binary unit, e.g. 1.5 MiB.
+func FormatBytes(n int64) string {
+	const unit = 1024
+
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatBytes(t *testing.T) {
+	assert.Equal(t, "0 B", FormatBytes(0))
+	assert.Equal(t, "1023 B", FormatBytes(1023))
+	assert.Equal(t, "1.0 KiB", FormatBytes(1024))
+	assert.Equal(t, "1.5 MiB", FormatBytes(1536*1024))
+	assert.Equal(t, "2.0 GiB", FormatBytes(2<<30))
+}
+
+func TestProgressBar(t *testing.T) {
+	var buf bytes.Buffer
+
+	now := time.Unix(0, 0)
+	b := newProgressBar(&buf, "data.bin", 4096, 1024, func() time.Time { return now })
+
+	now = now.Add(time.Second)
+	b.Set(2048)
+	assert.Equal(t, "\rdata.bin [==========          ]  50% 2.0 KiB 1.0 KiB/s\x1b[K", buf.String())
+
+	// Updates within 100ms are not drawn
+	buf.Reset()
+	b.Set(3072)
+	assert.Empty(t, buf.String())
+
+	now = now.Add(time.Second)
+	b.Finish()
+	assert.Equal(t, "\rdata.bin [====================] 100% 4.0 KiB 1.5 KiB/s\x1b[K\n", buf.String())
+
+	var nilBar *ProgressBar
+	nilBar.Set(1)
+	nilBar.Finish()
+}
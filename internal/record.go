@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"os"
+
+	"github.com/hupe1980/gotoaws/pkg/config"
+	"github.com/hupe1980/gotoaws/pkg/recording"
+)
+
+// SessionRecorder records a terminal session to a file.
+type SessionRecorder struct {
+	*recording.Recorder
+	file *os.File
+}
+
+// NewSessionRecorder creates the recording file. The account, region and principal are taken from the config.
+func NewSessionRecorder(cfg *config.Config, path, target string) (*SessionRecorder, error) {
+	// Recordings may contain secrets, so they are only readable by the user
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600) // nolint: gosec // path is provided by the user
+	if err != nil {
+		return nil, err
+	}
+
+	rec := recording.NewRecorder(f, recording.Metadata{
+		Account:   cfg.Account,
+		Region:    cfg.Region,
+		Principal: cfg.Principal,
+		Target:    target,
+	})
+
+	return &SessionRecorder{Recorder: rec, file: f}, nil
+}
+
+// Close ends the recording and closes the file.
+func (r *SessionRecorder) Close() error {
+	if err := r.Recorder.Close(); err != nil {
+		_ = r.file.Close()
+		return err
+	}
+
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+
+	PrintInfof("Session recorded to %s", r.file.Name())
+
+	return nil
+}
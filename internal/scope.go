@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/hupe1980/gotoaws/pkg/config"
+	"github.com/hupe1980/gotoaws/pkg/ec2"
+)
+
+// maxConcurrentScopes limits the requests running at the same time during a discovery.
+const maxConcurrentScopes = 10
+
+// ScopeInput selects the accounts and regions of a discovery.
+type ScopeInput struct {
+	// Profiles replace the base config
+	Profiles []string
+
+	// RoleARNs are assumed with the credentials of the base config
+	RoleARNs []string
+
+	// Region overrides the region of the profiles
+	Region string
+
+	// AllRegions searches every region enabled for an account
+	AllRegions bool
+}
+
+// IsMulti reports whether more than the base config is selected.
+func (input *ScopeInput) IsMulti() bool {
+	return len(input.Profiles) > 0 || len(input.RoleARNs) > 0 || input.AllRegions
+}
+
+// NewScope returns a config for every combination of account and region. Only the base config
+// is returned if the input selects nothing else. Duplicate accounts and regions are removed.
+func NewScope(base *config.Config, input *ScopeInput) ([]*config.Config, error) {
+	if !input.IsMulti() {
+		return []*config.Config{base}, nil
+	}
+
+	accounts := []*config.Config{base}
+
+	if len(input.Profiles) > 0 || len(input.RoleARNs) > 0 {
+		var err error
+
+		if accounts, err = loadAccounts(base, input); err != nil {
+			return nil, err
+		}
+	}
+
+	if !input.AllRegions {
+		return dedupe(accounts), nil
+	}
+
+	results := Discover(accounts, func(cfg *config.Config) ([]*config.Config, error) {
+		regions, err := ec2.Regions(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		configs := make([]*config.Config, 0, len(regions))
+		for _, r := range regions {
+			configs = append(configs, cfg.WithRegion(r))
+		}
+
+		return configs, nil
+	})
+
+	var scope []*config.Config
+
+	for _, r := range results {
+		if r.Err != nil {
+			return nil, fmt.Errorf("cannot list regions of %s: %w", r.Config.Account, r.Err)
+		}
+
+		scope = append(scope, r.Items...)
+	}
+
+	return dedupe(scope), nil
+}
+
+func loadAccounts(base *config.Config, input *ScopeInput) ([]*config.Config, error) {
+	type source struct {
+		profile string
+		roleARN string
+	}
+
+	sources := make([]source, 0, len(input.Profiles)+len(input.RoleARNs))
+	for _, p := range input.Profiles {
+		sources = append(sources, source{profile: p})
+	}
+
+	for _, arn := range input.RoleARNs {
+		sources = append(sources, source{roleARN: arn})
+	}
+
+	configs := make([]*config.Config, len(sources))
+	errs := make([]error, len(sources))
+
+	var wg sync.WaitGroup
+
+	for i, src := range sources {
+		wg.Add(1)
+
+		go func(i int, src source) {
+			defer wg.Done()
+
+			if src.roleARN != "" {
+				configs[i], errs[i] = base.AssumeRole(src.roleARN)
+				if errs[i] != nil {
+					errs[i] = fmt.Errorf("cannot assume role %s: %w", src.roleARN, errs[i])
+				}
+
+				return
+			}
+
+			configs[i], errs[i] = config.NewConfig(src.profile, input.Region, base.Timeout)
+			if errs[i] != nil {
+				errs[i] = fmt.Errorf("cannot load profile %s: %w", src.profile, errs[i])
+			}
+		}(i, src)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return configs, nil
+}
+
+func dedupe(configs []*config.Config) []*config.Config {
+	seen := make(map[string]bool, len(configs))
+	result := make([]*config.Config, 0, len(configs))
+
+	for _, cfg := range configs {
+		key := cfg.Account + "/" + cfg.Region
+		if !seen[key] {
+			seen[key] = true
+			result = append(result, cfg)
+		}
+	}
+
+	return result
+}
+
+// ScopeResult is the result of a discovery in one account and region.
+type ScopeResult[T any] struct {
+	Config *config.Config
+	Items  []T
+	Err    error
+}
+
+// Discover runs fn concurrently for every config of the scope. The results are in the order of the scope.
+func Discover[T any](scope []*config.Config, fn func(cfg *config.Config) ([]T, error)) []ScopeResult[T] {
+	results := make([]ScopeResult[T], len(scope))
+	sem := make(chan struct{}, maxConcurrentScopes)
+
+	var wg sync.WaitGroup
+
+	for i, cfg := range scope {
+		wg.Add(1)
+
+		go func(i int, cfg *config.Config) {
+			defer wg.Done()
+
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			items, err := fn(cfg)
+			results[i] = ScopeResult[T]{Config: cfg, Items: items, Err: err}
+		}(i, cfg)
+	}
+
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hupe1980/gotoaws/pkg/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewScope(t *testing.T) {
+	base := &config.Config{Account: "111111111111", Region: "eu-central-1"}
+
+	scope, err := NewScope(base, &ScopeInput{})
+	require.NoError(t, err)
+	assert.Equal(t, []*config.Config{base}, scope)
+}
+
+func TestDiscover(t *testing.T) {
+	scope := []*config.Config{
+		{Account: "111111111111", Region: "eu-central-1"},
+		{Account: "111111111111", Region: "us-east-1"},
+		{Account: "222222222222", Region: "eu-central-1"},
+	}
+
+	errDenied := errors.New("access denied")
+
+	results := Discover(scope, func(cfg *config.Config) ([]string, error) {
+		if cfg.Account == "222222222222" {
+			return nil, errDenied
+		}
+
+		return []string{cfg.Region}, nil
+	})
+
+	require.Len(t, results, 3)
+	assert.Equal(t, []string{"eu-central-1"}, results[0].Items)
+	assert.Equal(t, []string{"us-east-1"}, results[1].Items)
+	assert.Same(t, scope[2], results[2].Config)
+	assert.ErrorIs(t, results[2].Err, errDenied)
+}
+
+func TestDedupe(t *testing.T) {
+	a := &config.Config{Account: "111111111111", Region: "eu-central-1", Profile: "a"}
+	b := &config.Config{Account: "111111111111", Region: "eu-central-1", Profile: "b"}
+	c := &config.Config{Account: "111111111111", Region: "us-east-1", Profile: "b"}
+
+	assert.Equal(t, []*config.Config{a, c}, dedupe([]*config.Config{a, b, c}))
+}
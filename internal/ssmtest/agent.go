@@ -0,0 +1,267 @@
+// Package ssmtest provides an in-process stand-in for the agent side of a
+// Session Manager data channel.
+package ssmtest
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+	"github.com/hupe1980/gotoaws/pkg/ssm"
+)
+
+// Agent accepts data channels and hands the session stream of each to the handler.
+type Agent struct {
+	agentVersion string
+	sessionType  string
+	handler      func(stream *Stream)
+	server       *httptest.Server
+}
+
+// NewAgent starts an agent that reports the given version and session type during the handshake.
+func NewAgent(agentVersion, sessionType string, handler func(stream *Stream)) *Agent {
+	a := &Agent{
+		agentVersion: agentVersion,
+		sessionType:  sessionType,
+		handler:      handler,
+	}
+
+	a.server = httptest.NewServer(http.HandlerFunc(a.serve))
+
+	return a
+}
+
+// URL returns the stream url of the agent.
+func (a *Agent) URL() string {
+	return "ws" + strings.TrimPrefix(a.server.URL, "http")
+}
+
+// Close shuts down the agent.
+func (a *Agent) Close() {
+	a.server.Close()
+}
+
+func (a *Agent) serve(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	open := map[string]string{}
+	if err := conn.ReadJSON(&open); err != nil {
+		return
+	}
+
+	s := &Stream{
+		conn:      conn,
+		data:      make(chan []byte, 256),
+		flags:     make(chan ssm.Flag, 16),
+		handshake: make(chan struct{}),
+	}
+
+	go s.readLoop()
+
+	handshake, _ := json.Marshal(map[string]interface{}{
+		"AgentVersion": a.agentVersion,
+		"RequestedClientActions": []map[string]interface{}{{
+			"ActionType": "SessionType",
+			"ActionParameters": map[string]interface{}{
+				"SessionType": a.sessionType,
+				"Properties":  map[string]string{},
+			},
+		}},
+	})
+
+	if err := s.send(ssm.PayloadTypeHandshakeRequest, handshake); err != nil {
+		return
+	}
+
+	select {
+	case <-s.handshake:
+	case <-time.After(5 * time.Second):
+		return
+	}
+
+	if err := s.send(ssm.PayloadTypeHandshakeComplete, []byte(`{"HandshakeTimeToComplete":1000000}`)); err != nil {
+		return
+	}
+
+	a.handler(s)
+
+	_ = s.Close()
+}
+
+// Stream is the agent view of the session data. Reads return the input of the
+// client, writes are sent as output to the client.
+type Stream struct {
+	conn *websocket.Conn
+
+	mu       sync.Mutex
+	sequence int64
+
+	data      chan []byte
+	buf       []byte
+	flags     chan ssm.Flag
+	handshake chan struct{}
+	closeOnce sync.Once
+}
+
+// Flags returns the flags sent by the client.
+func (s *Stream) Flags() <-chan ssm.Flag {
+	return s.flags
+}
+
+func (s *Stream) Read(p []byte) (int, error) {
+	if len(s.buf) == 0 {
+		b, ok := <-s.data
+		if !ok {
+			return 0, io.EOF
+		}
+
+		s.buf = b
+	}
+
+	n := copy(p, s.buf)
+	s.buf = s.buf[n:]
+
+	return n, nil
+}
+
+func (s *Stream) Write(p []byte) (int, error) {
+	b := make([]byte, len(p))
+	copy(b, p)
+
+	if err := s.send(ssm.PayloadTypeOutput, b); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close sends channel_closed to the client and closes the connection.
+func (s *Stream) Close() error {
+	var err error
+
+	s.closeOnce.Do(func() {
+		_ = s.write(&ssm.ClientMessage{
+			MessageType:   ssm.MessageTypeChannelClosed,
+			SchemaVersion: 1,
+			CreatedDate:   time.Now(),
+			MessageID:     uuid.New(),
+			Payload:       []byte(`{"Output":""}`),
+		})
+
+		err = s.conn.Close()
+	})
+
+	return err
+}
+
+func (s *Stream) send(payloadType ssm.PayloadType, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	msg := &ssm.ClientMessage{
+		MessageType:    ssm.MessageTypeOutputStreamData,
+		SchemaVersion:  1,
+		CreatedDate:    time.Now(),
+		SequenceNumber: s.sequence,
+		MessageID:      uuid.New(),
+		PayloadType:    payloadType,
+		Payload:        payload,
+	}
+
+	b, err := msg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	if err := s.conn.WriteMessage(websocket.BinaryMessage, b); err != nil {
+		return err
+	}
+
+	s.sequence++
+
+	return nil
+}
+
+func (s *Stream) write(msg *ssm.ClientMessage) error {
+	b, err := msg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.conn.WriteMessage(websocket.BinaryMessage, b)
+}
+
+func (s *Stream) readLoop() {
+	defer close(s.data)
+
+	expected := int64(0)
+
+	for {
+		_, b, err := s.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		msg := &ssm.ClientMessage{}
+		if err := msg.UnmarshalBinary(b); err != nil {
+			return
+		}
+
+		if msg.MessageType != ssm.MessageTypeInputStreamData {
+			continue
+		}
+
+		ack, _ := json.Marshal(map[string]interface{}{
+			"AcknowledgedMessageType":           msg.MessageType,
+			"AcknowledgedMessageId":             msg.MessageID.String(),
+			"AcknowledgedMessageSequenceNumber": msg.SequenceNumber,
+			"IsSequentialMessage":               true,
+		})
+
+		_ = s.write(&ssm.ClientMessage{
+			MessageType:   ssm.MessageTypeAcknowledge,
+			SchemaVersion: 1,
+			CreatedDate:   time.Now(),
+			Flags:         3,
+			MessageID:     uuid.New(),
+			Payload:       ack,
+		})
+
+		// resent messages
+		if msg.SequenceNumber < expected {
+			continue
+		}
+
+		expected = msg.SequenceNumber + 1
+
+		switch msg.PayloadType {
+		case ssm.PayloadTypeHandshakeResponse:
+			close(s.handshake)
+		case ssm.PayloadTypeOutput:
+			s.data <- msg.Payload
+		case ssm.PayloadTypeFlag:
+			if len(msg.Payload) == 4 {
+				select {
+				case s.flags <- ssm.Flag(binary.BigEndian.Uint32(msg.Payload)):
+				default:
+				}
+			}
+		}
+	}
+}
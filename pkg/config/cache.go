@@ -0,0 +1,226 @@
+package config
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+const (
+	// CacheKindCredentials are temporary credentials, e.g. of an assumed role
+	CacheKindCredentials = "credentials"
+
+	// CacheKindIdentity is the caller identity of a profile
+	CacheKindIdentity = "identity"
+
+	// CacheKindMFA is a mfa authenticated session
+	CacheKindMFA = "mfa"
+
+	// CacheKindToken is an eks token
+	CacheKindToken = "token"
+
+	// identityLifetime is the time a caller identity is cached
+	identityLifetime = time.Hour
+)
+
+// cacheDir returns the directory of the cache.
+var cacheDir = func() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".config", "configstore", "gotoaws-cache"), nil
+}
+
+// cacheFile is the format of a file in the cache.
+type cacheFile struct {
+	Kind    string          `json:"kind"`
+	Key     []string        `json:"key"`
+	Expires time.Time       `json:"expires"`
+	Value   json.RawMessage `json:"value"`
+}
+
+// CacheEntry describes a cached value.
+type CacheEntry struct {
+	Kind    string    `json:"kind"`
+	Key     string    `json:"key"`
+	Expires time.Time `json:"expires"`
+	Expired bool      `json:"expired"`
+	File    string    `json:"file"`
+}
+
+// ReadCache decodes the cached value of kind and key into v. It reports false if
+// there is no value or the value is expired.
+func ReadCache(kind string, key []string, v interface{}) bool {
+	dir, err := cacheDir()
+	if err != nil {
+		return false
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, cacheName(kind, key)))
+	if err != nil {
+		return false
+	}
+
+	var f cacheFile
+	if err := json.Unmarshal(b, &f); err != nil || !time.Now().Before(f.Expires) {
+		return false
+	}
+
+	return json.Unmarshal(f.Value, v) == nil
+}
+
+// WriteCache stores v under kind and key until expires. The files are only readable by the user.
+func WriteCache(kind string, key []string, expires time.Time, v interface{}) error {
+	dir, err := cacheDir()
+	if err != nil {
+		return err
+	}
+
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(&cacheFile{Kind: kind, Key: key, Expires: expires, Value: value})
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	// Parallel invocations, e.g. by kubectl, must not read a partial file
+	tmp, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return err
+	}
+
+	return os.Rename(tmp.Name(), filepath.Join(dir, cacheName(kind, key)))
+}
+
+// ListCache returns the entries of the cache sorted by kind and expiration.
+func ListCache() ([]CacheEntry, error) {
+	files, err := cacheFiles()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	entries := make([]CacheEntry, 0, len(files))
+
+	for _, name := range files {
+		b, err := os.ReadFile(name) // nolint: gosec // file of the cache dir
+		if err != nil {
+			return nil, err
+		}
+
+		var f cacheFile
+		if err := json.Unmarshal(b, &f); err != nil {
+			return nil, fmt.Errorf("invalid cache file %s: %w", name, err)
+		}
+
+		entries = append(entries, CacheEntry{
+			Kind:    f.Kind,
+			Key:     strings.Join(f.Key, " "),
+			Expires: f.Expires,
+			Expired: !now.Before(f.Expires),
+			File:    name,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Kind != entries[j].Kind {
+			return entries[i].Kind < entries[j].Kind
+		}
+
+		return entries[i].Expires.Before(entries[j].Expires)
+	})
+
+	return entries, nil
+}
+
+// ClearCache removes every cached value and returns the number of removed entries.
+func ClearCache() (int, error) {
+	files, err := cacheFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, name := range files {
+		if err := os.Remove(name); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(files), nil
+}
+
+func cacheFiles() ([]string, error) {
+	dir, err := cacheDir()
+	if err != nil {
+		return nil, err
+	}
+
+	// A missing dir has no matches
+	return filepath.Glob(filepath.Join(dir, "*-*.json"))
+}
+
+// cacheName returns a file name that does not reveal the parts of the key.
+func cacheName(kind string, key []string) string {
+	sum := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+	return fmt.Sprintf("%s-%s.json", kind, hex.EncodeToString(sum[:8]))
+}
+
+// NewCachedProvider keeps the credentials of provider in the cache until shortly before they expire.
+// Credentials without expiration are not cached.
+func NewCachedProvider(provider aws.CredentialsProvider, key ...string) aws.CredentialsProvider {
+	return &cachedProvider{provider: provider, key: key}
+}
+
+type cachedProvider struct {
+	provider aws.CredentialsProvider
+	key      []string
+}
+
+func (p *cachedProvider) Retrieve(ctx context.Context) (aws.Credentials, error) {
+	var creds aws.Credentials
+	if ReadCache(CacheKindCredentials, p.key, &creds) {
+		return creds, nil
+	}
+
+	creds, err := p.provider.Retrieve(ctx)
+	if err != nil {
+		return aws.Credentials{}, err
+	}
+
+	if creds.CanExpire {
+		// Credentials work without the cache, they are only requested again
+		_ = WriteCache(CacheKindCredentials, p.key, creds.Expires.Add(-expiryWindow), &creds)
+	}
+
+	return creds, nil
+}
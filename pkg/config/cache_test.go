@@ -0,0 +1,116 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func useTempCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	orig := cacheDir
+	cacheDir = func() (string, error) { return dir, nil }
+
+	t.Cleanup(func() { cacheDir = orig })
+}
+
+func TestCache(t *testing.T) {
+	useTempCacheDir(t)
+
+	type value struct {
+		Token string
+	}
+
+	key := []string{"default", "eu-central-1", "cluster"}
+
+	var v value
+	assert.False(t, ReadCache(CacheKindToken, key, &v))
+
+	require.NoError(t, WriteCache(CacheKindToken, key, time.Now().Add(time.Minute), &value{Token: "token"}))
+	require.NoError(t, WriteCache(CacheKindIdentity, []string{"default"}, time.Now().Add(-time.Minute), &value{Token: "old"}))
+
+	assert.True(t, ReadCache(CacheKindToken, key, &v))
+	assert.Equal(t, "token", v.Token)
+	assert.False(t, ReadCache(CacheKindToken, []string{"default", "eu-central-1"}, &v))
+	assert.False(t, ReadCache(CacheKindIdentity, []string{"default"}, &v), "expired")
+
+	entries, err := ListCache()
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, CacheKindIdentity, entries[0].Kind)
+	assert.True(t, entries[0].Expired)
+	assert.Equal(t, CacheKindToken, entries[1].Kind)
+	assert.Equal(t, "default eu-central-1 cluster", entries[1].Key)
+	assert.False(t, entries[1].Expired)
+
+	n, err := ClearCache()
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	entries, err = ListCache()
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+type mockProvider struct {
+	calls int
+	creds aws.Credentials
+	err   error
+}
+
+func (m *mockProvider) Retrieve(_ context.Context) (aws.Credentials, error) {
+	m.calls++
+	return m.creds, m.err
+}
+
+func TestCachedProvider(t *testing.T) {
+	t.Run("expiring credentials", func(t *testing.T) {
+		useTempCacheDir(t)
+
+		mock := &mockProvider{creds: aws.Credentials{AccessKeyID: "AKID", CanExpire: true, Expires: time.Now().Add(time.Hour)}}
+
+		for i := 0; i < 2; i++ {
+			creds, err := NewCachedProvider(mock, "role").Retrieve(context.Background())
+			require.NoError(t, err)
+			assert.Equal(t, "AKID", creds.AccessKeyID)
+		}
+
+		assert.Equal(t, 1, mock.calls)
+	})
+
+	t.Run("about to expire", func(t *testing.T) {
+		useTempCacheDir(t)
+
+		mock := &mockProvider{creds: aws.Credentials{AccessKeyID: "AKID", CanExpire: true, Expires: time.Now().Add(time.Minute)}}
+
+		for i := 0; i < 2; i++ {
+			_, err := NewCachedProvider(mock, "role").Retrieve(context.Background())
+			require.NoError(t, err)
+		}
+
+		assert.Equal(t, 2, mock.calls)
+	})
+
+	t.Run("error", func(t *testing.T) {
+		useTempCacheDir(t)
+
+		_, err := NewCachedProvider(&mockProvider{err: errors.New("denied")}, "role").Retrieve(context.Background())
+		assert.EqualError(t, err, "denied")
+
+		entries, err := ListCache()
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+}
+
+func TestCacheName(t *testing.T) {
+	name := cacheName(CacheKindMFA, []string{"default", "arn:aws:iam::123456789012:mfa/user"})
+	assert.Regexp(t, `^mfa-[0-9a-f]{16}\.json$`, name)
+	assert.NotContains(t, name, "123456789012")
+	assert.NotEqual(t, name, cacheName(CacheKindMFA, []string{"other", "arn:aws:iam::123456789012:mfa/user"}))
+}
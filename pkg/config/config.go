@@ -2,8 +2,6 @@ package config
 
 import (
 	"context"
-	"fmt"
-	"os/exec"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -16,9 +14,15 @@ type Config struct {
 	// The Amazon Web Services account ID number of the account that owns or contains the calling entity
 	Account string
 
+	// The ARN of the calling entity, e.g. the assumed role
+	Principal string
+
 	// The SharedConfigProfile that is used
 	Profile string
 
+	// SessionName is the name of the role sessions started by gotoaws
+	SessionName string
+
 	// The region to send requests to.
 	Region string
 
@@ -27,12 +31,14 @@ type Config struct {
 
 	// A Config provides service configuration for aws service clients
 	AWSConfig aws.Config
-
-	// Path to the session-manager-plugin
-	Plugin string
 }
 
 func NewConfig(profile string, region string, timeout time.Duration) (*Config, error) {
+	return NewConfigWithCredentials(profile, region, timeout, nil)
+}
+
+// NewConfigWithCredentials loads the profile and applies the mfa session and role chain of the input.
+func NewConfigWithCredentials(profile string, region string, timeout time.Duration, input *CredentialsInput) (*Config, error) {
 	awsCfg, err := config.LoadDefaultConfig(
 		context.TODO(),
 		config.WithRegion(region),
@@ -45,27 +51,112 @@ func NewConfig(profile string, region string, timeout time.Duration) (*Config, e
 		return nil, err
 	}
 
-	pluginPath, err := exec.LookPath("session-manager-plugin")
+	// The cache keys are derived from the resolved credentials, not from the flags or
+	// environment that selected them
+	creds, err := awsCfg.Credentials.Retrieve(context.TODO())
 	if err != nil {
-		return nil, fmt.Errorf(`session-manager-plugin not found
+		return nil, err
+	}
+
+	sessionName := DefaultSessionName
 
-https://docs.aws.amazon.com/systems-manager/latest/userguide/session-manager-working-with-install-plugin.html
-		`)
+	if input != nil {
+		if err := applyCredentials(context.TODO(), &awsCfg, creds.AccessKeyID, input); err != nil {
+			return nil, err
+		}
+
+		if input.SessionName != "" {
+			sessionName = input.SessionName
+		}
 	}
 
-	client := sts.NewFromConfig(awsCfg)
+	key := identityKey(creds.AccessKeyID, awsCfg.Region, sessionName, input)
 
-	output, err := client.GetCallerIdentity(context.TODO(), &sts.GetCallerIdentityInput{})
+	id, err := callerIdentity(context.TODO(), awsCfg, key)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Config{
-		Account:   *output.Account,
-		Profile:   profile,
-		Region:    awsCfg.Region,
-		Plugin:    pluginPath,
-		Timeout:   timeout,
-		AWSConfig: awsCfg,
+		Account:     id.Account,
+		Principal:   id.Arn,
+		Profile:     profile,
+		SessionName: sessionName,
+		Region:      awsCfg.Region,
+		Timeout:     timeout,
+		AWSConfig:   awsCfg,
 	}, nil
 }
+
+// AssumeRole returns a config with the credentials of the role, e.g. in another account.
+// The credentials of c are used to assume the role.
+func (c *Config) AssumeRole(roleARN string) (*Config, error) {
+	awsCfg := c.AWSConfig.Copy()
+	awsCfg.Credentials = aws.NewCredentialsCache(NewCachedProvider(stscreds.NewAssumeRoleProvider(sts.NewFromConfig(c.AWSConfig), roleARN, func(aro *stscreds.AssumeRoleOptions) {
+		if c.SessionName != "" {
+			aro.RoleSessionName = c.SessionName
+		}
+	}), c.Principal, c.SessionName, roleARN))
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+
+	id, err := callerIdentity(ctx, awsCfg, []string{c.Principal, c.SessionName, roleARN, awsCfg.Region})
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := *c
+	cfg.Account = id.Account
+	cfg.Principal = id.Arn
+	cfg.AWSConfig = awsCfg
+
+	return &cfg, nil
+}
+
+// WithRegion returns a copy of the config for another region.
+func (c *Config) WithRegion(region string) *Config {
+	cfg := *c
+	cfg.Region = region
+	cfg.AWSConfig = c.AWSConfig.Copy()
+	cfg.AWSConfig.Region = region
+
+	return &cfg
+}
+
+type identity struct {
+	Account string `json:"account"`
+	Arn     string `json:"arn"`
+}
+
+// identityKey is the cache key of the caller identity of the credentials with the
+// access key id after the mfa session and role chain of the input.
+func identityKey(accessKeyID, region, sessionName string, input *CredentialsInput) []string {
+	key := []string{accessKeyID, region, sessionName}
+	if input != nil {
+		key = append(key, input.ExternalID, input.MFASerial)
+		key = append(key, input.RoleARNs...)
+	}
+
+	return key
+}
+
+// callerIdentity returns the cached identity of key or requests it with the credentials of awsCfg.
+func callerIdentity(ctx context.Context, awsCfg aws.Config, key []string) (*identity, error) {
+	var id identity
+	if ReadCache(CacheKindIdentity, key, &id) {
+		return &id, nil
+	}
+
+	output, err := sts.NewFromConfig(awsCfg).GetCallerIdentity(ctx, &sts.GetCallerIdentityInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	id = identity{Account: aws.ToString(output.Account), Arn: aws.ToString(output.Arn)}
+
+	// The identity is only requested again without the cache
+	_ = WriteCache(CacheKindIdentity, key, time.Now().Add(identityLifetime), &id)
+
+	return &id, nil
+}
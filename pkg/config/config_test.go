@@ -0,0 +1,102 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfigIdentity(t *testing.T) {
+	useTempCacheDir(t)
+
+	dir := t.TempDir()
+	credentials := filepath.Join(dir, "credentials")
+
+	require.NoError(t, os.WriteFile(credentials, []byte(`[first]
+aws_access_key_id = AKIDFIRST
+aws_secret_access_key = SECRET
+
+[second]
+aws_access_key_id = AKIDSECOND
+aws_secret_access_key = SECRET
+`), 0600))
+
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentials)
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	// The cached identities avoid requests to sts
+	expires := time.Now().Add(time.Minute)
+	require.NoError(t, WriteCache(CacheKindIdentity, identityKey("AKIDFIRST", "eu-central-1", DefaultSessionName, nil), expires, &identity{Account: "111111111111", Arn: "arn:aws:iam::111111111111:user/first"}))
+	require.NoError(t, WriteCache(CacheKindIdentity, identityKey("AKIDSECOND", "eu-central-1", DefaultSessionName, nil), expires, &identity{Account: "222222222222", Arn: "arn:aws:iam::222222222222:user/second"}))
+
+	t.Setenv("AWS_PROFILE", "first")
+
+	cfg, err := NewConfig("", "eu-central-1", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "111111111111", cfg.Account)
+
+	t.Setenv("AWS_PROFILE", "second")
+
+	cfg, err = NewConfig("", "eu-central-1", time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, "222222222222", cfg.Account)
+	assert.Equal(t, "arn:aws:iam::222222222222:user/second", cfg.Principal)
+}
+
+func TestNewConfigMFASession(t *testing.T) {
+	useTempCacheDir(t)
+
+	dir := t.TempDir()
+
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", filepath.Join(dir, "credentials"))
+	t.Setenv("AWS_CONFIG_FILE", filepath.Join(dir, "config"))
+	t.Setenv("AWS_PROFILE", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDFIRST")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "SECRET")
+	t.Setenv("AWS_SESSION_TOKEN", "")
+
+	prompts := 0
+	input := &CredentialsInput{
+		MFASerial: "arn:aws:iam::111111111111:mfa/first",
+		TokenProvider: func() (string, error) {
+			prompts++
+			return "", errors.New("prompted")
+		},
+	}
+
+	// The cached mfa session and identity avoid requests to sts
+	expires := time.Now().Add(time.Minute)
+	require.NoError(t, WriteCache(CacheKindMFA, []string{"AKIDFIRST", input.MFASerial}, expires, &aws.Credentials{AccessKeyID: "ASIAFIRST", SecretAccessKey: "SECRET", SessionToken: "TOKEN", CanExpire: true, Expires: expires}))
+	require.NoError(t, WriteCache(CacheKindIdentity, identityKey("AKIDFIRST", "eu-central-1", DefaultSessionName, input), expires, &identity{Account: "111111111111", Arn: "arn:aws:iam::111111111111:user/first"}))
+
+	cfg, err := NewConfigWithCredentials("", "eu-central-1", time.Second, input)
+	require.NoError(t, err)
+	assert.Equal(t, "111111111111", cfg.Account)
+	assert.Equal(t, 0, prompts)
+
+	// Other credentials of the environment share the empty profile, not the session
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDSECOND")
+
+	_, err = NewConfigWithCredentials("", "eu-central-1", time.Second, input)
+	assert.EqualError(t, err, "prompted")
+	assert.Equal(t, 1, prompts)
+}
+
+func TestIdentityKey(t *testing.T) {
+	input := &CredentialsInput{RoleARNs: []string{"arn:aws:iam::123456789012:role/admin"}}
+
+	assert.NotEqual(t, identityKey("AKID", "eu-central-1", "alice", input), identityKey("AKID", "eu-central-1", "bob", input))
+	assert.NotEqual(t, identityKey("AKID", "eu-central-1", "gotoaws", input), identityKey("AKID", "eu-central-1", "gotoaws", &CredentialsInput{
+		RoleARNs:   input.RoleARNs,
+		ExternalID: "external",
+	}))
+}
@@ -0,0 +1,171 @@
+package config
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+const (
+	// DefaultSessionName is the role session name if none is configured
+	DefaultSessionName = "gotoaws"
+
+	// mfaSessionDuration is the lifetime of a cached mfa session
+	mfaSessionDuration = 12 * time.Hour
+
+	// expiryWindow is the time before the expiration at which cached credentials are renewed
+	expiryWindow = 5 * time.Minute
+)
+
+// CredentialsInput configures the credentials on top of the profile.
+type CredentialsInput struct {
+	// RoleARNs are assumed in order, each with the credentials of the previous one
+	RoleARNs []string
+
+	// ExternalID is passed to every assumed role
+	ExternalID string
+
+	// SessionName is the role session name. The default is gotoaws.
+	SessionName string
+
+	// Duration of the role sessions. Chained roles are limited to one hour by AWS.
+	Duration time.Duration
+
+	// MFASerial is the serial number or arn of a mfa device. The mfa session is cached on disk.
+	MFASerial string
+
+	// TokenProvider returns the mfa code. The default reads it from stdin.
+	TokenProvider func() (string, error)
+}
+
+// SessionTokenClient is the subset of the sts api used to start a mfa session.
+type SessionTokenClient interface {
+	GetSessionToken(ctx context.Context, params *sts.GetSessionTokenInput, optFns ...func(*sts.Options)) (*sts.GetSessionTokenOutput, error)
+}
+
+// applyCredentials replaces the credentials of awsCfg with the mfa session and the role chain of the input.
+// accessKeyID identifies the credentials of the profile in the cache keys of the mfa session and the chain.
+func applyCredentials(ctx context.Context, awsCfg *aws.Config, accessKeyID string, input *CredentialsInput) error {
+	for _, roleARN := range input.RoleARNs {
+		if !arn.IsARN(roleARN) {
+			return fmt.Errorf("invalid role arn %q", roleARN)
+		}
+	}
+
+	if input.MFASerial != "" {
+		creds, err := mfaSession(ctx, sts.NewFromConfig(*awsCfg), accessKeyID, input)
+		if err != nil {
+			return err
+		}
+
+		awsCfg.Credentials = aws.NewCredentialsCache(credentialsProvider{creds: *creds})
+	}
+
+	sessionName := input.SessionName
+	if sessionName == "" {
+		sessionName = DefaultSessionName
+	}
+
+	// Every step of the chain is cached with the steps before it
+	key := []string{accessKeyID, input.MFASerial, sessionName, input.ExternalID}
+
+	for _, roleARN := range input.RoleARNs {
+		// The client keeps the credentials of the previous step
+		client := sts.NewFromConfig(*awsCfg)
+
+		key = append(key[:len(key):len(key)], roleARN)
+
+		awsCfg.Credentials = aws.NewCredentialsCache(NewCachedProvider(stscreds.NewAssumeRoleProvider(client, roleARN, func(aro *stscreds.AssumeRoleOptions) {
+			aro.RoleSessionName = sessionName
+
+			if input.ExternalID != "" {
+				aro.ExternalID = aws.String(input.ExternalID)
+			}
+
+			if input.Duration > 0 {
+				aro.Duration = input.Duration
+			}
+		}), key...))
+	}
+
+	return nil
+}
+
+// mfaSession returns the cached mfa session of the credentials and device or starts a new one.
+func mfaSession(ctx context.Context, client SessionTokenClient, accessKeyID string, input *CredentialsInput) (*aws.Credentials, error) {
+	key := []string{accessKeyID, input.MFASerial}
+
+	var creds aws.Credentials
+	if ReadCache(CacheKindMFA, key, &creds) {
+		return &creds, nil
+	}
+
+	tokenProvider := input.TokenProvider
+	if tokenProvider == nil {
+		tokenProvider = stdinTokenProvider(input.MFASerial)
+	}
+
+	code, err := tokenProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := client.GetSessionToken(ctx, &sts.GetSessionTokenInput{
+		SerialNumber:    aws.String(input.MFASerial),
+		TokenCode:       aws.String(code),
+		DurationSeconds: aws.Int32(int32(mfaSessionDuration.Seconds())),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	creds = aws.Credentials{
+		AccessKeyID:     aws.ToString(output.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(output.Credentials.SecretAccessKey),
+		SessionToken:    aws.ToString(output.Credentials.SessionToken),
+		Source:          "gotoaws mfa session",
+		CanExpire:       true,
+		Expires:         aws.ToTime(output.Credentials.Expiration),
+	}
+
+	// The session works without the cache, it is only asked for the code again
+	_ = WriteCache(CacheKindMFA, key, creds.Expires.Add(-expiryWindow), &creds)
+
+	return &creds, nil
+}
+
+func stdinTokenProvider(serial string) func() (string, error) {
+	return func() (string, error) {
+		fmt.Fprintf(os.Stderr, "MFA code for %s: ", serial)
+
+		code, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+
+		code = strings.TrimSpace(code)
+		if code == "" {
+			return "", errors.New("mfa code is required")
+		}
+
+		return code, nil
+	}
+}
+
+// credentialsProvider returns fixed credentials including their expiration.
+type credentialsProvider struct {
+	creds aws.Credentials
+}
+
+func (p credentialsProvider) Retrieve(_ context.Context) (aws.Credentials, error) {
+	return p.creds, nil
+}
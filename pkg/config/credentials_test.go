@@ -0,0 +1,88 @@
+package config
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+	"github.com/aws/aws-sdk-go-v2/service/sts/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockSessionTokenClient struct {
+	calls int
+	input *sts.GetSessionTokenInput
+}
+
+func (m *mockSessionTokenClient) GetSessionToken(ctx context.Context, params *sts.GetSessionTokenInput, optFns ...func(*sts.Options)) (*sts.GetSessionTokenOutput, error) {
+	m.calls++
+	m.input = params
+
+	return &sts.GetSessionTokenOutput{
+		Credentials: &types.Credentials{
+			AccessKeyId:     aws.String("AKID"),
+			SecretAccessKey: aws.String("SECRET"),
+			SessionToken:    aws.String("TOKEN"),
+			Expiration:      aws.Time(time.Now().Add(time.Hour)),
+		},
+	}, nil
+}
+
+func TestMFASession(t *testing.T) {
+	useTempCacheDir(t)
+
+	prompts := 0
+	input := &CredentialsInput{
+		MFASerial: "arn:aws:iam::123456789012:mfa/user",
+		TokenProvider: func() (string, error) {
+			prompts++
+			return "123456", nil
+		},
+	}
+
+	client := &mockSessionTokenClient{}
+
+	creds, err := mfaSession(context.Background(), client, "AKIDBASE", input)
+	require.NoError(t, err)
+	assert.Equal(t, "AKID", creds.AccessKeyID)
+	assert.Equal(t, "123456", aws.ToString(client.input.TokenCode))
+	assert.Equal(t, input.MFASerial, aws.ToString(client.input.SerialNumber))
+
+	t.Run("cached", func(t *testing.T) {
+		creds, err := mfaSession(context.Background(), client, "AKIDBASE", input)
+		require.NoError(t, err)
+		assert.Equal(t, "TOKEN", creds.SessionToken)
+		assert.Equal(t, 1, client.calls)
+		assert.Equal(t, 1, prompts)
+	})
+
+	t.Run("other credentials", func(t *testing.T) {
+		// Credentials from the environment share the empty profile
+		_, err := mfaSession(context.Background(), client, "AKIDOTHER", input)
+		require.NoError(t, err)
+		assert.Equal(t, 2, client.calls)
+		assert.Equal(t, 2, prompts)
+	})
+}
+
+func TestApplyCredentials(t *testing.T) {
+	t.Run("invalid role arn", func(t *testing.T) {
+		err := applyCredentials(context.Background(), &aws.Config{}, "AKID", &CredentialsInput{
+			RoleARNs: []string{"arn:aws:iam::123456789012:role/first", "second"},
+		})
+		assert.EqualError(t, err, `invalid role arn "second"`)
+	})
+
+	t.Run("role chain", func(t *testing.T) {
+		awsCfg := &aws.Config{Region: "eu-central-1"}
+
+		err := applyCredentials(context.Background(), awsCfg, "AKID", &CredentialsInput{
+			RoleARNs: []string{"arn:aws:iam::123456789012:role/first", "arn:aws:iam::210987654321:role/second"},
+		})
+		require.NoError(t, err)
+		assert.IsType(t, &aws.CredentialsCache{}, awsCfg.Credentials)
+	})
+}
@@ -0,0 +1,449 @@
+package ec2
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+type CopyInput struct {
+	SSHInput
+
+	// Sources are local paths when sending and remote paths when receiving. Glob patterns
+	// are expanded on the side of the sources.
+	Sources []string
+
+	// Target is a file or a directory. It must be a directory for more than one source,
+	// a trailing slash creates the directory.
+	Target string
+
+	Mode SCPMode
+
+	// Recursive copies directories with their contents
+	Recursive bool
+
+	// Resume continues partial target files that are smaller than the source instead of copying them again
+	Resume bool
+
+	// Preserve sets the mode and modification time of the targets to those of the sources
+	Preserve bool
+
+	// DryRun only plans the copy
+	DryRun bool
+
+	// Progress is called while a file is copied with the bytes written to the target
+	Progress func(file *CopyFile, written int64)
+}
+
+// CopyFile is a file or directory of a copy.
+type CopyFile struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	Dir    bool   `json:"dir,omitempty"`
+	Size   int64  `json:"size"`
+
+	// Offset is the size of a partial target that is resumed
+	Offset int64 `json:"offset,omitempty"`
+
+	Mode    os.FileMode `json:"-"`
+	ModTime time.Time   `json:"modTime"`
+}
+
+// PlanCopy expands the sources of the input and returns the directories and files to copy in order.
+func PlanCopy(client *sftp.Client, input *CopyInput) ([]CopyFile, error) {
+	src, dst := copySides(client, input.Mode)
+
+	plan, err := planCopy(src, dst, input)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.files, nil
+}
+
+// Copy copies the sources of the input over the sftp client and returns the copied
+// directories and files. A dry run returns the plan without copying.
+func Copy(client *sftp.Client, input *CopyInput) ([]CopyFile, error) {
+	src, dst := copySides(client, input.Mode)
+
+	plan, err := planCopy(src, dst, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.DryRun {
+		return plan.files, nil
+	}
+
+	if err := plan.run(src, dst, input); err != nil {
+		return nil, err
+	}
+
+	return plan.files, nil
+}
+
+func copySides(client *sftp.Client, mode SCPMode) (copyFS, copyFS) {
+	local, remote := localFS{}, remoteFS{client}
+
+	if mode == SCPModeReceiving {
+		return remote, local
+	}
+
+	return local, remote
+}
+
+type copyPlan struct {
+	// mkTarget creates the target directory of a trailing slash
+	mkTarget string
+	files    []CopyFile
+}
+
+func planCopy(src, dst copyFS, input *CopyInput) (*copyPlan, error) {
+	sources, err := expandSources(src, input.Sources)
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &copyPlan{}
+
+	info, err := dst.Stat(input.Target)
+	toDir := err == nil && info.IsDir()
+
+	if err != nil && strings.HasSuffix(input.Target, "/") {
+		toDir = true
+		plan.mkTarget = input.Target
+	}
+
+	if len(sources) > 1 && !toDir {
+		return nil, fmt.Errorf("target %s is not a directory", input.Target)
+	}
+
+	for _, s := range sources {
+		info, err := src.Stat(s)
+		if err != nil {
+			return nil, err
+		}
+
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("%s is not a regular file", s)
+		}
+
+		target := input.Target
+		if toDir {
+			target = dst.Join(input.Target, src.Base(s))
+		}
+
+		if err := plan.add(src, dst, input, s, target, info); err != nil {
+			return nil, err
+		}
+	}
+
+	return plan, nil
+}
+
+// expandSources expands the glob patterns of the sources. Sources without
+// a pattern, or existing files with pattern characters in their name (e.g.
+// report[final].pdf), are returned as they are.
+func expandSources(fs copyFS, sources []string) ([]string, error) {
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("no sources to copy")
+	}
+
+	var expanded []string
+
+	for _, s := range sources {
+		if !strings.ContainsAny(s, "*?[") {
+			expanded = append(expanded, s)
+			continue
+		}
+
+		if _, err := fs.Stat(s); err == nil {
+			expanded = append(expanded, s)
+			continue
+		}
+
+		matches, err := fs.Glob(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %s: %w", s, err)
+		}
+
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no matches for %s", s)
+		}
+
+		sort.Strings(matches)
+
+		expanded = append(expanded, matches...)
+	}
+
+	return expanded, nil
+}
+
+func (p *copyPlan) add(src, dst copyFS, input *CopyInput, source, target string, info os.FileInfo) error {
+	file := CopyFile{
+		Source:  source,
+		Target:  target,
+		Dir:     info.IsDir(),
+		Mode:    info.Mode().Perm(),
+		ModTime: info.ModTime(),
+	}
+
+	if !file.Dir {
+		file.Size = info.Size()
+
+		if input.Resume {
+			// Only a smaller target can be continued. A target of the same size may
+			// differ from the source, so it is copied again like everything else.
+			if t, err := dst.Stat(target); err == nil && t.Mode().IsRegular() && t.Size() < file.Size {
+				file.Offset = t.Size()
+			}
+		}
+
+		p.files = append(p.files, file)
+
+		return nil
+	}
+
+	if !input.Recursive {
+		return fmt.Errorf("%s is a directory, it is only copied recursively", source)
+	}
+
+	p.files = append(p.files, file)
+
+	entries, err := src.ReadDir(source)
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+
+	for _, entry := range entries {
+		child := src.Join(source, entry.Name())
+
+		// Links to files are followed, links to directories are not to avoid cycles
+		if entry.Mode()&os.ModeSymlink != 0 {
+			if entry, err = src.Stat(child); err != nil || entry.IsDir() {
+				continue
+			}
+		}
+
+		if !entry.IsDir() && !entry.Mode().IsRegular() {
+			continue
+		}
+
+		if err := p.add(src, dst, input, child, dst.Join(target, entry.Name()), entry); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *copyPlan) run(src, dst copyFS, input *CopyInput) error {
+	if p.mkTarget != "" {
+		if err := dst.MkdirAll(p.mkTarget); err != nil {
+			return fmt.Errorf("cannot create %s: %w", p.mkTarget, err)
+		}
+	}
+
+	for i := range p.files {
+		file := &p.files[i]
+
+		if file.Dir {
+			if err := dst.MkdirAll(file.Target); err != nil {
+				return fmt.Errorf("cannot create %s: %w", file.Target, err)
+			}
+
+			continue
+		}
+
+		if err := copyFile(src, dst, file, input.Progress); err != nil {
+			return err
+		}
+
+		if input.Preserve {
+			if err := preserve(dst, file); err != nil {
+				return err
+			}
+		}
+	}
+
+	if input.Preserve {
+		// Directories last, copying their files changes the modification time
+		for i := len(p.files) - 1; i >= 0; i-- {
+			if p.files[i].Dir {
+				if err := preserve(dst, &p.files[i]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func copyFile(src, dst copyFS, file *CopyFile, progress func(*CopyFile, int64)) error {
+	if progress == nil {
+		progress = func(*CopyFile, int64) {}
+	}
+
+	r, err := src.Open(file.Source)
+	if err != nil {
+		return fmt.Errorf("cannot open %s: %w", file.Source, err)
+	}
+	defer r.Close()
+
+	flag := os.O_WRONLY | os.O_CREATE
+	if file.Offset == 0 {
+		flag |= os.O_TRUNC
+	}
+
+	w, err := dst.OpenFile(file.Target, flag)
+	if err != nil {
+		return fmt.Errorf("cannot create %s: %w", file.Target, err)
+	}
+
+	if file.Offset > 0 {
+		if _, err := r.Seek(file.Offset, io.SeekStart); err != nil {
+			_ = w.Close()
+			return err
+		}
+
+		if _, err := w.Seek(file.Offset, io.SeekStart); err != nil {
+			_ = w.Close()
+			return err
+		}
+	}
+
+	progress(file, file.Offset)
+
+	pw := &progressWriter{w: w, written: file.Offset, report: func(n int64) { progress(file, n) }}
+
+	if _, err := io.Copy(pw, r); err != nil {
+		_ = w.Close()
+		return fmt.Errorf("cannot copy %s: %w", file.Source, err)
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	if file.Offset > 0 {
+		return nil
+	}
+
+	// Like scp, a target written from the start gets the mode of the source
+	return dst.Chmod(file.Target, file.Mode)
+}
+
+func preserve(fs copyFS, file *CopyFile) error {
+	if err := fs.Chmod(file.Target, file.Mode); err != nil {
+		return err
+	}
+
+	return fs.Chtimes(file.Target, file.ModTime, file.ModTime)
+}
+
+type progressWriter struct {
+	w       io.Writer
+	written int64
+	report  func(written int64)
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+	pw.report(pw.written)
+
+	return n, err
+}
+
+// copyFileHandle is an open file of a copyFS.
+type copyFileHandle interface {
+	io.ReadWriteSeeker
+	io.Closer
+}
+
+// copyFS is one side of a copy, the local filesystem or the instance.
+type copyFS interface {
+	Glob(pattern string) ([]string, error)
+	Stat(name string) (os.FileInfo, error)
+
+	// ReadDir returns the entries without following links
+	ReadDir(name string) ([]os.FileInfo, error)
+	Open(name string) (copyFileHandle, error)
+	OpenFile(name string, flag int) (copyFileHandle, error)
+	MkdirAll(name string) error
+	Chmod(name string, mode os.FileMode) error
+	Chtimes(name string, atime, mtime time.Time) error
+	Join(elem ...string) string
+	Base(name string) string
+}
+
+type localFS struct{}
+
+func (localFS) Glob(pattern string) ([]string, error) { return filepath.Glob(pattern) }
+func (localFS) Stat(name string) (os.FileInfo, error) { return os.Stat(name) }
+
+func (localFS) ReadDir(name string) ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(name)
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+func (localFS) Open(name string) (copyFileHandle, error) {
+	return os.Open(name) // nolint: gosec // path is provided by the user
+}
+
+func (localFS) OpenFile(name string, flag int) (copyFileHandle, error) {
+	return os.OpenFile(name, flag, 0o644) // nolint: gosec // path is provided by the user
+}
+
+func (localFS) MkdirAll(name string) error                { return os.MkdirAll(name, 0o755) }
+func (localFS) Chmod(name string, mode os.FileMode) error { return os.Chmod(name, mode) }
+func (localFS) Chtimes(name string, a, m time.Time) error { return os.Chtimes(name, a, m) }
+func (localFS) Join(elem ...string) string                { return filepath.Join(elem...) }
+func (localFS) Base(name string) string                   { return filepath.Base(name) }
+
+type remoteFS struct {
+	client *sftp.Client
+}
+
+func (fs remoteFS) Glob(pattern string) ([]string, error)      { return fs.client.Glob(pattern) }
+func (fs remoteFS) Stat(name string) (os.FileInfo, error)      { return fs.client.Stat(name) }
+func (fs remoteFS) ReadDir(name string) ([]os.FileInfo, error) { return fs.client.ReadDir(name) }
+
+func (fs remoteFS) Open(name string) (copyFileHandle, error) {
+	return fs.client.Open(name)
+}
+
+func (fs remoteFS) OpenFile(name string, flag int) (copyFileHandle, error) {
+	return fs.client.OpenFile(name, flag)
+}
+
+func (fs remoteFS) MkdirAll(name string) error                { return fs.client.MkdirAll(name) }
+func (fs remoteFS) Chmod(name string, mode os.FileMode) error { return fs.client.Chmod(name, mode) }
+func (fs remoteFS) Chtimes(name string, a, m time.Time) error { return fs.client.Chtimes(name, a, m) }
+func (fs remoteFS) Join(elem ...string) string                { return path.Join(elem...) }
+func (fs remoteFS) Base(name string) string                   { return path.Base(name) }
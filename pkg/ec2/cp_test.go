@@ -0,0 +1,187 @@
+package ec2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTree creates the files of a directory tree, the keys are slash separated paths.
+func newTree(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0o755))
+		writeFile(t, p, content, 0o644)
+	}
+
+	return dir
+}
+
+func targets(files []CopyFile, base string) []string {
+	var names []string
+
+	for _, f := range files {
+		rel, _ := filepath.Rel(base, f.Target)
+		names = append(names, filepath.ToSlash(rel))
+	}
+
+	return names
+}
+
+func TestCopy(t *testing.T) {
+	client := newSFTPClient(t)
+
+	t.Run("recursive", func(t *testing.T) {
+		local := newTree(t, map[string]string{
+			"app/bin/run.sh":    "run",
+			"app/conf/app.yaml": "yaml",
+			"app/README":        "readme",
+		})
+		remote := t.TempDir()
+
+		require.NoError(t, os.Symlink(filepath.Join(local, "app", "README"), filepath.Join(local, "app", "link")))
+		require.NoError(t, os.Symlink(filepath.Join(local, "app", "conf"), filepath.Join(local, "app", "conf-link")))
+
+		var written []int64
+
+		files, err := Copy(client, &CopyInput{
+			Sources:   []string{filepath.Join(local, "app")},
+			Target:    remote,
+			Mode:      SCPModeSending,
+			Recursive: true,
+			Progress: func(file *CopyFile, n int64) {
+				if file.Source == filepath.Join(local, "app", "README") {
+					written = append(written, n)
+				}
+			},
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"app", "app/README", "app/bin", "app/bin/run.sh", "app/conf", "app/conf/app.yaml", "app/link"}, targets(files, remote))
+		assertFile(t, filepath.Join(remote, "app", "bin", "run.sh"), "run", 0o644)
+		assertFile(t, filepath.Join(remote, "app", "link"), "readme", 0o644)
+		assert.Equal(t, []int64{0, 6}, written)
+	})
+
+	t.Run("not recursive", func(t *testing.T) {
+		local := newTree(t, map[string]string{"app/README": "readme"})
+
+		_, err := Copy(client, &CopyInput{Sources: []string{filepath.Join(local, "app")}, Target: t.TempDir(), Mode: SCPModeSending})
+		assert.ErrorContains(t, err, "is a directory")
+	})
+
+	t.Run("glob", func(t *testing.T) {
+		remote := newTree(t, map[string]string{
+			"logs/a.log": "a",
+			"logs/b.log": "b",
+			"logs/c.txt": "c",
+		})
+		local := filepath.Join(t.TempDir(), "logs") + "/"
+
+		files, err := Copy(client, &CopyInput{
+			Sources: []string{filepath.Join(remote, "logs", "*.log")},
+			Target:  local,
+			Mode:    SCPModeReceiving,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"a.log", "b.log"}, targets(files, local))
+		assertFile(t, filepath.Join(local, "a.log"), "a", 0o644)
+
+		_, err = Copy(client, &CopyInput{Sources: []string{filepath.Join(remote, "*.gz")}, Target: local, Mode: SCPModeReceiving})
+		assert.ErrorContains(t, err, "no matches for")
+
+		_, err = Copy(client, &CopyInput{Sources: []string{filepath.Join(remote, "logs", "*")}, Target: filepath.Join(local, "a.log"), Mode: SCPModeReceiving})
+		assert.ErrorContains(t, err, "is not a directory")
+	})
+
+	t.Run("pattern characters in a name", func(t *testing.T) {
+		remote := newTree(t, map[string]string{
+			"report[final].pdf": "final",
+			"reportf.pdf":       "f",
+		})
+		local := t.TempDir() + "/"
+
+		files, err := Copy(client, &CopyInput{
+			Sources: []string{filepath.Join(remote, "report[final].pdf")},
+			Target:  local,
+			Mode:    SCPModeReceiving,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"report[final].pdf"}, targets(files, local))
+		assertFile(t, filepath.Join(local, "report[final].pdf"), "final", 0o644)
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		local := newTree(t, map[string]string{"data.bin": "0123456789", "done.bin": "done"})
+		remote := newTree(t, map[string]string{"data.bin": "0123", "done.bin": "DONE"})
+
+		files, err := Copy(client, &CopyInput{
+			Sources: []string{filepath.Join(local, "data.bin"), filepath.Join(local, "done.bin")},
+			Target:  remote,
+			Mode:    SCPModeSending,
+			Resume:  true,
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, int64(4), files[0].Offset)
+		assert.Equal(t, int64(0), files[1].Offset, "same size")
+		assertFile(t, filepath.Join(remote, "data.bin"), "0123456789", 0o644)
+		assertFile(t, filepath.Join(remote, "done.bin"), "done", 0o644)
+	})
+
+	t.Run("preserve", func(t *testing.T) {
+		local := newTree(t, map[string]string{"bin/tool": "tool"})
+		remote := t.TempDir()
+		mtime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+		require.NoError(t, os.Chmod(filepath.Join(local, "bin", "tool"), 0o750))
+		require.NoError(t, os.Chtimes(filepath.Join(local, "bin", "tool"), mtime, mtime))
+		require.NoError(t, os.Chtimes(filepath.Join(local, "bin"), mtime, mtime))
+
+		_, err := Copy(client, &CopyInput{
+			Sources:   []string{filepath.Join(local, "bin")},
+			Target:    remote,
+			Mode:      SCPModeSending,
+			Recursive: true,
+			Preserve:  true,
+		})
+		require.NoError(t, err)
+
+		assertFile(t, filepath.Join(remote, "bin", "tool"), "tool", 0o750)
+
+		for _, name := range []string{"bin", "bin/tool"} {
+			info, err := os.Stat(filepath.Join(remote, filepath.FromSlash(name)))
+			require.NoError(t, err)
+			assert.True(t, mtime.Equal(info.ModTime()), name)
+		}
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		local := newTree(t, map[string]string{"a": "a"})
+		remote := filepath.Join(t.TempDir(), "new")
+
+		files, err := Copy(client, &CopyInput{Sources: []string{filepath.Join(local, "a")}, Target: remote + "/", Mode: SCPModeSending, DryRun: true})
+		require.NoError(t, err)
+
+		assert.Equal(t, []CopyFile{{
+			Source:  filepath.Join(local, "a"),
+			Target:  filepath.Join(remote, "a"),
+			Size:    1,
+			Mode:    0o644,
+			ModTime: files[0].ModTime,
+		}}, files)
+
+		_, err = os.Stat(remote)
+		assert.True(t, os.IsNotExist(err))
+	})
+}
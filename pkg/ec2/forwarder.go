@@ -0,0 +1,587 @@
+package ec2
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hupe1980/gotoaws/internal/backoff"
+	"github.com/hupe1980/gotoaws/pkg/ssm"
+	"github.com/xtaci/smux"
+)
+
+// ErrSessionClosed is returned by Forwarder.Start when the agent closed the session.
+var ErrSessionClosed = errors.New("session closed")
+
+// ErrTargetUnavailable is returned by a Reconnect function when the target is gone for
+// good, e.g. a stopped task. The forwarder stops instead of retrying.
+var ErrTargetUnavailable = errors.New("target is no longer available")
+
+// ConnStats describes a forwarded connection.
+type ConnStats struct {
+	// ID of the connection, unique per forwarder
+	ID int64
+
+	// Address of the local client
+	RemoteAddr string
+
+	// Time the connection was accepted
+	OpenedAt time.Time
+
+	// Time the connection was closed, zero while it is open
+	ClosedAt time.Time
+
+	// Bytes sent from the local client to the remote port
+	BytesSent int64
+
+	// Bytes received from the remote port
+	BytesReceived int64
+}
+
+// Stats describes the connections of a forwarder.
+type Stats struct {
+	// Number of connections accepted since start
+	TotalConns int64
+
+	// Connections that are currently open
+	ActiveConns []ConnStats
+
+	// Bytes sent over all connections
+	BytesSent int64
+
+	// Bytes received over all connections
+	BytesReceived int64
+}
+
+type ForwarderInput struct {
+	// LocalPort is the local port to listen on. An empty port or "0" chooses a free port.
+	LocalPort string
+
+	// OnConnOpen is called when a local connection was accepted
+	OnConnOpen func(conn ConnStats)
+
+	// OnConnClose is called when a local connection was closed
+	OnConnClose func(conn ConnStats)
+
+	// KeepAlive is the interval of keepalive messages sent over an idle session. Zero disables them.
+	KeepAlive time.Duration
+
+	// Reconnect starts a new session after the current one dropped. Without it the forwarder stops
+	// when the session is closed. Errors wrapping ErrTargetUnavailable stop it as well.
+	Reconnect func() (Session, error)
+
+	// OnReconnect is called before each reconnect attempt with the error that caused it
+	OnReconnect func(attempt int, err error)
+}
+
+// Forwarder listens on a local port and forwards every accepted connection over
+// a port forwarding session. Agents that support it multiplex the connections
+// over the session, older agents serve them one after another.
+type Forwarder struct {
+	session Session
+	input   *ForwarderInput
+	ready   chan struct{}
+	addr    net.Addr
+
+	chMu    sync.Mutex
+	ch      *channel
+	chReady chan struct{}
+
+	mu            sync.Mutex
+	nextID        int64
+	conns         map[int64]*trackedConn
+	bytesSent     int64
+	bytesReceived int64
+}
+
+// NewForwarder creates a forwarder for a session started with one of the port forwarding documents.
+func NewForwarder(session Session, input *ForwarderInput) *Forwarder {
+	return &Forwarder{
+		session: session,
+		input:   input,
+		ready:   make(chan struct{}),
+		chReady: make(chan struct{}),
+		conns:   make(map[int64]*trackedConn),
+	}
+}
+
+// Ready is closed once the local listener accepts connections.
+func (f *Forwarder) Ready() <-chan struct{} {
+	return f.ready
+}
+
+// Addr returns the address of the local listener. It is only valid after Ready is closed.
+func (f *Forwarder) Addr() net.Addr {
+	return f.addr
+}
+
+// Stats returns a snapshot of the forwarded connections.
+func (f *Forwarder) Stats() Stats {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	stats := Stats{
+		TotalConns:    f.nextID,
+		ActiveConns:   make([]ConnStats, 0, len(f.conns)),
+		BytesSent:     f.bytesSent,
+		BytesReceived: f.bytesReceived,
+	}
+
+	for _, c := range f.conns {
+		cs := c.stats()
+
+		stats.ActiveConns = append(stats.ActiveConns, cs)
+		stats.BytesSent += cs.BytesSent
+		stats.BytesReceived += cs.BytesReceived
+	}
+
+	sort.Slice(stats.ActiveConns, func(i, j int) bool {
+		return stats.ActiveConns[i].ID < stats.ActiveConns[j].ID
+	})
+
+	return stats
+}
+
+// Start forwards connections until the context is canceled or the session is closed.
+// If Reconnect is set, a dropped session is replaced with a new one while the local
+// listener stays open.
+func (f *Forwarder) Start(ctx context.Context) error {
+	ln, err := net.Listen("tcp", net.JoinHostPort("localhost", f.input.LocalPort))
+	if err != nil {
+		return err
+	}
+	defer ln.Close()
+
+	ch, err := f.connect(f.session, false)
+	if err != nil {
+		return err
+	}
+
+	f.setChannel(ch)
+
+	f.addr = ln.Addr()
+	close(f.ready)
+
+	var wg sync.WaitGroup
+
+	acceptErr := make(chan error, 1)
+
+	go func() {
+		acceptErr <- f.accept(ctx, ln, &wg)
+	}()
+
+	defer func() {
+		ln.Close()
+		f.closeConns()
+		wg.Wait()
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			f.clearChannel()
+			ch.close()
+
+			return nil
+		case err := <-acceptErr:
+			f.clearChannel()
+			ch.close()
+
+			return err
+		case <-ch.dc.Done():
+		}
+
+		f.clearChannel()
+		ch.close()
+		f.closeConns()
+
+		err := ch.dc.Err()
+		if err == nil {
+			err = ErrSessionClosed
+		}
+
+		if f.input.Reconnect == nil {
+			return err
+		}
+
+		ch, err = f.reconnect(ctx, err)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		f.setChannel(ch)
+	}
+}
+
+func (f *Forwarder) accept(ctx context.Context, ln net.Listener, wg *sync.WaitGroup) error {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		tc := f.track(conn)
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer f.untrack(tc)
+
+			ch, err := f.currentChannel(ctx)
+			if err != nil {
+				return
+			}
+
+			ch.handle(tc)
+		}()
+	}
+}
+
+// reconnect obtains a new session with exponential backoff until it succeeds, the
+// target is unavailable or the context is done.
+func (f *Forwarder) reconnect(ctx context.Context, cause error) (*channel, error) {
+	b := backoff.New()
+
+	for {
+		if f.input.OnReconnect != nil {
+			f.input.OnReconnect(b.Attempt()+1, cause)
+		}
+
+		if err := b.Wait(ctx); err != nil {
+			return nil, err
+		}
+
+		session, err := f.input.Reconnect()
+		if err != nil {
+			if errors.Is(err, ErrTargetUnavailable) {
+				return nil, err
+			}
+
+			cause = err
+
+			continue
+		}
+
+		ch, err := f.connect(session, true)
+		if err != nil {
+			_ = session.Close()
+			cause = err
+
+			continue
+		}
+
+		return ch, nil
+	}
+}
+
+// connect opens the data channel of a session. If owned is set, the session is closed with the channel.
+func (f *Forwarder) connect(session Session, owned bool) (*channel, error) {
+	dc, err := session.OpenDataChannel()
+	if err != nil {
+		return nil, err
+	}
+
+	ch := &channel{
+		dc:      dc,
+		stop:    make(chan struct{}),
+		session: session,
+		owned:   owned,
+	}
+
+	if ssm.SupportsMux(dc.AgentVersion()) {
+		cfg := smux.DefaultConfig()
+		cfg.KeepAliveDisabled = ssm.SupportsMuxKeepAliveDisabled(dc.AgentVersion())
+
+		mux, err := smux.Client(dc, cfg)
+		if err != nil {
+			dc.Close()
+			return nil, err
+		}
+
+		ch.mux = mux
+		ch.handle = func(conn *trackedConn) {
+			stream, err := mux.OpenStream()
+			if err != nil {
+				conn.Close()
+				return
+			}
+
+			pipe(conn, stream)
+		}
+	} else {
+		basic := &basicForwarder{dc: dc}
+		go basic.copyToConn()
+
+		ch.handle = basic.serve
+	}
+
+	if f.input.KeepAlive > 0 {
+		go ch.keepAlive(f.input.KeepAlive)
+	}
+
+	return ch, nil
+}
+
+func (f *Forwarder) setChannel(ch *channel) {
+	f.chMu.Lock()
+	defer f.chMu.Unlock()
+
+	f.ch = ch
+	close(f.chReady)
+}
+
+func (f *Forwarder) clearChannel() {
+	f.chMu.Lock()
+	defer f.chMu.Unlock()
+
+	if f.ch != nil {
+		f.ch = nil
+		f.chReady = make(chan struct{})
+	}
+}
+
+// currentChannel returns the open channel and waits while the forwarder reconnects.
+func (f *Forwarder) currentChannel(ctx context.Context) (*channel, error) {
+	for {
+		f.chMu.Lock()
+		ch, ready := f.ch, f.chReady
+		f.chMu.Unlock()
+
+		if ch != nil {
+			return ch, nil
+		}
+
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (f *Forwarder) track(conn net.Conn) *trackedConn {
+	f.mu.Lock()
+
+	f.nextID++
+
+	tc := &trackedConn{
+		Conn:     conn,
+		id:       f.nextID,
+		openedAt: time.Now(),
+	}
+
+	f.conns[tc.id] = tc
+
+	f.mu.Unlock()
+
+	if f.input.OnConnOpen != nil {
+		f.input.OnConnOpen(tc.stats())
+	}
+
+	return tc
+}
+
+func (f *Forwarder) untrack(tc *trackedConn) {
+	tc.Close()
+
+	cs := tc.stats()
+	cs.ClosedAt = time.Now()
+
+	f.mu.Lock()
+
+	delete(f.conns, tc.id)
+
+	f.bytesSent += cs.BytesSent
+	f.bytesReceived += cs.BytesReceived
+
+	f.mu.Unlock()
+
+	if f.input.OnConnClose != nil {
+		f.input.OnConnClose(cs)
+	}
+}
+
+func (f *Forwarder) closeConns() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, c := range f.conns {
+		c.Close()
+	}
+}
+
+// smuxNOP is a smux v1 NOP frame. The agent discards it, but it counts as session activity.
+var smuxNOP = []byte{1, 3, 0, 0, 0, 0, 0, 0}
+
+// channel is the data channel of one session together with the way connections are served over it.
+type channel struct {
+	dc      *ssm.DataChannel
+	mux     *smux.Session
+	handle  func(conn *trackedConn)
+	stop    chan struct{}
+	once    sync.Once
+	session Session
+	owned   bool
+}
+
+func (ch *channel) keepAlive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = ch.dc.Ping()
+
+			if ch.mux != nil {
+				_, _ = ch.dc.Write(smuxNOP)
+			}
+		case <-ch.stop:
+			return
+		case <-ch.dc.Done():
+			return
+		}
+	}
+}
+
+func (ch *channel) close() {
+	ch.once.Do(func() {
+		close(ch.stop)
+
+		if ch.mux != nil {
+			_ = ch.dc.SendFlag(ssm.FlagTerminateSession)
+			ch.mux.Close()
+		}
+
+		ch.dc.Close()
+
+		if ch.owned {
+			_ = ch.session.Close()
+		}
+	})
+}
+
+// trackedConn counts the bytes transferred over a local connection.
+type trackedConn struct {
+	net.Conn
+
+	id       int64
+	openedAt time.Time
+	sent     atomic.Int64
+	received atomic.Int64
+}
+
+func (c *trackedConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.sent.Add(int64(n))
+
+	return n, err
+}
+
+func (c *trackedConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	c.received.Add(int64(n))
+
+	return n, err
+}
+
+func (c *trackedConn) stats() ConnStats {
+	return ConnStats{
+		ID:            c.id,
+		RemoteAddr:    c.RemoteAddr().String(),
+		OpenedAt:      c.openedAt,
+		BytesSent:     c.sent.Load(),
+		BytesReceived: c.received.Load(),
+	}
+}
+
+// pipe copies in both directions until one side is done.
+func pipe(a, b io.ReadWriteCloser) {
+	done := make(chan struct{}, 2)
+
+	go func() {
+		_, _ = io.Copy(a, b)
+		done <- struct{}{}
+	}()
+
+	go func() {
+		_, _ = io.Copy(b, a)
+		done <- struct{}{}
+	}()
+
+	<-done
+
+	a.Close()
+	b.Close()
+
+	<-done
+}
+
+// basicForwarder serves one connection after another over a session without multiplexing.
+type basicForwarder struct {
+	dc *ssm.DataChannel
+
+	serving sync.Mutex
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func (f *basicForwarder) serve(conn *trackedConn) {
+	f.serving.Lock()
+	defer f.serving.Unlock()
+
+	f.mu.Lock()
+	f.conn = conn
+	f.mu.Unlock()
+
+	_, _ = io.Copy(f.dc, conn)
+
+	f.mu.Lock()
+	f.conn = nil
+	f.mu.Unlock()
+
+	conn.Close()
+
+	// Tell the agent to close its connection to the remote port
+	_ = f.dc.SendFlag(ssm.FlagDisconnectToPort)
+}
+
+func (f *basicForwarder) copyToConn() {
+	buf := make([]byte, 32*1024)
+
+	for {
+		n, err := f.dc.Read(buf)
+		if n > 0 {
+			f.mu.Lock()
+			if f.conn != nil {
+				_, _ = f.conn.Write(buf[:n])
+			}
+			f.mu.Unlock()
+		}
+
+		if err != nil {
+			f.mu.Lock()
+			if f.conn != nil {
+				f.conn.Close()
+			}
+			f.mu.Unlock()
+
+			return
+		}
+	}
+}
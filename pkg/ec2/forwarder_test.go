@@ -0,0 +1,262 @@
+package ec2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hupe1980/gotoaws/internal/ssmtest"
+	"github.com/hupe1980/gotoaws/pkg/recording"
+	"github.com/hupe1980/gotoaws/pkg/ssm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xtaci/smux"
+	"golang.org/x/crypto/ssh"
+)
+
+type mockSession struct {
+	streamURL string
+}
+
+func (m *mockSession) Close() error                             { return nil }
+func (m *mockSession) RunShell() error                          { return nil }
+func (m *mockSession) RecordShell(_ *recording.Recorder) error  { return nil }
+func (m *mockSession) RunSSH(_ *RunSSHInput) error              { return nil }
+func (m *mockSession) RunSCP(_ *RunSCPInput) error              { return nil }
+func (m *mockSession) DialSSH(_ *SSHInput) (*ssh.Client, error) { return nil, nil }
+func (m *mockSession) Copy(_ *CopyInput) ([]CopyFile, error)    { return nil, nil }
+func (m *mockSession) OpenDataChannel() (*ssm.DataChannel, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	dc := ssm.NewDataChannel(m.streamURL, "token")
+	if err := dc.Open(ctx); err != nil {
+		return nil, err
+	}
+
+	return dc, nil
+}
+
+func startForwarder(t *testing.T, agent *ssmtest.Agent) (*Forwarder, context.CancelFunc, chan error) {
+	t.Helper()
+
+	fwd := NewForwarder(&mockSession{streamURL: agent.URL()}, &ForwarderInput{LocalPort: "0"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- fwd.Start(ctx)
+	}()
+
+	select {
+	case <-fwd.Ready():
+	case err := <-errCh:
+		t.Fatal(err)
+	}
+
+	return fwd, cancel, errCh
+}
+
+func echo(t *testing.T, addr net.Addr, msg string) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", addr.String())
+	require.NoError(t, err)
+
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(msg))
+	require.NoError(t, err)
+
+	buf := make([]byte, len(msg))
+	_, err = io.ReadFull(conn, buf)
+	require.NoError(t, err)
+	assert.Equal(t, msg, string(buf))
+}
+
+func TestForwarder(t *testing.T) {
+	t.Run("multiplexed", func(t *testing.T) {
+		agent := ssmtest.NewAgent("3.2.0.0", "Port", func(stream *ssmtest.Stream) {
+			cfg := smux.DefaultConfig()
+			cfg.KeepAliveDisabled = true
+
+			mux, err := smux.Server(stream, cfg)
+			if err != nil {
+				return
+			}
+			defer mux.Close()
+
+			for {
+				s, err := mux.AcceptStream()
+				if err != nil {
+					return
+				}
+
+				go func() {
+					_, _ = io.Copy(s, s)
+					s.Close()
+				}()
+			}
+		})
+		defer agent.Close()
+
+		fwd, cancel, errCh := startForwarder(t, agent)
+
+		// hold a connection open while the others are forwarded concurrently
+		held, err := net.Dial("tcp", fwd.Addr().String())
+		require.NoError(t, err)
+
+		var wg sync.WaitGroup
+
+		for _, msg := range []string{"postgres", "redis", "http"} {
+			wg.Add(1)
+
+			go func(msg string) {
+				defer wg.Done()
+				echo(t, fwd.Addr(), msg)
+			}(msg)
+		}
+
+		wg.Wait()
+
+		assert.Eventually(t, func() bool {
+			return len(fwd.Stats().ActiveConns) == 1
+		}, 5*time.Second, 10*time.Millisecond)
+
+		held.Close()
+
+		assert.Eventually(t, func() bool {
+			return len(fwd.Stats().ActiveConns) == 0
+		}, 5*time.Second, 10*time.Millisecond)
+
+		stats := fwd.Stats()
+		assert.Equal(t, int64(4), stats.TotalConns)
+		assert.Equal(t, int64(len("postgresredishttp")), stats.BytesSent)
+		assert.Equal(t, int64(len("postgresredishttp")), stats.BytesReceived)
+
+		cancel()
+		assert.NoError(t, <-errCh)
+	})
+
+	t.Run("basic", func(t *testing.T) {
+		flags := make(chan ssm.Flag, 4)
+
+		agent := ssmtest.NewAgent("2.3.672.0", "Port", func(stream *ssmtest.Stream) {
+			go func() {
+				for f := range stream.Flags() {
+					flags <- f
+				}
+			}()
+
+			_, _ = io.Copy(stream, stream)
+		})
+		defer agent.Close()
+
+		fwd, cancel, errCh := startForwarder(t, agent)
+
+		echo(t, fwd.Addr(), "first")
+
+		select {
+		case f := <-flags:
+			assert.Equal(t, ssm.FlagDisconnectToPort, f)
+		case <-time.After(5 * time.Second):
+			t.Fatal("missing disconnect flag")
+		}
+
+		echo(t, fwd.Addr(), "second")
+
+		cancel()
+		assert.NoError(t, <-errCh)
+		assert.Equal(t, int64(2), fwd.Stats().TotalConns)
+	})
+
+	t.Run("session closed", func(t *testing.T) {
+		agent := ssmtest.NewAgent("3.2.0.0", "Port", func(_ *ssmtest.Stream) {})
+		defer agent.Close()
+
+		_, cancel, errCh := startForwarder(t, agent)
+		defer cancel()
+
+		assert.ErrorIs(t, <-errCh, ErrSessionClosed)
+	})
+
+	t.Run("reconnect", func(t *testing.T) {
+		dropped := ssmtest.NewAgent("3.2.0.0", "Port", func(_ *ssmtest.Stream) {})
+		defer dropped.Close()
+
+		agent := ssmtest.NewAgent("2.3.672.0", "Port", func(stream *ssmtest.Stream) {
+			_, _ = io.Copy(stream, stream)
+		})
+		defer agent.Close()
+
+		attempts := make(chan int, 1)
+
+		fwd := NewForwarder(&mockSession{streamURL: dropped.URL()}, &ForwarderInput{
+			LocalPort: "0",
+			KeepAlive: 10 * time.Millisecond,
+			Reconnect: func() (Session, error) {
+				return &mockSession{streamURL: agent.URL()}, nil
+			},
+			OnReconnect: func(attempt int, err error) {
+				attempts <- attempt
+				assert.ErrorIs(t, err, ErrSessionClosed)
+			},
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		errCh := make(chan error, 1)
+
+		go func() {
+			errCh <- fwd.Start(ctx)
+		}()
+
+		<-fwd.Ready()
+
+		select {
+		case attempt := <-attempts:
+			assert.Equal(t, 1, attempt)
+		case <-time.After(5 * time.Second):
+			t.Fatal("missing reconnect")
+		}
+
+		// the connection waits until the session is restored
+		echo(t, fwd.Addr(), "after reconnect")
+
+		cancel()
+		assert.NoError(t, <-errCh)
+	})
+
+	t.Run("target unavailable", func(t *testing.T) {
+		dropped := ssmtest.NewAgent("3.2.0.0", "Port", func(_ *ssmtest.Stream) {})
+		defer dropped.Close()
+
+		calls := 0
+
+		fwd := NewForwarder(&mockSession{streamURL: dropped.URL()}, &ForwarderInput{
+			LocalPort: "0",
+			Reconnect: func() (Session, error) {
+				calls++
+				return nil, fmt.Errorf("task stopped: %w", ErrTargetUnavailable)
+			},
+		})
+
+		errCh := make(chan error, 1)
+
+		go func() {
+			errCh <- fwd.Start(context.Background())
+		}()
+
+		select {
+		case err := <-errCh:
+			assert.ErrorIs(t, err, ErrTargetUnavailable)
+			assert.Equal(t, 1, calls)
+		case <-time.After(5 * time.Second):
+			t.Fatal("forwarder kept reconnecting")
+		}
+	})
+}
@@ -2,7 +2,7 @@ package ec2
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"net"
 	"strings"
 	"time"
@@ -15,11 +15,43 @@ import (
 	"github.com/hupe1980/gotoaws/pkg/config"
 )
 
+// ErrNoInstances is returned if no ssm managed instance matches.
+var ErrNoInstances = errors.New("no ssm managed instances found")
+
 // An object representing an instance.
 type Instance struct {
-	Name     string
-	ID       string
-	Platform string
+	Name string `json:"name"`
+	ID   string `json:"id"`
+
+	// Account and Region the instance was found in
+	Account string `json:"account,omitempty"`
+	Region  string `json:"region,omitempty"`
+
+	// Platform is Linux, Windows or MacOS
+	Platform string `json:"platform"`
+
+	// State of an ec2 instance, e.g. running
+	State            string    `json:"state,omitempty"`
+	InstanceType     string    `json:"instanceType,omitempty"`
+	AvailabilityZone string    `json:"availabilityZone,omitempty"`
+	PrivateIP        string    `json:"privateIp,omitempty"`
+	PublicIP         string    `json:"publicIp,omitempty"`
+	VpcID            string    `json:"vpcId,omitempty"`
+	SubnetID         string    `json:"subnetId,omitempty"`
+	LaunchTime       time.Time `json:"launchTime,omitempty"`
+
+	// Tags of an ec2 instance
+	Tags map[string]string `json:"tags,omitempty"`
+
+	// PingStatus of the ssm agent, e.g. Online or ConnectionLost
+	PingStatus string `json:"pingStatus,omitempty"`
+
+	// AgentVersion is the version of the ssm agent
+	AgentVersion string `json:"agentVersion,omitempty"`
+
+	// PlatformName and PlatformVersion of the operating system reported by the ssm agent, e.g. Amazon Linux 2023
+	PlatformName    string `json:"platformName,omitempty"`
+	PlatformVersion string `json:"platformVersion,omitempty"`
 }
 
 type InstanceFinder interface {
@@ -29,6 +61,8 @@ type InstanceFinder interface {
 
 type instanceFinder struct {
 	timeout   time.Duration
+	account   string
+	region    string
 	ec2Client aws_ec2.DescribeInstancesAPIClient
 	ssmClient ssm.DescribeInstanceInformationAPIClient
 }
@@ -36,6 +70,8 @@ type instanceFinder struct {
 func NewInstanceFinder(cfg *config.Config) InstanceFinder {
 	return &instanceFinder{
 		timeout:   cfg.Timeout,
+		account:   cfg.Account,
+		region:    cfg.Region,
 		ec2Client: aws_ec2.NewFromConfig(cfg.AWSConfig),
 		ssmClient: ssm.NewFromConfig(cfg.AWSConfig),
 	}
@@ -51,8 +87,11 @@ func (f *instanceFinder) Find() ([]Instance, error) {
 	}
 
 	instanceIDs := []string{}
-	for _, i := range ec2Instances {
-		instanceIDs = append(instanceIDs, *i.InstanceId)
+	infos := make(map[string]*ssmTypes.InstanceInformation, len(ec2Instances))
+
+	for i := range ec2Instances {
+		instanceIDs = append(instanceIDs, *ec2Instances[i].InstanceId)
+		infos[*ec2Instances[i].InstanceId] = &ec2Instances[i]
 	}
 
 	instanceIDFilter := types.Filter{
@@ -80,25 +119,16 @@ func (f *instanceFinder) Find() ([]Instance, error) {
 
 		for _, r := range page.Reservations {
 			for _, inst := range r.Instances {
-				name := ""
-
-				for _, tag := range inst.Tags {
-					if *tag.Key == "Name" {
-						name = *tag.Value
-						break
-					}
-				}
-
-				instances = append(instances, Instance{Name: name, ID: *inst.InstanceId, Platform: platform(inst)})
+				instances = append(instances, newInstance(inst, infos[*inst.InstanceId]))
 			}
 		}
 	}
 
 	for _, mi := range managedInstances {
-		instances = append(instances, Instance{Name: *mi.Name, ID: *mi.InstanceId, Platform: string(mi.PlatformType)})
+		instances = append(instances, newManagedInstance(mi))
 	}
 
-	return instances, nil
+	return f.annotate(instances), nil
 }
 
 func (f *instanceFinder) FindByIdentifier(identifier string) ([]Instance, error) {
@@ -112,7 +142,7 @@ func (f *instanceFinder) FindByIdentifier(identifier string) ([]Instance, error)
 
 	p := aws_ec2.NewDescribeInstancesPaginator(f.ec2Client, input)
 
-	var instances []Instance
+	var ec2Instances []types.Instance
 
 	for p.HasMorePages() {
 		page, err := p.NextPage(ctx)
@@ -122,25 +152,73 @@ func (f *instanceFinder) FindByIdentifier(identifier string) ([]Instance, error)
 
 		for _, r := range page.Reservations {
 			for _, inst := range r.Instances {
-				name := ""
-
-				for _, tag := range inst.Tags {
-					if *tag.Key == "Name" {
-						name = *tag.Value
-						break
-					}
-				}
-
-				instances = append(instances, Instance{Name: name, ID: *inst.InstanceId, Platform: platform(inst)})
+				ec2Instances = append(ec2Instances, inst)
 			}
 		}
 	}
 
-	if len(instances) == 0 {
-		return nil, fmt.Errorf("no ssm managed instances found")
+	if len(ec2Instances) == 0 {
+		return nil, ErrNoInstances
+	}
+
+	infos, err := f.findInstanceInformation(ec2Instances)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]Instance, 0, len(ec2Instances))
+	for _, inst := range ec2Instances {
+		instances = append(instances, newInstance(inst, infos[*inst.InstanceId]))
+	}
+
+	return f.annotate(instances), nil
+}
+
+// annotate sets the account and region of the finder.
+func (f *instanceFinder) annotate(instances []Instance) []Instance {
+	for i := range instances {
+		instances[i].Account = f.account
+		instances[i].Region = f.region
 	}
 
-	return instances, nil
+	return instances
+}
+
+// findInstanceInformation returns the ssm agent information of the instances by id.
+func (f *instanceFinder) findInstanceInformation(ec2Instances []types.Instance) (map[string]*ssmTypes.InstanceInformation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+
+	ids := make([]string, 0, len(ec2Instances))
+	for _, inst := range ec2Instances {
+		ids = append(ids, *inst.InstanceId)
+	}
+
+	infos := make(map[string]*ssmTypes.InstanceInformation, len(ids))
+
+	input := &ssm.DescribeInstanceInformationInput{
+		Filters: []ssmTypes.InstanceInformationStringFilter{{
+			Key:    aws.String(string(ssmTypes.InstanceInformationFilterKeyInstanceIds)),
+			Values: ids,
+		}},
+		MaxResults: aws.Int32(50),
+	}
+
+	p := ssm.NewDescribeInstanceInformationPaginator(f.ssmClient, input)
+
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range page.InstanceInformationList {
+			info := page.InstanceInformationList[i]
+			infos[*info.InstanceId] = &info
+		}
+	}
+
+	return infos, nil
 }
 
 func (f *instanceFinder) findSSMManagedInstances() ([]ssmTypes.InstanceInformation, []ssmTypes.InstanceInformation, error) {
@@ -178,18 +256,79 @@ func (f *instanceFinder) findSSMManagedInstances() ([]ssmTypes.InstanceInformati
 	}
 
 	if len(ec2Instances) == 0 && len(managedInstances) == 0 {
-		return nil, nil, fmt.Errorf("no ssm managed instances found")
+		return nil, nil, ErrNoInstances
 	}
 
 	return ec2Instances, managedInstances, nil
 }
 
+// newInstance merges an ec2 instance with the information of its ssm agent, if any.
+func newInstance(inst types.Instance, info *ssmTypes.InstanceInformation) Instance {
+	instance := Instance{
+		ID:           aws.ToString(inst.InstanceId),
+		Platform:     platform(inst),
+		InstanceType: string(inst.InstanceType),
+		PrivateIP:    aws.ToString(inst.PrivateIpAddress),
+		PublicIP:     aws.ToString(inst.PublicIpAddress),
+		VpcID:        aws.ToString(inst.VpcId),
+		SubnetID:     aws.ToString(inst.SubnetId),
+		LaunchTime:   aws.ToTime(inst.LaunchTime),
+		Tags:         make(map[string]string, len(inst.Tags)),
+	}
+
+	if inst.State != nil {
+		instance.State = string(inst.State.Name)
+	}
+
+	if inst.Placement != nil {
+		instance.AvailabilityZone = aws.ToString(inst.Placement.AvailabilityZone)
+	}
+
+	for _, tag := range inst.Tags {
+		instance.Tags[aws.ToString(tag.Key)] = aws.ToString(tag.Value)
+	}
+
+	instance.Name = instance.Tags["Name"]
+
+	if info != nil {
+		instance.PingStatus = string(info.PingStatus)
+		instance.AgentVersion = aws.ToString(info.AgentVersion)
+		instance.PlatformName = aws.ToString(info.PlatformName)
+		instance.PlatformVersion = aws.ToString(info.PlatformVersion)
+
+		if info.PlatformType != "" {
+			instance.Platform = string(info.PlatformType)
+		}
+	}
+
+	return instance
+}
+
+// newManagedInstance creates an instance for a managed node outside of ec2 (e.g. an on-premises server).
+func newManagedInstance(info ssmTypes.InstanceInformation) Instance {
+	return Instance{
+		Name:            aws.ToString(info.Name),
+		ID:              aws.ToString(info.InstanceId),
+		Platform:        string(info.PlatformType),
+		PrivateIP:       aws.ToString(info.IPAddress),
+		PingStatus:      string(info.PingStatus),
+		AgentVersion:    aws.ToString(info.AgentVersion),
+		PlatformName:    aws.ToString(info.PlatformName),
+		PlatformVersion: aws.ToString(info.PlatformVersion),
+	}
+}
+
 func platform(inst types.Instance) string {
 	if inst.Platform != "" {
-		return string(inst.Platform)
+		return "Windows"
+	}
+
+	// Mac instances (mac1.metal, mac2.metal, mac2-m2pro.metal, ...) report Linux/UNIX as platform details
+	if strings.HasPrefix(string(inst.InstanceType), "mac") {
+		return "MacOS"
 	}
 
-	return "Linux" // TODO MacOS
+	return "Linux"
 }
 
 func parseIdentifier(identifier string) types.Filter {
@@ -9,6 +9,7 @@ import (
 	aws_ec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
 	"github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	ssmTypes "github.com/aws/aws-sdk-go-v2/service/ssm/types"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -21,6 +22,12 @@ func (m *MockEC2Client) DescribeInstances(_ context.Context, _ *aws_ec2.Describe
 	return m.DescribeInstancesOutput, m.DescribeInstancesError
 }
 
+func (m *MockEC2Client) DescribeRegions(_ context.Context, _ *aws_ec2.DescribeRegionsInput, _ ...func(*aws_ec2.Options)) (*aws_ec2.DescribeRegionsOutput, error) {
+	return &aws_ec2.DescribeRegionsOutput{
+		Regions: []types.Region{{RegionName: aws.String("us-east-1")}, {RegionName: aws.String("eu-central-1")}},
+	}, nil
+}
+
 type MockSSMClient struct {
 	DescribeInstanceInformationOutput *ssm.DescribeInstanceInformationOutput
 	DescribeInstanceInformationError  error
@@ -47,9 +54,142 @@ func TestInstanceFinder(t *testing.T) {
 			assert.Equal(t, "no ssm managed instances found", err.Error())
 			assert.Nil(t, instances)
 		})
+
+		t.Run("merges agent information", func(t *testing.T) {
+			finder := &instanceFinder{
+				timeout: time.Second * 15,
+				ec2Client: &MockEC2Client{
+					DescribeInstancesOutput: &aws_ec2.DescribeInstancesOutput{
+						Reservations: []types.Reservation{{Instances: []types.Instance{testInstance}}},
+					},
+				},
+				ssmClient: &MockSSMClient{
+					DescribeInstanceInformationOutput: &ssm.DescribeInstanceInformationOutput{
+						InstanceInformationList: []ssmTypes.InstanceInformation{testInstanceInformation},
+					},
+				},
+			}
+			instances, err := finder.FindByIdentifier("web")
+			assert.NoError(t, err)
+			assert.Equal(t, []Instance{expectedInstance}, instances)
+		})
+
+		t.Run("annotates account and region", func(t *testing.T) {
+			finder := &instanceFinder{
+				timeout: time.Second * 15,
+				account: "123456789012",
+				region:  "eu-central-1",
+				ec2Client: &MockEC2Client{
+					DescribeInstancesOutput: &aws_ec2.DescribeInstancesOutput{
+						Reservations: []types.Reservation{{Instances: []types.Instance{testInstance}}},
+					},
+				},
+				ssmClient: &MockSSMClient{
+					DescribeInstanceInformationOutput: &ssm.DescribeInstanceInformationOutput{},
+				},
+			}
+			instances, err := finder.FindByIdentifier("web")
+			assert.NoError(t, err)
+			assert.Len(t, instances, 1)
+			assert.Equal(t, "123456789012", instances[0].Account)
+			assert.Equal(t, "eu-central-1", instances[0].Region)
+		})
+	})
+
+	t.Run("Find", func(t *testing.T) {
+		finder := &instanceFinder{
+			timeout: time.Second * 15,
+			ec2Client: &MockEC2Client{
+				DescribeInstancesOutput: &aws_ec2.DescribeInstancesOutput{
+					Reservations: []types.Reservation{{Instances: []types.Instance{testInstance}}},
+				},
+			},
+			ssmClient: &MockSSMClient{
+				DescribeInstanceInformationOutput: &ssm.DescribeInstanceInformationOutput{
+					InstanceInformationList: []ssmTypes.InstanceInformation{
+						testInstanceInformation,
+						{
+							InstanceId:   aws.String("mi-0123456789abcdef0"),
+							Name:         aws.String("on-prem"),
+							IPAddress:    aws.String("192.168.1.10"),
+							PingStatus:   ssmTypes.PingStatusOnline,
+							PlatformType: ssmTypes.PlatformTypeLinux,
+							ResourceType: ssmTypes.ResourceTypeManagedInstance,
+						},
+					},
+				},
+			},
+		}
+		instances, err := finder.Find()
+		assert.NoError(t, err)
+		assert.Equal(t, []Instance{
+			expectedInstance,
+			{Name: "on-prem", ID: "mi-0123456789abcdef0", Platform: "Linux", PrivateIP: "192.168.1.10", PingStatus: "Online"},
+		}, instances)
 	})
 }
 
+func TestRegions(t *testing.T) {
+	names, err := regions(context.Background(), &MockEC2Client{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"eu-central-1", "us-east-1"}, names)
+}
+
+var (
+	launchTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	testInstance = types.Instance{
+		InstanceId:       aws.String("i-08d0906a5bd77e96f"),
+		InstanceType:     types.InstanceTypeT3Micro,
+		Placement:        &types.Placement{AvailabilityZone: aws.String("eu-central-1a")},
+		PrivateIpAddress: aws.String("10.0.1.12"),
+		PublicIpAddress:  aws.String("3.120.1.2"),
+		VpcId:            aws.String("vpc-1"),
+		SubnetId:         aws.String("subnet-1"),
+		LaunchTime:       &launchTime,
+		State:            &types.InstanceState{Name: types.InstanceStateNameRunning},
+		Tags: []types.Tag{
+			{Key: aws.String("Name"), Value: aws.String("web")},
+			{Key: aws.String("Env"), Value: aws.String("prod")},
+		},
+	}
+
+	testInstanceInformation = ssmTypes.InstanceInformation{
+		InstanceId:      aws.String("i-08d0906a5bd77e96f"),
+		AgentVersion:    aws.String("3.3.40.0"),
+		PingStatus:      ssmTypes.PingStatusOnline,
+		PlatformName:    aws.String("Amazon Linux"),
+		PlatformVersion: aws.String("2023"),
+		PlatformType:    ssmTypes.PlatformTypeLinux,
+		ResourceType:    ssmTypes.ResourceTypeEc2Instance,
+	}
+
+	expectedInstance = Instance{
+		Name:             "web",
+		ID:               "i-08d0906a5bd77e96f",
+		Platform:         "Linux",
+		State:            "running",
+		InstanceType:     "t3.micro",
+		AvailabilityZone: "eu-central-1a",
+		PrivateIP:        "10.0.1.12",
+		PublicIP:         "3.120.1.2",
+		VpcID:            "vpc-1",
+		SubnetID:         "subnet-1",
+		LaunchTime:       launchTime,
+		Tags:             map[string]string{"Name": "web", "Env": "prod"},
+		PingStatus:       "Online",
+		AgentVersion:     "3.3.40.0",
+		PlatformName:     "Amazon Linux",
+		PlatformVersion:  "2023",
+	}
+)
+
+func TestPlatform(t *testing.T) {
+	assert.Equal(t, "Windows", platform(types.Instance{Platform: types.PlatformValuesWindows}))
+	assert.Equal(t, "MacOS", platform(types.Instance{InstanceType: types.InstanceTypeMac2Metal}))
+	assert.Equal(t, "Linux", platform(types.Instance{InstanceType: types.InstanceTypeT3Micro}))
+}
+
 func TestParseIdentifier(t *testing.T) {
 	t.Run("instance-id", func(t *testing.T) {
 		identifier := "i-08d0906a5bd77e96f"
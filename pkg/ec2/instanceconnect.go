@@ -0,0 +1,141 @@
+package ec2
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
+	"github.com/hupe1980/gotoaws/pkg/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// instanceConnectKeyLifetime is the time a pushed key is accepted by the instance.
+const instanceConnectKeyLifetime = 60 * time.Second
+
+// InstanceConnectClient is the subset of the ec2 instance connect api used to push keys.
+type InstanceConnectClient interface {
+	SendSSHPublicKey(ctx context.Context, params *ec2instanceconnect.SendSSHPublicKeyInput, optFns ...func(*ec2instanceconnect.Options)) (*ec2instanceconnect.SendSSHPublicKeyOutput, error)
+}
+
+// PushKeyInput selects the instance and the os user of an ephemeral key.
+type PushKeyInput struct {
+	InstanceID       string
+	AvailabilityZone string
+	User             string
+
+	// UseAgent adds the key to the ssh agent of SSH_AUTH_SOCK for clients that do not use the signer
+	UseAgent bool
+}
+
+// EphemeralKey is a key pair that only exists for one connection.
+type EphemeralKey struct {
+	// Signer signs with the private key
+	Signer ssh.Signer
+
+	privateKey ed25519.PrivateKey
+	agent      agent.Agent
+	conn       net.Conn
+}
+
+// Close removes the key from the agent.
+func (k *EphemeralKey) Close() error {
+	if k.agent == nil {
+		return nil
+	}
+
+	err := k.agent.Remove(k.Signer.PublicKey())
+
+	if k.conn != nil {
+		_ = k.conn.Close()
+	}
+
+	return err
+}
+
+// PushEphemeralKey generates an ed25519 key pair and pushes the public key with ec2 instance connect.
+// The instance accepts the key for 60 seconds, so the connection must follow immediately.
+func PushEphemeralKey(cfg *config.Config, input *PushKeyInput) (*EphemeralKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	key, err := pushEphemeralKey(ctx, ec2instanceconnect.NewFromConfig(cfg.AWSConfig), input)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.UseAgent {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return nil, errors.New("no ssh agent running (SSH_AUTH_SOCK is not set)")
+		}
+
+		conn, err := net.Dial("unix", sock)
+		if err != nil {
+			return nil, fmt.Errorf("cannot connect to the ssh agent: %w", err)
+		}
+
+		key.conn = conn
+
+		if err := key.addToAgent(agent.NewClient(conn)); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	return key, nil
+}
+
+func pushEphemeralKey(ctx context.Context, client InstanceConnectClient, input *PushKeyInput) (*EphemeralKey, error) {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &ec2instanceconnect.SendSSHPublicKeyInput{
+		InstanceId:     aws.String(input.InstanceID),
+		InstanceOSUser: aws.String(input.User),
+		SSHPublicKey:   aws.String(string(ssh.MarshalAuthorizedKey(signer.PublicKey()))),
+	}
+
+	if input.AvailabilityZone != "" {
+		params.AvailabilityZone = aws.String(input.AvailabilityZone)
+	}
+
+	output, err := client.SendSSHPublicKey(ctx, params)
+	if err != nil {
+		return nil, fmt.Errorf("cannot push key to %s: %w", input.InstanceID, err)
+	}
+
+	if !output.Success {
+		return nil, fmt.Errorf("cannot push key to %s", input.InstanceID)
+	}
+
+	return &EphemeralKey{Signer: signer, privateKey: priv}, nil
+}
+
+func (k *EphemeralKey) addToAgent(a agent.Agent) error {
+	if err := a.Add(agent.AddedKey{
+		PrivateKey:   k.privateKey,
+		Comment:      "gotoaws ephemeral key",
+		LifetimeSecs: uint32(instanceConnectKeyLifetime.Seconds()),
+	}); err != nil {
+		return fmt.Errorf("cannot add key to the ssh agent: %w", err)
+	}
+
+	k.agent = a
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package ec2
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ec2instanceconnect"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+type mockInstanceConnectClient struct {
+	input *ec2instanceconnect.SendSSHPublicKeyInput
+	err   error
+}
+
+func (m *mockInstanceConnectClient) SendSSHPublicKey(ctx context.Context, params *ec2instanceconnect.SendSSHPublicKeyInput, optFns ...func(*ec2instanceconnect.Options)) (*ec2instanceconnect.SendSSHPublicKeyOutput, error) {
+	m.input = params
+	if m.err != nil {
+		return nil, m.err
+	}
+
+	return &ec2instanceconnect.SendSSHPublicKeyOutput{Success: true}, nil
+}
+
+func TestPushEphemeralKey(t *testing.T) {
+	input := &PushKeyInput{InstanceID: "i-123", AvailabilityZone: "eu-central-1a", User: "ec2-user"}
+
+	t.Run("push", func(t *testing.T) {
+		client := &mockInstanceConnectClient{}
+
+		key, err := pushEphemeralKey(context.Background(), client, input)
+		require.NoError(t, err)
+		assert.Equal(t, "i-123", aws.ToString(client.input.InstanceId))
+		assert.Equal(t, "ec2-user", aws.ToString(client.input.InstanceOSUser))
+		assert.Equal(t, "eu-central-1a", aws.ToString(client.input.AvailabilityZone))
+
+		pub, _, _, _, err := ssh.ParseAuthorizedKey([]byte(aws.ToString(client.input.SSHPublicKey)))
+		require.NoError(t, err)
+		assert.Equal(t, ssh.KeyAlgoED25519, pub.Type())
+		assert.Equal(t, key.Signer.PublicKey().Marshal(), pub.Marshal())
+
+		require.NoError(t, key.Close())
+	})
+
+	t.Run("agent", func(t *testing.T) {
+		key, err := pushEphemeralKey(context.Background(), &mockInstanceConnectClient{}, input)
+		require.NoError(t, err)
+
+		keyring := agent.NewKeyring()
+		require.NoError(t, key.addToAgent(keyring))
+
+		keys, err := keyring.List()
+		require.NoError(t, err)
+		require.Len(t, keys, 1)
+		assert.Equal(t, key.Signer.PublicKey().Marshal(), keys[0].Marshal())
+
+		require.NoError(t, key.Close())
+
+		keys, err = keyring.List()
+		require.NoError(t, err)
+		assert.Empty(t, keys)
+	})
+
+	t.Run("denied", func(t *testing.T) {
+		_, err := pushEphemeralKey(context.Background(), &mockInstanceConnectClient{err: errors.New("access denied")}, input)
+		assert.EqualError(t, err, "cannot push key to i-123: access denied")
+	})
+}
@@ -0,0 +1,97 @@
+package ec2
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+// ForwardSpec describes a port forwarding in the style of ssh -L.
+type ForwardSpec struct {
+	// LocalPort is the local port to listen on
+	LocalPort string
+
+	// RemoteHost is the host to forward to. An empty host forwards to the instance itself.
+	RemoteHost string
+
+	// RemotePort is the port to forward to
+	RemotePort string
+}
+
+// ParseForwardSpec parses a spec in the form local:host:remote or local:remote.
+func ParseForwardSpec(spec string) (*ForwardSpec, error) {
+	var parts []string
+
+	if i := strings.Index(spec, "["); i != -1 {
+		// local:[ipv6]:remote
+		j := strings.Index(spec, "]")
+		if j < i || !strings.HasSuffix(spec[:i], ":") || !strings.HasPrefix(spec[j+1:], ":") {
+			return nil, fmt.Errorf("invalid forward spec: %s", spec)
+		}
+
+		parts = []string{spec[:i-1], spec[i+1 : j], spec[j+2:]}
+	} else {
+		parts = strings.Split(spec, ":")
+	}
+
+	var fs *ForwardSpec
+
+	switch len(parts) {
+	case 2:
+		fs = &ForwardSpec{LocalPort: parts[0], RemotePort: parts[1]}
+	case 3:
+		fs = &ForwardSpec{LocalPort: parts[0], RemoteHost: parts[1], RemotePort: parts[2]}
+	default:
+		return nil, fmt.Errorf("invalid forward spec: %s", spec)
+	}
+
+	if !isPort(fs.LocalPort) || !isPort(fs.RemotePort) {
+		return nil, fmt.Errorf("invalid port in forward spec: %s", spec)
+	}
+
+	if fs.RemoteHost == "localhost" || fs.RemoteHost == "127.0.0.1" {
+		fs.RemoteHost = ""
+	}
+
+	return fs, nil
+}
+
+func (fs *ForwardSpec) String() string {
+	if fs.RemoteHost == "" {
+		return fmt.Sprintf("%s:%s", fs.LocalPort, fs.RemotePort)
+	}
+
+	if strings.Contains(fs.RemoteHost, ":") {
+		return fmt.Sprintf("%s:[%s]:%s", fs.LocalPort, fs.RemoteHost, fs.RemotePort)
+	}
+
+	return fmt.Sprintf("%s:%s:%s", fs.LocalPort, fs.RemoteHost, fs.RemotePort)
+}
+
+// StartSessionInput returns the input to start a port forwarding session for the target, an
+// instance ID or the target of an ecs container.
+func (fs *ForwardSpec) StartSessionInput(target string) *ssm.StartSessionInput {
+	docName := "AWS-StartPortForwardingSession"
+	parameters := map[string][]string{
+		"portNumber":      {fs.RemotePort},
+		"localPortNumber": {fs.LocalPort},
+	}
+
+	if fs.RemoteHost != "" {
+		docName = "AWS-StartPortForwardingSessionToRemoteHost"
+		parameters["host"] = []string{fs.RemoteHost}
+	}
+
+	return &ssm.StartSessionInput{
+		DocumentName: &docName,
+		Parameters:   parameters,
+		Target:       &target,
+	}
+}
+
+func isPort(s string) bool {
+	p, err := strconv.Atoi(s)
+	return err == nil && p >= 0 && p <= 65535
+}
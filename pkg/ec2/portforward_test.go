@@ -0,0 +1,52 @@
+package ec2
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseForwardSpec(t *testing.T) {
+	t.Run("remote host", func(t *testing.T) {
+		spec, err := ParseForwardSpec("5432:db.xxx.rds.amazonaws.com:5432")
+		assert.NoError(t, err)
+		assert.Equal(t, &ForwardSpec{LocalPort: "5432", RemoteHost: "db.xxx.rds.amazonaws.com", RemotePort: "5432"}, spec)
+		assert.Equal(t, "5432:db.xxx.rds.amazonaws.com:5432", spec.String())
+
+		input := spec.StartSessionInput("i-123456789")
+		assert.Equal(t, "AWS-StartPortForwardingSessionToRemoteHost", *input.DocumentName)
+		assert.Equal(t, []string{"db.xxx.rds.amazonaws.com"}, input.Parameters["host"])
+		assert.Equal(t, []string{"5432"}, input.Parameters["portNumber"])
+		assert.Equal(t, "i-123456789", *input.Target)
+	})
+
+	t.Run("instance", func(t *testing.T) {
+		spec, err := ParseForwardSpec("8080:80")
+		assert.NoError(t, err)
+		assert.Equal(t, &ForwardSpec{LocalPort: "8080", RemotePort: "80"}, spec)
+
+		input := spec.StartSessionInput("i-123456789")
+		assert.Equal(t, "AWS-StartPortForwardingSession", *input.DocumentName)
+		assert.NotContains(t, input.Parameters, "host")
+	})
+
+	t.Run("localhost", func(t *testing.T) {
+		spec, err := ParseForwardSpec("8080:localhost:80")
+		assert.NoError(t, err)
+		assert.Equal(t, "", spec.RemoteHost)
+	})
+
+	t.Run("ipv6", func(t *testing.T) {
+		spec, err := ParseForwardSpec("6379:[fd00::1]:6379")
+		assert.NoError(t, err)
+		assert.Equal(t, "fd00::1", spec.RemoteHost)
+		assert.Equal(t, "6379:[fd00::1]:6379", spec.String())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, s := range []string{"", "8080", "a:b:c", "1:2:3:4", "8080:host:99999", "8080:[::1"} {
+			_, err := ParseForwardSpec(s)
+			assert.Error(t, err, s)
+		}
+	})
+}
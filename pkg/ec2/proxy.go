@@ -0,0 +1,27 @@
+package ec2
+
+import (
+	"io"
+)
+
+// Proxy bridges stdin and stdout to the data channel of an AWS-StartSSHSession session,
+// like a ProxyCommand of ssh. It returns when either side is closed.
+func Proxy(dc io.ReadWriteCloser, stdin io.Reader, stdout io.Writer) error {
+	errCh := make(chan error, 2)
+
+	go func() {
+		_, err := io.Copy(dc, stdin)
+		errCh <- err
+	}()
+
+	go func() {
+		_, err := io.Copy(stdout, dc)
+		errCh <- err
+	}()
+
+	err := <-errCh
+
+	_ = dc.Close()
+
+	return err
+}
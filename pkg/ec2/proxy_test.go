@@ -0,0 +1,47 @@
+package ec2
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hupe1980/gotoaws/internal/ssmtest"
+	"github.com/hupe1980/gotoaws/pkg/ssm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy(t *testing.T) {
+	agent := ssmtest.NewAgent("3.2.0.0", "Port", func(stream *ssmtest.Stream) {
+		b := make([]byte, 5)
+		if _, err := io.ReadFull(stream, b); err != nil {
+			return
+		}
+
+		_, _ = stream.Write(bytes.ToUpper(b))
+		stream.Close()
+	})
+	defer agent.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	dc := ssm.NewDataChannel(agent.URL(), "token")
+	require.NoError(t, dc.Open(ctx))
+
+	stdinReader, stdinWriter := io.Pipe()
+	defer stdinWriter.Close()
+
+	var stdout bytes.Buffer
+
+	go func() {
+		_, _ = io.Copy(stdinWriter, strings.NewReader("hello"))
+	}()
+
+	// The proxy returns when the session is closed by the instance
+	require.NoError(t, Proxy(dc, stdinReader, &stdout))
+	assert.Equal(t, "HELLO", stdout.String())
+}
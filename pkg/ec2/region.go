@@ -0,0 +1,39 @@
+package ec2
+
+import (
+	"context"
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aws_ec2 "github.com/aws/aws-sdk-go-v2/service/ec2"
+	"github.com/hupe1980/gotoaws/pkg/config"
+)
+
+// RegionClient is the subset of the ec2 api used to list regions.
+type RegionClient interface {
+	DescribeRegions(ctx context.Context, params *aws_ec2.DescribeRegionsInput, optFns ...func(*aws_ec2.Options)) (*aws_ec2.DescribeRegionsOutput, error)
+}
+
+// Regions returns the names of the regions enabled for the account of the config.
+func Regions(cfg *config.Config) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	return regions(ctx, aws_ec2.NewFromConfig(cfg.AWSConfig))
+}
+
+func regions(ctx context.Context, client RegionClient) ([]string, error) {
+	output, err := client.DescribeRegions(ctx, &aws_ec2.DescribeRegionsInput{})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(output.Regions))
+	for _, r := range output.Regions {
+		names = append(names, aws.ToString(r.RegionName))
+	}
+
+	sort.Strings(names)
+
+	return names, nil
+}
@@ -2,7 +2,9 @@ package ec2
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 	"time"
 
@@ -12,74 +14,274 @@ import (
 	"github.com/hupe1980/gotoaws/pkg/config"
 )
 
-type CommandRunner struct {
-	client     *ssm.Client
-	instanceID string
-	output     *ssm.SendCommandOutput
-	timeout    time.Duration
+// InvocationStatus summarizes the result of a command on one instance.
+type InvocationStatus string
+
+const (
+	InvocationStatusSuccess InvocationStatus = "Success"
+	InvocationStatusFailed  InvocationStatus = "Failed"
+	InvocationStatusTimeout InvocationStatus = "Timeout"
+)
+
+// InvocationResult is the result of a command on one instance.
+type InvocationResult struct {
+	InstanceID   string           `json:"instanceId"`
+	InstanceName string           `json:"instanceName"`
+	Status       InvocationStatus `json:"status"`
+
+	// StatusDetails is the detailed status reported by ssm, e.g. ExecutionTimedOut or Undeliverable
+	StatusDetails string `json:"statusDetails"`
+
+	// ResponseCode is the exit code of the command, -1 if it did not finish
+	ResponseCode int32 `json:"exitCode"`
+
+	// Output and Error are the (truncated) stdout and stderr of the command
+	Output string `json:"stdout"`
+	Error  string `json:"stderr"`
 }
 
-func NewCommandRunner(cfg *config.Config, inst *Instance, command []string) (*CommandRunner, error) {
-	client := ssm.NewFromConfig(cfg.AWSConfig)
-	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+// CommandClient is the subset of the ssm api used to run commands.
+type CommandClient interface {
+	SendCommand(ctx context.Context, params *ssm.SendCommandInput, optFns ...func(*ssm.Options)) (*ssm.SendCommandOutput, error)
+	ListCommands(ctx context.Context, params *ssm.ListCommandsInput, optFns ...func(*ssm.Options)) (*ssm.ListCommandsOutput, error)
+	ssm.ListCommandInvocationsAPIClient
+	GetCommandInvocation(ctx context.Context, params *ssm.GetCommandInvocationInput, optFns ...func(*ssm.Options)) (*ssm.GetCommandInvocationOutput, error)
+}
 
-	defer cancel()
+type RunCommandInput struct {
+	// Command to run
+	Command []string
+
+	// InstanceIDs of the instances to run the command on
+	InstanceIDs []string
+
+	// Tags selects the instances by tag. All tags must match.
+	Tags map[string]string
 
-	// only linux support (window = "AWS-RunPowerShellScript")
-	docName := "AWS-RunShellScript"
+	// All runs the command on all managed instances
+	All bool
 
-	if inst.Platform == "Windows" {
-		docName = "AWS-RunPowerShellScript"
+	// DocumentName overrides the document. The default is AWS-RunShellScript.
+	DocumentName string
+
+	// MaxConcurrency is the number (e.g. 10) or percentage (e.g. 10%) of instances running the command at the same time
+	MaxConcurrency string
+
+	// MaxErrors is the number or percentage of errors after which no further instances receive the command
+	MaxErrors string
+}
+
+type CommandRunner struct {
+	client       CommandClient
+	commandID    string
+	timeout      time.Duration
+	pollInterval time.Duration
+}
+
+func NewCommandRunner(cfg *config.Config, input *RunCommandInput) (*CommandRunner, error) {
+	return newCommandRunner(ssm.NewFromConfig(cfg.AWSConfig), cfg.Timeout, input)
+}
+
+func newCommandRunner(client CommandClient, timeout time.Duration, input *RunCommandInput) (*CommandRunner, error) {
+	docName := input.DocumentName
+	if docName == "" {
+		docName = "AWS-RunShellScript"
 	}
 
-	input := &ssm.SendCommandInput{
+	sendInput := &ssm.SendCommandInput{
 		DocumentName:   &docName,
-		InstanceIds:    []string{inst.ID},
 		TimeoutSeconds: aws.Int32(60), // 60 seconds
 		CloudWatchOutputConfig: &types.CloudWatchOutputConfig{
 			CloudWatchOutputEnabled: true,
 		},
 		Parameters: map[string][]string{
-			"commands": {strings.Join(command, " ")},
+			"commands": {strings.Join(input.Command, " ")},
 		},
 	}
 
-	output, err := client.SendCommand(ctx, input)
+	switch {
+	case input.All:
+		sendInput.Targets = []types.Target{{Key: aws.String("InstanceIds"), Values: []string{"*"}}}
+	case len(input.Tags) > 0:
+		keys := make([]string, 0, len(input.Tags))
+		for k := range input.Tags {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			sendInput.Targets = append(sendInput.Targets, types.Target{
+				Key:    aws.String(fmt.Sprintf("tag:%s", k)),
+				Values: []string{input.Tags[k]},
+			})
+		}
+	case len(input.InstanceIDs) > 0:
+		sendInput.InstanceIds = input.InstanceIDs
+	default:
+		return nil, errors.New("no instances to run the command on")
+	}
+
+	if input.MaxConcurrency != "" {
+		sendInput.MaxConcurrency = &input.MaxConcurrency
+	}
+
+	if input.MaxErrors != "" {
+		sendInput.MaxErrors = &input.MaxErrors
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	output, err := client.SendCommand(ctx, sendInput)
 	if err != nil {
 		return nil, err
 	}
 
 	return &CommandRunner{
-		client:     client,
-		instanceID: inst.ID,
-		output:     output,
-		timeout:    cfg.Timeout,
+		client:       client,
+		commandID:    *output.Command.CommandId,
+		timeout:      timeout,
+		pollInterval: time.Second,
 	}, nil
 }
 
-func (cmd *CommandRunner) Result() (string, error) {
-	input := &ssm.GetCommandInvocationInput{
-		CommandId:  cmd.output.Command.CommandId,
-		InstanceId: &cmd.instanceID,
+// CommandID returns the id of the sent command.
+func (cmd *CommandRunner) CommandID() string {
+	return cmd.commandID
+}
+
+// Results waits until the command finished on all instances. Each result is passed to
+// fn as soon as the instance completed. The results are returned in order of completion.
+func (cmd *CommandRunner) Results(fn func(res InvocationResult)) ([]InvocationResult, error) {
+	var results []InvocationResult
+
+	done := make(map[string]bool)
+
+	for {
+		time.Sleep(cmd.pollInterval)
+
+		// Fetch the command status first, so that no invocation completing in between is missed
+		finished, err := cmd.finished()
+		if err != nil {
+			return nil, err
+		}
+
+		invocations, err := cmd.invocations()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, inv := range invocations {
+			id := aws.ToString(inv.InstanceId)
+			if done[id] || !invocationFinished(inv.Status) {
+				continue
+			}
+
+			res, err := cmd.result(inv)
+			if err != nil {
+				return nil, err
+			}
+
+			done[id] = true
+			results = append(results, res)
+
+			if fn != nil {
+				fn(res)
+			}
+		}
+
+		if finished {
+			return results, nil
+		}
 	}
+}
 
+func (cmd *CommandRunner) finished() (bool, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), cmd.timeout)
 	defer cancel()
 
-	for {
-		time.Sleep(1 * time.Second)
+	output, err := cmd.client.ListCommands(ctx, &ssm.ListCommandsInput{CommandId: &cmd.commandID})
+	if err != nil {
+		return false, err
+	}
+
+	if len(output.Commands) == 0 {
+		return false, fmt.Errorf("command %s not found", cmd.commandID)
+	}
+
+	switch output.Commands[0].Status {
+	case types.CommandStatusSuccess, types.CommandStatusFailed, types.CommandStatusTimedOut, types.CommandStatusCancelled:
+		return true, nil
+	default:
+		return false, nil
+	}
+}
+
+func (cmd *CommandRunner) invocations() ([]types.CommandInvocation, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cmd.timeout)
+	defer cancel()
+
+	p := ssm.NewListCommandInvocationsPaginator(cmd.client, &ssm.ListCommandInvocationsInput{
+		CommandId: &cmd.commandID,
+	})
 
-		output, err := cmd.client.GetCommandInvocation(ctx, input)
+	var invocations []types.CommandInvocation
+
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
 		if err != nil {
-			return "", err
+			return nil, err
 		}
 
-		switch output.Status {
-		case types.CommandInvocationStatusPending, types.CommandInvocationStatusInProgress, types.CommandInvocationStatusDelayed:
-		case types.CommandInvocationStatusSuccess:
-			return *output.StandardOutputContent, nil
-		case types.CommandInvocationStatusCancelled, types.CommandInvocationStatusCancelling, types.CommandInvocationStatusFailed, types.CommandInvocationStatusTimedOut:
-			return "", fmt.Errorf(*output.StandardErrorContent)
-		}
+		invocations = append(invocations, page.CommandInvocations...)
+	}
+
+	return invocations, nil
+}
+
+func (cmd *CommandRunner) result(inv types.CommandInvocation) (InvocationResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), cmd.timeout)
+	defer cancel()
+
+	output, err := cmd.client.GetCommandInvocation(ctx, &ssm.GetCommandInvocationInput{
+		CommandId:  &cmd.commandID,
+		InstanceId: inv.InstanceId,
+	})
+	if err != nil {
+		return InvocationResult{}, err
+	}
+
+	res := InvocationResult{
+		InstanceID:    aws.ToString(inv.InstanceId),
+		InstanceName:  aws.ToString(inv.InstanceName),
+		StatusDetails: aws.ToString(output.StatusDetails),
+		ResponseCode:  output.ResponseCode,
+		Output:        aws.ToString(output.StandardOutputContent),
+		Error:         aws.ToString(output.StandardErrorContent),
+	}
+
+	switch {
+	case inv.Status == types.CommandInvocationStatusSuccess:
+		res.Status = InvocationStatusSuccess
+	case inv.Status == types.CommandInvocationStatusTimedOut || strings.HasSuffix(res.StatusDetails, "TimedOut"):
+		res.Status = InvocationStatusTimeout
+	default:
+		res.Status = InvocationStatusFailed
+	}
+
+	if res.Status != InvocationStatusSuccess && res.Error == "" {
+		res.Error = res.StatusDetails
+	}
+
+	return res, nil
+}
+
+func invocationFinished(status types.CommandInvocationStatus) bool {
+	switch status {
+	case types.CommandInvocationStatusSuccess, types.CommandInvocationStatusFailed, types.CommandInvocationStatusTimedOut, types.CommandInvocationStatusCancelled:
+		return true
+	default:
+		return false
 	}
 }
@@ -0,0 +1,112 @@
+package ec2
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/aws/aws-sdk-go-v2/service/ssm/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// MockCommandClient replays one ListCommands and ListCommandInvocations output per poll.
+type MockCommandClient struct {
+	SendCommandInput *ssm.SendCommandInput
+	CommandStatus    []types.CommandStatus
+	Invocations      [][]types.CommandInvocation
+	Details          map[string]*ssm.GetCommandInvocationOutput
+
+	poll int
+}
+
+func (m *MockCommandClient) SendCommand(_ context.Context, params *ssm.SendCommandInput, _ ...func(*ssm.Options)) (*ssm.SendCommandOutput, error) {
+	m.SendCommandInput = params
+	return &ssm.SendCommandOutput{Command: &types.Command{CommandId: aws.String("cmd-1")}}, nil
+}
+
+func (m *MockCommandClient) ListCommands(_ context.Context, _ *ssm.ListCommandsInput, _ ...func(*ssm.Options)) (*ssm.ListCommandsOutput, error) {
+	return &ssm.ListCommandsOutput{Commands: []types.Command{{Status: m.CommandStatus[m.poll]}}}, nil
+}
+
+func (m *MockCommandClient) ListCommandInvocations(_ context.Context, _ *ssm.ListCommandInvocationsInput, _ ...func(*ssm.Options)) (*ssm.ListCommandInvocationsOutput, error) {
+	invocations := m.Invocations[m.poll]
+	m.poll++
+
+	return &ssm.ListCommandInvocationsOutput{CommandInvocations: invocations}, nil
+}
+
+func (m *MockCommandClient) GetCommandInvocation(_ context.Context, params *ssm.GetCommandInvocationInput, _ ...func(*ssm.Options)) (*ssm.GetCommandInvocationOutput, error) {
+	return m.Details[*params.InstanceId], nil
+}
+
+func invocation(id string, status types.CommandInvocationStatus) types.CommandInvocation {
+	return types.CommandInvocation{InstanceId: aws.String(id), InstanceName: aws.String(id + ".local"), Status: status}
+}
+
+func TestCommandRunner(t *testing.T) {
+	t.Run("targets", func(t *testing.T) {
+		client := &MockCommandClient{}
+
+		_, err := newCommandRunner(client, time.Second, &RunCommandInput{
+			Command:        []string{"uptime"},
+			Tags:           map[string]string{"Role": "web", "Env": "prod"},
+			MaxConcurrency: "25%",
+		})
+		require.NoError(t, err)
+
+		input := client.SendCommandInput
+		assert.Nil(t, input.InstanceIds)
+		assert.Equal(t, []types.Target{
+			{Key: aws.String("tag:Env"), Values: []string{"prod"}},
+			{Key: aws.String("tag:Role"), Values: []string{"web"}},
+		}, input.Targets)
+		assert.Equal(t, "25%", *input.MaxConcurrency)
+		assert.Nil(t, input.MaxErrors)
+		assert.Equal(t, []string{"uptime"}, input.Parameters["commands"])
+
+		_, err = newCommandRunner(client, time.Second, &RunCommandInput{Command: []string{"uptime"}, All: true})
+		require.NoError(t, err)
+		assert.Equal(t, []types.Target{{Key: aws.String("InstanceIds"), Values: []string{"*"}}}, client.SendCommandInput.Targets)
+
+		_, err = newCommandRunner(client, time.Second, &RunCommandInput{Command: []string{"uptime"}})
+		assert.Error(t, err)
+	})
+
+	t.Run("results", func(t *testing.T) {
+		client := &MockCommandClient{
+			CommandStatus: []types.CommandStatus{types.CommandStatusInProgress, types.CommandStatusInProgress, types.CommandStatusFailed},
+			Invocations: [][]types.CommandInvocation{
+				{invocation("i-1", types.CommandInvocationStatusSuccess), invocation("i-2", types.CommandInvocationStatusInProgress)},
+				{invocation("i-1", types.CommandInvocationStatusSuccess), invocation("i-2", types.CommandInvocationStatusFailed), invocation("i-3", types.CommandInvocationStatusInProgress)},
+				{invocation("i-1", types.CommandInvocationStatusSuccess), invocation("i-2", types.CommandInvocationStatusFailed), invocation("i-3", types.CommandInvocationStatusTimedOut)},
+			},
+			Details: map[string]*ssm.GetCommandInvocationOutput{
+				"i-1": {StandardOutputContent: aws.String("up 3 days"), StatusDetails: aws.String("Success")},
+				"i-2": {StandardErrorContent: aws.String("permission denied"), StatusDetails: aws.String("Failed"), ResponseCode: 1},
+				"i-3": {StatusDetails: aws.String("ExecutionTimedOut"), ResponseCode: -1},
+			},
+		}
+
+		runner, err := newCommandRunner(client, time.Second, &RunCommandInput{Command: []string{"uptime"}, InstanceIDs: []string{"i-1", "i-2", "i-3"}})
+		require.NoError(t, err)
+
+		runner.pollInterval = time.Millisecond
+
+		var streamed []string
+
+		results, err := runner.Results(func(res InvocationResult) {
+			streamed = append(streamed, res.InstanceID)
+		})
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"i-1", "i-2", "i-3"}, streamed)
+		assert.Equal(t, []InvocationResult{
+			{InstanceID: "i-1", InstanceName: "i-1.local", Status: InvocationStatusSuccess, StatusDetails: "Success", Output: "up 3 days"},
+			{InstanceID: "i-2", InstanceName: "i-2.local", Status: InvocationStatusFailed, StatusDetails: "Failed", ResponseCode: 1, Error: "permission denied"},
+			{InstanceID: "i-3", InstanceName: "i-3.local", Status: InvocationStatusTimeout, StatusDetails: "ExecutionTimedOut", ResponseCode: -1, Error: "ExecutionTimedOut"},
+		}, results)
+	})
+}
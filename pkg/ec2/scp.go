@@ -0,0 +1,35 @@
+package ec2
+
+import "github.com/pkg/sftp"
+
+type SCPMode string
+
+const (
+	SCPModeSending   SCPMode = "sending"
+	SCPModeReceiving SCPMode = "receiving"
+)
+
+type RunSCPInput struct {
+	SSHInput
+
+	// Sources are local files when sending and remote files when receiving
+	Sources []string
+
+	// Target is a file or a directory. It must be a directory for more than one source.
+	Target string
+
+	Mode SCPMode
+}
+
+// copySCP copies the sources of the input like Copy without options. New targets get
+// the mode of their source like with scp.
+func copySCP(client *sftp.Client, input *RunSCPInput) error {
+	_, err := Copy(client, &CopyInput{
+		SSHInput: input.SSHInput,
+		Sources:  input.Sources,
+		Target:   input.Target,
+		Mode:     input.Mode,
+	})
+
+	return err
+}
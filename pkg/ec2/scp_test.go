@@ -0,0 +1,125 @@
+package ec2
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/sftp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSFTPClient serves the local filesystem to a sftp client over pipes.
+func newSFTPClient(t *testing.T) *sftp.Client {
+	t.Helper()
+
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+
+	server, err := sftp.NewServer(struct {
+		io.Reader
+		io.WriteCloser
+	}{serverReader, serverWriter})
+	require.NoError(t, err)
+
+	go func() { _ = server.Serve() }()
+
+	client, err := sftp.NewClientPipe(clientReader, clientWriter)
+	require.NoError(t, err)
+
+	t.Cleanup(func() {
+		server.Close()
+		client.Close()
+	})
+
+	return client
+}
+
+func writeFile(t *testing.T, name, content string, perm os.FileMode) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(name, []byte(content), perm))
+}
+
+func assertFile(t *testing.T, name, content string, perm os.FileMode) {
+	t.Helper()
+
+	b, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, content, string(b))
+
+	info, err := os.Stat(name)
+	require.NoError(t, err)
+	assert.Equal(t, perm, info.Mode().Perm())
+}
+
+func TestCopySCP(t *testing.T) {
+	client := newSFTPClient(t)
+
+	t.Run("send to file", func(t *testing.T) {
+		dir := t.TempDir()
+		src := filepath.Join(dir, "my notes.txt")
+		dst := filepath.Join(dir, "remote notes.txt")
+
+		writeFile(t, src, "notes", 0o640)
+
+		require.NoError(t, copySCP(client, &RunSCPInput{Sources: []string{src}, Target: dst, Mode: SCPModeSending}))
+		assertFile(t, dst, "notes", 0o640)
+	})
+
+	t.Run("send to directory", func(t *testing.T) {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "remote dir")
+
+		require.NoError(t, os.Mkdir(target, 0o755))
+		writeFile(t, filepath.Join(dir, "a.txt"), "a", 0o600)
+		writeFile(t, filepath.Join(dir, "b.sh"), "b", 0o700)
+
+		err := copySCP(client, &RunSCPInput{
+			Sources: []string{filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.sh")},
+			Target:  target,
+			Mode:    SCPModeSending,
+		})
+		require.NoError(t, err)
+
+		assertFile(t, filepath.Join(target, "a.txt"), "a", 0o600)
+		assertFile(t, filepath.Join(target, "b.sh"), "b", 0o700)
+	})
+
+	t.Run("receive to directory", func(t *testing.T) {
+		dir := t.TempDir()
+		target := filepath.Join(dir, "local dir")
+
+		require.NoError(t, os.Mkdir(target, 0o755))
+		writeFile(t, filepath.Join(dir, "remote file.log"), "log", 0o644)
+
+		err := copySCP(client, &RunSCPInput{
+			Sources: []string{filepath.Join(dir, "remote file.log")},
+			Target:  target,
+			Mode:    SCPModeReceiving,
+		})
+		require.NoError(t, err)
+
+		assertFile(t, filepath.Join(target, "remote file.log"), "log", 0o644)
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "a"), "a", 0o600)
+		writeFile(t, filepath.Join(dir, "b"), "b", 0o600)
+
+		err := copySCP(client, &RunSCPInput{
+			Sources: []string{filepath.Join(dir, "a"), filepath.Join(dir, "b")},
+			Target:  filepath.Join(dir, "c"),
+			Mode:    SCPModeSending,
+		})
+		assert.ErrorContains(t, err, "is not a directory")
+
+		err = copySCP(client, &RunSCPInput{Sources: []string{dir}, Target: filepath.Join(dir, "d"), Mode: SCPModeReceiving})
+		assert.ErrorContains(t, err, "is a directory")
+
+		err = copySCP(client, &RunSCPInput{Target: dir, Mode: SCPModeSending})
+		assert.Error(t, err)
+	})
+}
@@ -3,43 +3,26 @@ package ec2
 import (
 	"context"
 	"fmt"
-	"strings"
+	"net"
+	"os"
 
 	aws_ssm "github.com/aws/aws-sdk-go-v2/service/ssm"
-	"github.com/hupe1980/gotoaws/internal/exec"
 	"github.com/hupe1980/gotoaws/pkg/config"
+	"github.com/hupe1980/gotoaws/pkg/recording"
 	"github.com/hupe1980/gotoaws/pkg/ssm"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
 )
 
-type SCPMode string
-
-const (
-	SCPModeSending   SCPMode = "sending"
-	SCPModeReceiving SCPMode = "receiving"
-)
-
-type RunSSHInput struct {
-	User                string
-	InstanceID          string
-	Identity            string
-	LocalPortForwarding string
-	Command             string
-}
-
-type RunSCPInput struct {
-	User       string
-	InstanceID string
-	Identity   string
-	Sources    []string
-	Target     string
-	Mode       SCPMode
-}
-
 type Session interface {
 	Close() error
-	RunPlugin() error
+	RunShell() error
+	RecordShell(rec *recording.Recorder) error
+	OpenDataChannel() (*ssm.DataChannel, error)
+	DialSSH(input *SSHInput) (*ssh.Client, error)
 	RunSSH(input *RunSSHInput) error
 	RunSCP(input *RunSCPInput) error
+	Copy(input *CopyInput) ([]CopyFile, error)
 }
 
 type session struct {
@@ -65,7 +48,6 @@ func NewSession(cfg *config.Config, input *aws_ssm.StartSessionInput) (Session,
 			SSMClient:  ssmClient,
 			Input:      input,
 			Profile:    cfg.Profile,
-			Plugin:     cfg.Plugin,
 			Region:     cfg.AWSConfig.Region,
 			Timeout:    cfg.Timeout,
 		},
@@ -76,71 +58,72 @@ func (sess *session) Close() error {
 	return sess.ssmSession.Close()
 }
 
-func (sess *session) RunPlugin() error {
-	return sess.ssmSession.RunPlugin()
+func (sess *session) RunShell() error {
+	return sess.ssmSession.RunShell()
 }
 
-func (sess *session) RunSSH(input *RunSSHInput) error {
-	pc, err := sess.ssmSession.ProxyCommand()
-	if err != nil {
-		return err
-	}
+func (sess *session) RecordShell(rec *recording.Recorder) error {
+	return sess.ssmSession.RecordShell(rec)
+}
 
-	args := []string{"-o", pc}
+func (sess *session) OpenDataChannel() (*ssm.DataChannel, error) {
+	return sess.ssmSession.OpenDataChannel()
+}
 
-	for _, sep := range strings.Split(sshArgs(input), " ") {
-		if sep != "" {
-			args = append(args, sep)
-		}
+// DialSSH connects a ssh client over the data channel. The session must be started with the AWS-StartSSHSession document.
+func (sess *session) DialSSH(input *SSHInput) (*ssh.Client, error) {
+	dc, err := sess.ssmSession.OpenDataChannel()
+	if err != nil {
+		return nil, err
 	}
 
-	cmd := exec.NewCmd()
+	client, err := NewSSHClient(&dataChannelConn{DataChannel: dc, addr: sshAddr(net.JoinHostPort(input.InstanceID, "22"))}, input)
+	if err != nil {
+		dc.Close()
+		return nil, err
+	}
 
-	return cmd.InteractiveRun("ssh", args...)
+	return client, nil
 }
 
-func (sess *session) RunSCP(input *RunSCPInput) error {
-	pc, err := sess.ssmSession.ProxyCommand()
+func (sess *session) RunSSH(input *RunSSHInput) error {
+	client, err := sess.DialSSH(&input.SSHInput)
 	if err != nil {
 		return err
 	}
+	defer client.Close()
 
-	args := []string{"-o", pc}
-
-	for _, sep := range strings.Split(scpArgs(input), " ") {
-		if sep != "" {
-			args = append(args, sep)
-		}
-	}
-
-	cmd := exec.NewCmd()
-
-	return cmd.InteractiveRun("scp", args...)
+	return RunSSH(client, input, os.Stdin, os.Stdout, os.Stderr)
 }
 
-func sshArgs(input *RunSSHInput) string {
-	ssh := fmt.Sprintf("-i %s %s@%s", input.Identity, input.User, input.InstanceID)
-	if input.LocalPortForwarding != "" {
-		ssh = fmt.Sprintf("-L %s %s", input.LocalPortForwarding, ssh)
+func (sess *session) RunSCP(input *RunSCPInput) error {
+	client, err := sess.DialSSH(&input.SSHInput)
+	if err != nil {
+		return err
 	}
+	defer client.Close()
 
-	if input.Command != "" {
-		ssh = fmt.Sprintf("%s %s", ssh, input.Command)
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return fmt.Errorf("cannot start sftp: %w", err)
 	}
+	defer sftpClient.Close()
 
-	return ssh
+	return copySCP(sftpClient, input)
 }
 
-func scpArgs(input *RunSCPInput) string {
-	if input.Mode == SCPModeSending {
-		return fmt.Sprintf("-i %s %s %s@%s:%s", input.Identity, strings.Join(input.Sources, " "), input.User, input.InstanceID, input.Target)
+func (sess *session) Copy(input *CopyInput) ([]CopyFile, error) {
+	client, err := sess.DialSSH(&input.SSHInput)
+	if err != nil {
+		return nil, err
 	}
+	defer client.Close()
 
-	s := input.Sources[0]
-
-	if len(input.Sources) > 1 {
-		s = fmt.Sprintf("{%s}", strings.Join(input.Sources, ","))
+	sftpClient, err := sftp.NewClient(client)
+	if err != nil {
+		return nil, fmt.Errorf("cannot start sftp: %w", err)
 	}
+	defer sftpClient.Close()
 
-	return fmt.Sprintf("-i %s %s@%s:%s %s", input.Identity, input.User, input.InstanceID, s, input.Target)
+	return Copy(sftpClient, input)
 }
@@ -0,0 +1,403 @@
+package ec2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hupe1980/gotoaws/pkg/ssm"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+	"golang.org/x/term"
+)
+
+// SSHInput authenticates a ssh connection to an instance.
+type SSHInput struct {
+	User       string
+	InstanceID string
+
+	// Identity is the path of a private key file
+	Identity string
+
+	// Signers are used in addition to the identity and the keys of the ssh agent, e.g. an ephemeral key
+	Signers []ssh.Signer
+
+	// KnownHosts is the file of the trusted host keys. The default is ~/.ssh/known_hosts.
+	// Host keys are stored by instance ID and trusted on first use.
+	KnownHosts string
+
+	// StrictHostKeyChecking rejects unknown host keys instead of adding them to KnownHosts
+	StrictHostKeyChecking bool
+}
+
+type RunSSHInput struct {
+	SSHInput
+
+	// LocalForwards are forwarded like -L [bind_address:]port:host:hostport
+	LocalForwards []string
+
+	// Command runs instead of a login shell
+	Command string
+
+	// NoCommand only forwards the ports until the session is interrupted
+	NoCommand bool
+
+	// ForwardAgent forwards the ssh agent of SSH_AUTH_SOCK to the instance
+	ForwardAgent bool
+}
+
+// NewSSHClient authenticates a ssh connection over conn, e.g. the data channel of an AWS-StartSSHSession session.
+func NewSSHClient(conn net.Conn, input *SSHInput) (*ssh.Client, error) {
+	signers, closeAgent, err := sshSigners(input)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAgent()
+
+	knownHosts := input.KnownHosts
+	if knownHosts == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, err
+		}
+
+		knownHosts = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	cfg := &ssh.ClientConfig{
+		User:            input.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signers...)},
+		HostKeyCallback: knownHostsCallback(knownHosts, input.StrictHostKeyChecking),
+		Timeout:         30 * time.Second,
+	}
+
+	c, chans, reqs, err := ssh.NewClientConn(conn, net.JoinHostPort(input.InstanceID, "22"), cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.NewClient(c, chans, reqs), nil
+}
+
+// sshSigners returns the keys of the input and the ssh agent. The agent must stay connected until
+// the authentication is done.
+func sshSigners(input *SSHInput) ([]ssh.Signer, func(), error) {
+	signers := append([]ssh.Signer{}, input.Signers...)
+
+	if input.Identity != "" {
+		signer, err := readIdentity(input.Identity)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		signers = append(signers, signer)
+	}
+
+	if sock := os.Getenv("SSH_AUTH_SOCK"); sock != "" {
+		if conn, err := net.Dial("unix", sock); err == nil {
+			if agentSigners, err := agent.NewClient(conn).Signers(); err == nil {
+				signers = append(signers, agentSigners...)
+			}
+
+			return signers, func() { _ = conn.Close() }, nil
+		}
+	}
+
+	if len(signers) == 0 {
+		return nil, nil, errors.New("no identity and no ssh agent available")
+	}
+
+	return signers, func() {}, nil
+}
+
+func readIdentity(path string) (ssh.Signer, error) {
+	b, err := os.ReadFile(path) // nolint: gosec // path is provided by the user
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := ssh.ParsePrivateKey(b)
+
+	var missing *ssh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return signer, err
+	}
+
+	fmt.Fprintf(os.Stderr, "Enter passphrase for key '%s': ", path)
+
+	passphrase, err := term.ReadPassword(int(os.Stdin.Fd()))
+
+	fmt.Fprintln(os.Stderr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ssh.ParsePrivateKeyWithPassphrase(b, passphrase)
+}
+
+// knownHostsCallback verifies the host key against the known_hosts file. Unknown hosts
+// are added to the file unless strict, changed keys are rejected.
+func knownHostsCallback(path string, strict bool) ssh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+				return err
+			}
+
+			if err := os.WriteFile(path, nil, 0o600); err != nil {
+				return err
+			}
+		}
+
+		callback, err := knownhosts.New(path)
+		if err != nil {
+			return err
+		}
+
+		err = callback(hostname, remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("host key of %s does not match %s:%d, the instance may have been replaced or the connection intercepted",
+				hostname, keyErr.Want[0].Filename, keyErr.Want[0].Line)
+		}
+
+		fingerprint := ssh.FingerprintSHA256(key)
+
+		if strict {
+			return fmt.Errorf("host key of %s is unknown (%s %s) and strict host key checking is enabled", hostname, key.Type(), fingerprint)
+		}
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600) // nolint: gosec // known_hosts file
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if _, err := fmt.Fprintln(f, knownhosts.Line([]string{knownhosts.Normalize(hostname)}, key)); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Warning: Permanently added %s (%s %s) to %s\n", hostname, key.Type(), fingerprint, path)
+
+		return nil
+	}
+}
+
+// RunSSH runs the command or an interactive shell of the input and forwards the local ports.
+func RunSSH(client *ssh.Client, input *RunSSHInput, stdin io.Reader, stdout, stderr io.Writer) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	for _, spec := range input.LocalForwards {
+		ln, err := ForwardLocal(ctx, client, spec)
+		if err != nil {
+			return err
+		}
+		defer ln.Close()
+	}
+
+	if input.NoCommand {
+		sigs := make(chan os.Signal, 1)
+		signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+		defer signal.Stop(sigs)
+
+		done := make(chan error, 1)
+		go func() { done <- client.Wait() }()
+
+		select {
+		case <-sigs:
+			return nil
+		case err := <-done:
+			return err
+		}
+	}
+
+	session, err := client.NewSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	if input.ForwardAgent {
+		sock := os.Getenv("SSH_AUTH_SOCK")
+		if sock == "" {
+			return errors.New("no ssh agent to forward (SSH_AUTH_SOCK is not set)")
+		}
+
+		if err := agent.ForwardToRemote(client, sock); err != nil {
+			return err
+		}
+
+		if err := agent.RequestAgentForwarding(session); err != nil {
+			return err
+		}
+	}
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	// Like ssh, a terminal is only allocated for an interactive shell
+	if f, ok := stdin.(*os.File); ok && input.Command == "" && term.IsTerminal(int(f.Fd())) {
+		fd := int(f.Fd())
+
+		cols, rows, err := term.GetSize(fd)
+		if err != nil {
+			cols, rows = 80, 24
+		}
+
+		if err := requestPTY(session, cols, rows); err != nil {
+			return err
+		}
+
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			_ = term.Restore(fd, state)
+		}()
+
+		stop := ssm.WatchTerminalSize(fd, func(cols, rows int) {
+			_ = session.WindowChange(rows, cols)
+		})
+		defer stop()
+	}
+
+	if input.Command == "" {
+		if err := session.Shell(); err != nil {
+			return err
+		}
+
+		return session.Wait()
+	}
+
+	return session.Run(input.Command)
+}
+
+func requestPTY(session *ssh.Session, cols, rows int) error {
+	termType := os.Getenv("TERM")
+	if termType == "" {
+		termType = "xterm-256color"
+	}
+
+	return session.RequestPty(termType, rows, cols, ssh.TerminalModes{
+		ssh.ECHO:          1,
+		ssh.TTY_OP_ISPEED: 14400,
+		ssh.TTY_OP_OSPEED: 14400,
+	})
+}
+
+// ForwardLocal listens on the local address of spec ([bind_address:]port:host:hostport) and
+// forwards every connection to host:hostport from the instance until ctx is done.
+func ForwardLocal(ctx context.Context, client *ssh.Client, spec string) (net.Listener, error) {
+	local, remote, err := parseLocalForward(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	ln, err := net.Listen("tcp", local)
+	if err != nil {
+		return nil, err
+	}
+
+	var once sync.Once
+
+	stop := func() {
+		once.Do(func() { _ = ln.Close() })
+	}
+
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	go func() {
+		defer stop()
+
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				target, err := client.Dial("tcp", remote)
+				if err != nil {
+					conn.Close()
+					return
+				}
+
+				pipe(conn, target)
+			}()
+		}
+	}()
+
+	return ln, nil
+}
+
+// parseLocalForward splits [bind_address:]port:host:hostport into the local and remote address.
+func parseLocalForward(spec string) (string, string, error) {
+	bind, rest := "localhost", spec
+
+	prefix := spec
+	if i := strings.Index(spec, "["); i != -1 {
+		prefix = spec[:i]
+	}
+
+	if n := strings.Count(prefix, ":"); (prefix == spec && n == 3) || (prefix != spec && n == 2) {
+		i := strings.Index(spec, ":")
+		bind, rest = spec[:i], spec[i+1:]
+
+		if bind == "*" {
+			bind = ""
+		}
+	}
+
+	fs, err := ParseForwardSpec(rest)
+	if err != nil {
+		return "", "", err
+	}
+
+	host := fs.RemoteHost
+	if host == "" {
+		host = "localhost"
+	}
+
+	return net.JoinHostPort(bind, fs.LocalPort), net.JoinHostPort(host, fs.RemotePort), nil
+}
+
+// dataChannelConn is a net.Conn over the data channel of a ssh session.
+type dataChannelConn struct {
+	*ssm.DataChannel
+	addr sshAddr
+}
+
+func (c *dataChannelConn) LocalAddr() net.Addr                { return sshAddr("localhost:0") }
+func (c *dataChannelConn) RemoteAddr() net.Addr               { return c.addr }
+func (c *dataChannelConn) SetDeadline(_ time.Time) error      { return nil }
+func (c *dataChannelConn) SetReadDeadline(_ time.Time) error  { return nil }
+func (c *dataChannelConn) SetWriteDeadline(_ time.Time) error { return nil }
+
+// sshAddr is the address of an instance, e.g. i-123:22.
+type sshAddr string
+
+func (a sshAddr) Network() string { return "ssm" }
+func (a sshAddr) String() string  { return string(a) }
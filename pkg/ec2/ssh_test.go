@@ -0,0 +1,461 @@
+package ec2
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hupe1980/gotoaws/internal/ssmtest"
+	"github.com/hupe1980/gotoaws/pkg/ssm"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// sshServer is an in-process ssh server for the session stream of an ssmtest agent.
+type sshServer struct {
+	config *ssh.ServerConfig
+
+	mu            sync.Mutex
+	pty           string
+	windowChanges []string
+}
+
+func newSSHServer(t *testing.T, authorized ssh.PublicKey) *sshServer {
+	s := &sshServer{
+		config: &ssh.ServerConfig{
+			PublicKeyCallback: func(_ ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+				if bytes.Equal(key.Marshal(), authorized.Marshal()) {
+					return nil, nil
+				}
+
+				return nil, fmt.Errorf("unauthorized key")
+			},
+		},
+	}
+
+	s.config.AddHostKey(newSigner(t))
+
+	return s
+}
+
+func (s *sshServer) serve(stream io.ReadWriteCloser) {
+	conn, chans, reqs, err := ssh.NewServerConn(&streamConn{ReadWriteCloser: stream}, s.config)
+	if err != nil {
+		stream.Close()
+		return
+	}
+	defer conn.Close()
+
+	go ssh.DiscardRequests(reqs)
+
+	for newCh := range chans {
+		switch newCh.ChannelType() {
+		case "session":
+			ch, reqs, err := newCh.Accept()
+			if err != nil {
+				return
+			}
+
+			go s.session(conn, ch, reqs)
+		case "direct-tcpip":
+			var payload struct {
+				Host       string
+				Port       uint32
+				OriginHost string
+				OriginPort uint32
+			}
+
+			if err := ssh.Unmarshal(newCh.ExtraData(), &payload); err != nil {
+				_ = newCh.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+
+			target, err := net.Dial("tcp", net.JoinHostPort(payload.Host, fmt.Sprint(payload.Port)))
+			if err != nil {
+				_ = newCh.Reject(ssh.ConnectionFailed, err.Error())
+				continue
+			}
+
+			ch, reqs, err := newCh.Accept()
+			if err != nil {
+				target.Close()
+				continue
+			}
+
+			go ssh.DiscardRequests(reqs)
+			go pipe(ch, target)
+		default:
+			_ = newCh.Reject(ssh.UnknownChannelType, "unsupported")
+		}
+	}
+}
+
+func (s *sshServer) session(conn *ssh.ServerConn, ch ssh.Channel, reqs <-chan *ssh.Request) {
+	defer ch.Close()
+
+	exit := func(status uint32) {
+		_, _ = ch.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+	}
+
+	for req := range reqs {
+		switch req.Type {
+		case "pty-req":
+			var pty struct {
+				Term          string
+				Cols, Rows    uint32
+				Width, Height uint32
+				Modes         string
+			}
+
+			_ = ssh.Unmarshal(req.Payload, &pty)
+
+			s.mu.Lock()
+			s.pty = fmt.Sprintf("%s %dx%d", pty.Term, pty.Cols, pty.Rows)
+			s.mu.Unlock()
+
+			_ = req.Reply(true, nil)
+		case "window-change":
+			var size struct{ Cols, Rows, Width, Height uint32 }
+
+			_ = ssh.Unmarshal(req.Payload, &size)
+
+			s.mu.Lock()
+			s.windowChanges = append(s.windowChanges, fmt.Sprintf("%dx%d", size.Cols, size.Rows))
+			s.mu.Unlock()
+		case "auth-agent-req@openssh.com":
+			_ = req.Reply(true, nil)
+		case "shell":
+			_ = req.Reply(true, nil)
+
+			line, _ := readLine(ch)
+			fmt.Fprintf(ch, "shell: %s\n", line)
+			exit(0)
+
+			return
+		case "exec":
+			var cmd struct{ Command string }
+
+			_ = ssh.Unmarshal(req.Payload, &cmd)
+			_ = req.Reply(true, nil)
+
+			switch {
+			case cmd.Command == "list-agent-keys":
+				agentCh, agentReqs, err := conn.OpenChannel("auth-agent@openssh.com", nil)
+				if err != nil {
+					fmt.Fprintf(ch.Stderr(), "%v\n", err)
+					exit(1)
+
+					return
+				}
+
+				go ssh.DiscardRequests(agentReqs)
+
+				keys, err := agent.NewClient(agentCh).List()
+				agentCh.Close()
+
+				if err != nil {
+					exit(1)
+					return
+				}
+
+				for _, k := range keys {
+					fmt.Fprintln(ch, k.Comment)
+				}
+
+				exit(0)
+			case strings.HasPrefix(cmd.Command, "exit "):
+				var status uint32
+
+				_, _ = fmt.Sscan(strings.TrimPrefix(cmd.Command, "exit "), &status)
+				exit(status)
+			default:
+				fmt.Fprintf(ch, "ran: %s\n", cmd.Command)
+				exit(0)
+			}
+
+			return
+		default:
+			_ = req.Reply(false, nil)
+		}
+	}
+}
+
+func readLine(r io.Reader) (string, error) {
+	var line []byte
+
+	b := make([]byte, 1)
+
+	for {
+		if _, err := r.Read(b); err != nil {
+			return string(line), err
+		}
+
+		if b[0] == '\n' {
+			return string(line), nil
+		}
+
+		line = append(line, b[0])
+	}
+}
+
+// streamConn is a net.Conn over the session stream of the agent.
+type streamConn struct {
+	io.ReadWriteCloser
+}
+
+func (c *streamConn) LocalAddr() net.Addr                { return sshAddr("agent:22") }
+func (c *streamConn) RemoteAddr() net.Addr               { return sshAddr("client:0") }
+func (c *streamConn) SetDeadline(_ time.Time) error      { return nil }
+func (c *streamConn) SetReadDeadline(_ time.Time) error  { return nil }
+func (c *streamConn) SetWriteDeadline(_ time.Time) error { return nil }
+
+func newSigner(t *testing.T) ssh.Signer {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+
+	return signer
+}
+
+// dialSSH connects over a data channel to the ssh server behind an ssmtest agent.
+func dialSSH(t *testing.T, server *sshServer, input *SSHInput) (*ssh.Client, error) {
+	t.Helper()
+
+	agent := ssmtest.NewAgent("3.2.0.0", "Port", func(stream *ssmtest.Stream) {
+		server.serve(stream)
+	})
+	t.Cleanup(agent.Close)
+
+	dc := ssm.NewDataChannel(agent.URL(), "token")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	require.NoError(t, dc.Open(ctx))
+
+	client, err := NewSSHClient(&dataChannelConn{DataChannel: dc, addr: sshAddr(input.InstanceID + ":22")}, input)
+	if err != nil {
+		dc.Close()
+		return nil, err
+	}
+
+	t.Cleanup(func() { client.Close() })
+
+	return client, nil
+}
+
+func TestSSHClient(t *testing.T) {
+	t.Setenv("SSH_AUTH_SOCK", "")
+
+	key := newSigner(t)
+	server := newSSHServer(t, key.PublicKey())
+	knownHosts := filepath.Join(t.TempDir(), "known_hosts")
+
+	input := &SSHInput{User: "ec2-user", InstanceID: "i-123", Signers: []ssh.Signer{key}, KnownHosts: knownHosts}
+
+	client, err := dialSSH(t, server, input)
+	require.NoError(t, err)
+
+	t.Run("command", func(t *testing.T) {
+		var stdout bytes.Buffer
+
+		err := RunSSH(client, &RunSSHInput{SSHInput: *input, Command: "uname -a"}, strings.NewReader(""), &stdout, io.Discard)
+		require.NoError(t, err)
+		assert.Equal(t, "ran: uname -a\n", stdout.String())
+	})
+
+	t.Run("exit status", func(t *testing.T) {
+		err := RunSSH(client, &RunSSHInput{SSHInput: *input, Command: "exit 3"}, strings.NewReader(""), io.Discard, io.Discard)
+
+		var exitErr *ssh.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, 3, exitErr.ExitStatus())
+	})
+
+	t.Run("shell", func(t *testing.T) {
+		var stdout bytes.Buffer
+
+		err := RunSSH(client, &RunSSHInput{SSHInput: *input}, strings.NewReader("ls\n"), &stdout, io.Discard)
+		require.NoError(t, err)
+		assert.Equal(t, "shell: ls\n", stdout.String())
+	})
+
+	t.Run("pty", func(t *testing.T) {
+		t.Setenv("TERM", "xterm")
+
+		session, err := client.NewSession()
+		require.NoError(t, err)
+
+		defer session.Close()
+
+		require.NoError(t, requestPTY(session, 120, 40))
+		require.NoError(t, session.WindowChange(50, 132))
+		require.NoError(t, session.Run("true"))
+
+		server.mu.Lock()
+		defer server.mu.Unlock()
+
+		assert.Equal(t, "xterm 120x40", server.pty)
+		assert.Equal(t, []string{"132x50"}, server.windowChanges)
+	})
+
+	t.Run("local forward", func(t *testing.T) {
+		target, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+
+		defer target.Close()
+
+		go func() {
+			for {
+				conn, err := target.Accept()
+				if err != nil {
+					return
+				}
+
+				go func() {
+					_, _ = io.Copy(conn, conn)
+					conn.Close()
+				}()
+			}
+		}()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		ln, err := ForwardLocal(ctx, client, "127.0.0.1:0:"+target.Addr().String())
+		require.NoError(t, err)
+
+		echo(t, ln.Addr(), "first")
+		echo(t, ln.Addr(), "second")
+	})
+
+	t.Run("known hosts", func(t *testing.T) {
+		b, err := os.ReadFile(knownHosts)
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(string(b), "i-123 ssh-ed25519 "), string(b))
+
+		// Another host key for the same instance is rejected
+		_, err = dialSSH(t, newSSHServer(t, key.PublicKey()), input)
+		assert.ErrorContains(t, err, "host key of i-123:22 does not match")
+
+		// The key of another instance is added
+		other := *input
+		other.InstanceID = "i-456"
+
+		_, err = dialSSH(t, newSSHServer(t, key.PublicKey()), &other)
+		require.NoError(t, err)
+
+		b, err = os.ReadFile(knownHosts)
+		require.NoError(t, err)
+		assert.Equal(t, 2, strings.Count(string(b), "\n"))
+	})
+
+	t.Run("strict host key checking", func(t *testing.T) {
+		strict := *input
+		strict.InstanceID = "i-789"
+		strict.StrictHostKeyChecking = true
+
+		_, err := dialSSH(t, newSSHServer(t, key.PublicKey()), &strict)
+		assert.ErrorContains(t, err, "host key of i-789:22 is unknown (ssh-ed25519 SHA256:")
+
+		b, err := os.ReadFile(knownHosts)
+		require.NoError(t, err)
+		assert.NotContains(t, string(b), "i-789")
+
+		// Known hosts are still accepted
+		_, err = dialSSH(t, server, &SSHInput{User: "ec2-user", InstanceID: "i-123", Signers: []ssh.Signer{key}, KnownHosts: knownHosts, StrictHostKeyChecking: true})
+		require.NoError(t, err)
+	})
+
+	t.Run("unauthorized", func(t *testing.T) {
+		unauthorized := *input
+		unauthorized.Signers = []ssh.Signer{newSigner(t)}
+
+		_, err := dialSSH(t, server, &unauthorized)
+		assert.ErrorContains(t, err, "unable to authenticate")
+	})
+}
+
+func TestSSHAgent(t *testing.T) {
+	keyring := agent.NewKeyring()
+
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	require.NoError(t, keyring.Add(agent.AddedKey{PrivateKey: priv, Comment: "agent key"}))
+
+	sock := filepath.Join(t.TempDir(), "agent.sock")
+
+	ln, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+
+	defer ln.Close()
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				_ = agent.ServeAgent(keyring, conn)
+				conn.Close()
+			}()
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", sock)
+
+	signer, err := ssh.NewSignerFromKey(priv)
+	require.NoError(t, err)
+
+	input := &SSHInput{User: "ec2-user", InstanceID: "i-123", KnownHosts: filepath.Join(t.TempDir(), "known_hosts")}
+
+	// The key of the agent authenticates
+	client, err := dialSSH(t, newSSHServer(t, signer.PublicKey()), input)
+	require.NoError(t, err)
+
+	var stdout bytes.Buffer
+
+	err = RunSSH(client, &RunSSHInput{SSHInput: *input, Command: "list-agent-keys", ForwardAgent: true}, strings.NewReader(""), &stdout, io.Discard)
+	require.NoError(t, err)
+	assert.Equal(t, "agent key\n", stdout.String())
+}
+
+func TestParseLocalForward(t *testing.T) {
+	tests := []struct {
+		spec   string
+		local  string
+		remote string
+	}{
+		{"8080:intra.example.com:80", "localhost:8080", "intra.example.com:80"},
+		{"0.0.0.0:8080:intra.example.com:80", "0.0.0.0:8080", "intra.example.com:80"},
+		{"*:8080:localhost:80", ":8080", "localhost:80"},
+		{"6379:[fd00::1]:6379", "localhost:6379", "[fd00::1]:6379"},
+		{"127.0.0.1:6379:[fd00::1]:6379", "127.0.0.1:6379", "[fd00::1]:6379"},
+	}
+
+	for _, tt := range tests {
+		local, remote, err := parseLocalForward(tt.spec)
+		require.NoError(t, err, tt.spec)
+		assert.Equal(t, tt.local, local, tt.spec)
+		assert.Equal(t, tt.remote, remote, tt.spec)
+	}
+
+	_, _, err := parseLocalForward("8080")
+	assert.Error(t, err)
+}
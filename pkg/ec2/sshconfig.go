@@ -0,0 +1,154 @@
+package ec2
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+const (
+	sshConfigBegin = "# BEGIN gotoaws managed block"
+	sshConfigEnd   = "# END gotoaws managed block"
+)
+
+// SSHConfigHost is a Host entry of the ssh config that connects through gotoaws.
+type SSHConfigHost struct {
+	Alias      string
+	InstanceID string
+	User       string
+
+	// IdentityFile is optional
+	IdentityFile string
+
+	// ProxyCommand bridges ssh to the session, e.g. gotoaws ec2 proxy --target %h --port %p
+	ProxyCommand string
+}
+
+// DefaultSSHConfig returns the path of the ssh config of the user.
+func DefaultSSHConfig() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(home, ".ssh", "config"), nil
+}
+
+var invalidAliasChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// SSHHostAliases returns a host alias for every instance. The alias is the name of
+// the instance, or its ID if it has no name. Names of more than one instance get the
+// ID appended.
+func SSHHostAliases(instances []Instance, prefix string) []string {
+	names := make([]string, len(instances))
+	count := make(map[string]int)
+
+	for i, inst := range instances {
+		names[i] = strings.Trim(invalidAliasChars.ReplaceAllString(inst.Name, "-"), "-")
+		count[names[i]]++
+	}
+
+	aliases := make([]string, len(instances))
+
+	for i, inst := range instances {
+		switch {
+		case names[i] == "":
+			aliases[i] = prefix + inst.ID
+		case count[names[i]] > 1:
+			aliases[i] = fmt.Sprintf("%s%s-%s", prefix, names[i], inst.ID)
+		default:
+			aliases[i] = prefix + names[i]
+		}
+	}
+
+	return aliases
+}
+
+// RenderSSHConfig returns the managed block of the hosts.
+func RenderSSHConfig(hosts []SSHConfigHost) string {
+	var b strings.Builder
+
+	fmt.Fprintln(&b, sshConfigBegin)
+	fmt.Fprintln(&b, "# Generated by gotoaws ec2 ssh-config, changes are overwritten")
+
+	for _, h := range hosts {
+		fmt.Fprintf(&b, "Host %s\n", h.Alias)
+		fmt.Fprintf(&b, "  HostName %s\n", h.InstanceID)
+
+		if h.User != "" {
+			fmt.Fprintf(&b, "  User %s\n", h.User)
+		}
+
+		if h.IdentityFile != "" {
+			fmt.Fprintf(&b, "  IdentityFile %s\n", h.IdentityFile)
+		}
+
+		fmt.Fprintf(&b, "  ProxyCommand %s\n", h.ProxyCommand)
+	}
+
+	fmt.Fprintln(&b, sshConfigEnd)
+
+	return b.String()
+}
+
+// WriteSSHConfig replaces the managed block of the ssh config at path with the hosts,
+// or removes it if there are no hosts. A new block is added at the top, where its
+// entries take precedence over patterns like Host *. It reports whether the file changed.
+func WriteSSHConfig(path string, hosts []SSHConfigHost) (bool, error) {
+	b, err := os.ReadFile(path) // nolint: gosec // ssh config of the user
+	if err != nil && !os.IsNotExist(err) {
+		return false, err
+	}
+
+	block := ""
+	if len(hosts) > 0 {
+		block = RenderSSHConfig(hosts)
+	}
+
+	content, err := replaceSSHConfigBlock(string(b), block)
+	if err != nil {
+		return false, err
+	}
+
+	if content == string(b) {
+		return false, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return false, err
+	}
+
+	return true, os.WriteFile(path, []byte(content), 0o600)
+}
+
+func replaceSSHConfigBlock(content, block string) (string, error) {
+	begin := strings.Index(content, sshConfigBegin+"\n")
+	end := strings.Index(content, sshConfigEnd+"\n")
+
+	if begin == -1 && end == -1 {
+		if block == "" {
+			return content, nil
+		}
+
+		if content == "" {
+			return block, nil
+		}
+
+		return block + "\n" + content, nil
+	}
+
+	if begin == -1 || end < begin {
+		return "", fmt.Errorf("the gotoaws block of the ssh config is incomplete, remove it manually")
+	}
+
+	rest := content[end+len(sshConfigEnd)+1:]
+
+	// The separating blank line of a removed block is removed as well
+	if block == "" {
+		rest = strings.TrimPrefix(rest, "\n")
+	}
+
+	return content[:begin] + block + rest, nil
+}
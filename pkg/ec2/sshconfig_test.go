@@ -0,0 +1,98 @@
+package ec2
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSSHHostAliases(t *testing.T) {
+	instances := []Instance{
+		{ID: "i-1", Name: "prod web"},
+		{ID: "i-2", Name: "worker"},
+		{ID: "i-3", Name: "worker"},
+		{ID: "i-4"},
+		{ID: "i-5", Name: "*db*"},
+	}
+
+	assert.Equal(t, []string{"aws-prod-web", "aws-worker-i-2", "aws-worker-i-3", "aws-i-4", "aws-db"}, SSHHostAliases(instances, "aws-"))
+}
+
+func TestWriteSSHConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".ssh", "config")
+
+	hosts := []SSHConfigHost{{
+		Alias:        "web",
+		InstanceID:   "i-1",
+		User:         "ec2-user",
+		ProxyCommand: "gotoaws ec2 proxy --target %h --port %p --region eu-west-1",
+	}}
+
+	block := `# BEGIN gotoaws managed block
+# Generated by gotoaws ec2 ssh-config, changes are overwritten
+Host web
+  HostName i-1
+  User ec2-user
+  ProxyCommand gotoaws ec2 proxy --target %h --port %p --region eu-west-1
+# END gotoaws managed block
+`
+
+	t.Run("new file", func(t *testing.T) {
+		changed, err := WriteSSHConfig(path, hosts)
+		require.NoError(t, err)
+		assert.True(t, changed)
+
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, block, string(b))
+	})
+
+	t.Run("existing config", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("Host *\n  ServerAliveInterval 60\n"), 0o600))
+
+		changed, err := WriteSSHConfig(path, hosts)
+		require.NoError(t, err)
+		assert.True(t, changed)
+
+		changed, err = WriteSSHConfig(path, hosts)
+		require.NoError(t, err)
+		assert.False(t, changed)
+
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, block+"\nHost *\n  ServerAliveInterval 60\n", string(b))
+	})
+
+	t.Run("update", func(t *testing.T) {
+		updated := append(hosts, SSHConfigHost{Alias: "db", InstanceID: "i-2", IdentityFile: "~/.ssh/db.pem", ProxyCommand: "gotoaws ec2 proxy --target %h --port %p"})
+
+		changed, err := WriteSSHConfig(path, updated)
+		require.NoError(t, err)
+		assert.True(t, changed)
+
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, RenderSSHConfig(updated)+"\nHost *\n  ServerAliveInterval 60\n", string(b))
+		assert.Contains(t, string(b), "Host db\n  HostName i-2\n  IdentityFile ~/.ssh/db.pem\n")
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		changed, err := WriteSSHConfig(path, nil)
+		require.NoError(t, err)
+		assert.True(t, changed)
+
+		b, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "Host *\n  ServerAliveInterval 60\n", string(b))
+	})
+
+	t.Run("incomplete block", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(path, []byte("# BEGIN gotoaws managed block\nHost web\n"), 0o600))
+
+		_, err := WriteSSHConfig(path, hosts)
+		assert.ErrorContains(t, err, "incomplete")
+	})
+}
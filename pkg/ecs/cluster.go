@@ -0,0 +1,224 @@
+package ecs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/arn"
+	aws_ecs "github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/hupe1980/gotoaws/pkg/config"
+)
+
+// ErrNoClusters is returned if no active cluster is found.
+var ErrNoClusters = errors.New("no ecs clusters found")
+
+// An object representing an ECS cluster.
+type Cluster struct {
+	// The Amazon Resource Name (ARN) of the cluster.
+	ARN string `json:"arn"`
+
+	// The name of the cluster.
+	Name string `json:"name"`
+
+	// RunningTasks is the number of running tasks of the cluster
+	RunningTasks int32 `json:"runningTasks"`
+
+	// ActiveServices is the number of active services of the cluster
+	ActiveServices int32 `json:"activeServices"`
+}
+
+// An object representing an ECS service.
+type Service struct {
+	// The Amazon Resource Name (ARN) of the service.
+	ARN string `json:"arn"`
+
+	// The name of the service.
+	Name string `json:"name"`
+
+	// The name of the cluster.
+	Cluster string `json:"cluster"`
+
+	// TaskDefinition as family:revision
+	TaskDefinition string `json:"taskDefinition,omitempty"`
+
+	// DesiredCount and RunningCount of the tasks of the service
+	DesiredCount int32 `json:"desiredCount"`
+	RunningCount int32 `json:"runningCount"`
+
+	// ExecEnabled reports whether execute command is enabled for new tasks of the service
+	ExecEnabled bool `json:"execEnabled"`
+}
+
+type ClusterClient interface {
+	aws_ecs.ListClustersAPIClient
+	DescribeClusters(ctx context.Context, params *aws_ecs.DescribeClustersInput, optFns ...func(*aws_ecs.Options)) (*aws_ecs.DescribeClustersOutput, error)
+	aws_ecs.ListServicesAPIClient
+	aws_ecs.DescribeServicesAPIClient
+}
+
+type ClusterFinder interface {
+	Find(cluster string) ([]Cluster, error)
+	FindServices(cluster string) ([]Service, error)
+}
+
+type clusterFinder struct {
+	timeout   time.Duration
+	ecsClient ClusterClient
+}
+
+func NewClusterFinder(cfg *config.Config) ClusterFinder {
+	return &clusterFinder{
+		timeout:   cfg.Timeout,
+		ecsClient: aws_ecs.NewFromConfig(cfg.AWSConfig),
+	}
+}
+
+// Find returns the active cluster with the name or arn, or all active clusters if it is empty.
+func (f *clusterFinder) Find(cluster string) ([]Cluster, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+
+	arns := []string{cluster}
+
+	if cluster == "" {
+		var err error
+		if arns, err = listClusters(ctx, f.ecsClient); err != nil {
+			return nil, err
+		}
+	}
+
+	var clusters []Cluster
+
+	// DescribeClusters accepts up to 100 clusters
+	for start := 0; start < len(arns); start += 100 {
+		end := start + 100
+		if end > len(arns) {
+			end = len(arns)
+		}
+
+		out, err := f.ecsClient.DescribeClusters(ctx, &aws_ecs.DescribeClustersInput{
+			Clusters: arns[start:end],
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, c := range out.Clusters {
+			if aws.ToString(c.Status) != "ACTIVE" {
+				continue
+			}
+
+			clusters = append(clusters, Cluster{
+				ARN:            aws.ToString(c.ClusterArn),
+				Name:           aws.ToString(c.ClusterName),
+				RunningTasks:   c.RunningTasksCount,
+				ActiveServices: c.ActiveServicesCount,
+			})
+		}
+	}
+
+	if len(clusters) == 0 {
+		return nil, ErrNoClusters
+	}
+
+	return clusters, nil
+}
+
+// FindServices returns the active services of the cluster.
+func (f *clusterFinder) FindServices(cluster string) ([]Service, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+
+	p := aws_ecs.NewListServicesPaginator(f.ecsClient, &aws_ecs.ListServicesInput{
+		Cluster:    &cluster,
+		MaxResults: aws.Int32(10),
+	})
+
+	var services []Service
+
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.ServiceArns) == 0 {
+			continue
+		}
+
+		// DescribeServices accepts up to 10 services, the size of a page
+		out, err := f.ecsClient.DescribeServices(ctx, &aws_ecs.DescribeServicesInput{
+			Cluster:  &cluster,
+			Services: page.ServiceArns,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, s := range out.Services {
+			if aws.ToString(s.Status) != "ACTIVE" {
+				continue
+			}
+
+			services = append(services, Service{
+				ARN:            aws.ToString(s.ServiceArn),
+				Name:           aws.ToString(s.ServiceName),
+				Cluster:        resourceName(aws.ToString(s.ClusterArn)),
+				TaskDefinition: resourceName(aws.ToString(s.TaskDefinition)),
+				DesiredCount:   s.DesiredCount,
+				RunningCount:   s.RunningCount,
+				ExecEnabled:    s.EnableExecuteCommand,
+			})
+		}
+	}
+
+	return services, nil
+}
+
+func listClusters(ctx context.Context, client aws_ecs.ListClustersAPIClient) ([]string, error) {
+	p := aws_ecs.NewListClustersPaginator(client, &aws_ecs.ListClustersInput{
+		MaxResults: aws.Int32(100),
+	})
+
+	var arns []string
+
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		arns = append(arns, page.ClusterArns...)
+	}
+
+	return arns, nil
+}
+
+// splitARN returns the cluster and name of a task or service arn. Arns in the old
+// format without the cluster return an empty cluster. Other values are returned as
+// name, e.g. a task ID or the name of a service.
+func splitARN(s, resourceType string) (string, string, error) {
+	if !arn.IsARN(s) {
+		return "", s, nil
+	}
+
+	a, err := arn.Parse(s)
+	if err != nil {
+		return "", "", err
+	}
+
+	parts := strings.Split(a.Resource, "/")
+	if parts[0] != resourceType || len(parts) < 2 || len(parts) > 3 {
+		return "", "", fmt.Errorf("%s is not the arn of a %s", s, resourceType)
+	}
+
+	if len(parts) == 2 {
+		return "", parts[1], nil
+	}
+
+	return parts[1], parts[2], nil
+}
@@ -0,0 +1,102 @@
+package ecs
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aws_ecs "github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockClusterClient struct {
+	ListClustersOutput     *aws_ecs.ListClustersOutput
+	DescribeClustersOutput *aws_ecs.DescribeClustersOutput
+	ListServicesOutput     *aws_ecs.ListServicesOutput
+	DescribeServicesOutput *aws_ecs.DescribeServicesOutput
+}
+
+func (m *MockClusterClient) ListClusters(_ context.Context, _ *aws_ecs.ListClustersInput, _ ...func(*aws_ecs.Options)) (*aws_ecs.ListClustersOutput, error) {
+	return m.ListClustersOutput, nil
+}
+
+func (m *MockClusterClient) DescribeClusters(_ context.Context, _ *aws_ecs.DescribeClustersInput, _ ...func(*aws_ecs.Options)) (*aws_ecs.DescribeClustersOutput, error) {
+	return m.DescribeClustersOutput, nil
+}
+
+func (m *MockClusterClient) ListServices(_ context.Context, _ *aws_ecs.ListServicesInput, _ ...func(*aws_ecs.Options)) (*aws_ecs.ListServicesOutput, error) {
+	return m.ListServicesOutput, nil
+}
+
+func (m *MockClusterClient) DescribeServices(_ context.Context, _ *aws_ecs.DescribeServicesInput, _ ...func(*aws_ecs.Options)) (*aws_ecs.DescribeServicesOutput, error) {
+	return m.DescribeServicesOutput, nil
+}
+
+func TestClusterFinder(t *testing.T) {
+	finder := &clusterFinder{
+		timeout: time.Second * 15,
+		ecsClient: &MockClusterClient{
+			ListClustersOutput: &aws_ecs.ListClustersOutput{
+				ClusterArns: []string{"arn:aws:ecs:us-west-2:123456789012:cluster/MyCluster", "arn:aws:ecs:us-west-2:123456789012:cluster/Old"},
+			},
+			DescribeClustersOutput: &aws_ecs.DescribeClustersOutput{
+				Clusters: []types.Cluster{
+					{
+						ClusterArn:          aws.String("arn:aws:ecs:us-west-2:123456789012:cluster/MyCluster"),
+						ClusterName:         aws.String("MyCluster"),
+						Status:              aws.String("ACTIVE"),
+						RunningTasksCount:   3,
+						ActiveServicesCount: 1,
+					},
+					{
+						ClusterArn:  aws.String("arn:aws:ecs:us-west-2:123456789012:cluster/Old"),
+						ClusterName: aws.String("Old"),
+						Status:      aws.String("INACTIVE"),
+					},
+				},
+			},
+			ListServicesOutput: &aws_ecs.ListServicesOutput{
+				ServiceArns: []string{"arn:aws:ecs:us-west-2:123456789012:service/MyCluster/web"},
+			},
+			DescribeServicesOutput: &aws_ecs.DescribeServicesOutput{
+				Services: []types.Service{{
+					ServiceArn:           aws.String("arn:aws:ecs:us-west-2:123456789012:service/MyCluster/web"),
+					ServiceName:          aws.String("web"),
+					ClusterArn:           aws.String("arn:aws:ecs:us-west-2:123456789012:cluster/MyCluster"),
+					TaskDefinition:       aws.String("arn:aws:ecs:us-west-2:123456789012:task-definition/web:42"),
+					Status:               aws.String("ACTIVE"),
+					DesiredCount:         3,
+					RunningCount:         3,
+					EnableExecuteCommand: true,
+				}},
+			},
+		},
+	}
+
+	t.Run("Find", func(t *testing.T) {
+		clusters, err := finder.Find("")
+		assert.NoError(t, err)
+		assert.Equal(t, []Cluster{{
+			ARN:            "arn:aws:ecs:us-west-2:123456789012:cluster/MyCluster",
+			Name:           "MyCluster",
+			RunningTasks:   3,
+			ActiveServices: 1,
+		}}, clusters)
+	})
+
+	t.Run("FindServices", func(t *testing.T) {
+		services, err := finder.FindServices("MyCluster")
+		assert.NoError(t, err)
+		assert.Equal(t, []Service{{
+			ARN:            "arn:aws:ecs:us-west-2:123456789012:service/MyCluster/web",
+			Name:           "web",
+			Cluster:        "MyCluster",
+			TaskDefinition: "web:42",
+			DesiredCount:   3,
+			RunningCount:   3,
+			ExecEnabled:    true,
+		}}, services)
+	})
+}
@@ -2,6 +2,7 @@ package ecs
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -9,25 +10,74 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/aws/arn"
 	aws_ecs "github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
 	"github.com/hupe1980/gotoaws/pkg/config"
 )
 
+// ErrNoContainers is returned if no container matches.
+var ErrNoContainers = errors.New("no ssm managed containers found")
+
 // An object representing a container.
 type Container struct {
+	// The name of the cluster.
+	Cluster string `json:"cluster"`
+
 	// The name of the taks.
-	Task string
+	Task string `json:"task"`
 
 	// The name of the container.
-	Name string
+	Name string `json:"name"`
+
+	// Service that started the task, empty for standalone tasks
+	Service string `json:"service,omitempty"`
+
+	// Group of the task, e.g. service:my-service or family:my-task
+	Group string `json:"group,omitempty"`
+
+	// TaskDefinition as family:revision
+	TaskDefinition string `json:"taskDefinition,omitempty"`
+
+	// LaunchType of the task, e.g. FARGATE or EC2
+	LaunchType string `json:"launchType,omitempty"`
+
+	// LastStatus of the container, e.g. RUNNING
+	LastStatus string `json:"lastStatus,omitempty"`
+
+	// HealthStatus of the container, e.g. HEALTHY or UNKNOWN
+	HealthStatus string `json:"healthStatus,omitempty"`
+
+	// StartedAt is the time the task started
+	StartedAt time.Time `json:"startedAt,omitempty"`
+
+	// RuntimeID of the container, part of the target of port forwarding sessions
+	RuntimeID string `json:"runtimeId,omitempty"`
+
+	// ExecEnabled reports whether execute command is enabled for the task
+	ExecEnabled bool `json:"execEnabled"`
+
+	// ExecAgentStatus is the last status of the ExecuteCommandAgent, e.g. RUNNING
+	ExecAgentStatus string `json:"execAgentStatus,omitempty"`
+
+	// Unavailable is the reason why no session can be started in the container, empty if it is connectable
+	Unavailable string `json:"unavailable,omitempty"`
+}
+
+// Target returns the target of ssm sessions in the container, e.g. for port forwarding.
+func (c *Container) Target() string {
+	return fmt.Sprintf("ecs:%s_%s_%s", c.Cluster, c.Task, c.RuntimeID)
 }
 
 type Client interface {
+	aws_ecs.ListClustersAPIClient
 	aws_ecs.ListTasksAPIClient
 	aws_ecs.DescribeTasksAPIClient
 }
 
+// ContainerFinder searches all clusters if the cluster is empty. Clusters, services
+// and tasks are names, IDs or arns.
 type ContainerFinder interface {
 	Find(cluster string) ([]Container, error)
+	FindByService(cluster, service string) ([]Container, error)
 	FindByIdentifier(cluster, task, container string) ([]Container, error)
 }
 
@@ -47,41 +97,67 @@ func (f *containerFinder) Find(cluster string) ([]Container, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
 	defer cancel()
 
-	p := aws_ecs.NewListTasksPaginator(f.ecsClient, &aws_ecs.ListTasksInput{
-		Cluster:    &cluster,
-		MaxResults: aws.Int32(100),
-	})
+	clusters, err := f.clusters(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
 
 	var containers []Container
 
-	for p.HasMorePages() {
-		page, err := p.NextPage(ctx)
+	for _, c := range clusters {
+		found, err := f.findTasks(ctx, &aws_ecs.ListTasksInput{Cluster: aws.String(c)})
 		if err != nil {
 			return nil, err
 		}
 
-		tasks, err := f.ecsClient.DescribeTasks(ctx, &aws_ecs.DescribeTasksInput{
-			Cluster: &cluster,
-			Tasks:   page.TaskArns,
-		})
+		containers = append(containers, found...)
+	}
+
+	if len(containers) == 0 {
+		return nil, ErrNoContainers
+	}
+
+	return containers, nil
+}
+
+// FindByService returns the containers of the running tasks of the service.
+func (f *containerFinder) FindByService(cluster, service string) ([]Container, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+
+	serviceCluster, name, err := splitARN(service, "service")
+	if err != nil {
+		return nil, err
+	}
+
+	if cluster == "" {
+		cluster = serviceCluster
+	}
+
+	clusters, err := f.clusters(ctx, cluster)
+	if err != nil {
+		return nil, err
+	}
+
+	var containers []Container
+
+	for _, c := range clusters {
+		found, err := f.findTasks(ctx, &aws_ecs.ListTasksInput{Cluster: aws.String(c), ServiceName: aws.String(name)})
 		if err != nil {
+			// Only some of the clusters have a service with the name
+			var notFound *types.ServiceNotFoundException
+			if len(clusters) > 1 && errors.As(err, &notFound) {
+				continue
+			}
+
 			return nil, err
 		}
 
-		for _, t := range tasks.Tasks {
-			if t.EnableExecuteCommand {
-				for _, c := range t.Containers {
-					containers = append(containers, Container{
-						Task: taskID(*c.TaskArn),
-						Name: *c.Name,
-					})
-				}
-			}
-		}
+		containers = append(containers, found...)
 	}
 
 	if len(containers) == 0 {
-		return nil, fmt.Errorf("no ssm managed containers found")
+		return nil, fmt.Errorf("no running tasks of service %s found: %w", name, ErrNoContainers)
 	}
 
 	return containers, nil
@@ -91,36 +167,177 @@ func (f *containerFinder) FindByIdentifier(cluster, task, container string) ([]C
 	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
 	defer cancel()
 
-	tasks, err := f.ecsClient.DescribeTasks(ctx, &aws_ecs.DescribeTasksInput{
-		Cluster: &cluster,
-		Tasks:   []string{task},
-	})
+	taskCluster, _, err := splitARN(task, "task")
+	if err != nil {
+		return nil, err
+	}
+
+	if cluster == "" {
+		cluster = taskCluster
+	}
+
+	clusters, err := f.clusters(ctx, cluster)
 	if err != nil {
 		return nil, err
 	}
 
 	var containers []Container
 
-	for _, t := range tasks.Tasks {
-		if t.EnableExecuteCommand {
-			for _, c := range t.Containers {
-				if container == "" || container == *c.Name {
-					containers = append(containers, Container{
-						Task: taskID(*c.TaskArn),
-						Name: *c.Name,
-					})
-				}
-			}
+	for _, c := range clusters {
+		// Tasks of other clusters are reported as failures, not as error
+		tasks, err := f.ecsClient.DescribeTasks(ctx, &aws_ecs.DescribeTasksInput{
+			Cluster: aws.String(c),
+			Tasks:   []string{task},
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range tasks.Tasks {
+			containers = append(containers, newContainers(c, &tasks.Tasks[i], container)...)
 		}
 	}
 
 	if len(containers) == 0 {
-		return nil, fmt.Errorf("no ssm managed containers found")
+		return nil, ErrNoContainers
+	}
+
+	return containers, nil
+}
+
+// clusters returns the cluster, or all clusters if it is empty.
+func (f *containerFinder) clusters(ctx context.Context, cluster string) ([]string, error) {
+	if cluster != "" {
+		return []string{cluster}, nil
+	}
+
+	clusters, err := listClusters(ctx, f.ecsClient)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(clusters) == 0 {
+		return nil, ErrNoClusters
+	}
+
+	return clusters, nil
+}
+
+func (f *containerFinder) findTasks(ctx context.Context, input *aws_ecs.ListTasksInput) ([]Container, error) {
+	input.MaxResults = aws.Int32(100)
+
+	p := aws_ecs.NewListTasksPaginator(f.ecsClient, input)
+
+	var containers []Container
+
+	for p.HasMorePages() {
+		page, err := p.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(page.TaskArns) == 0 {
+			continue
+		}
+
+		tasks, err := f.ecsClient.DescribeTasks(ctx, &aws_ecs.DescribeTasksInput{
+			Cluster: input.Cluster,
+			Tasks:   page.TaskArns,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range tasks.Tasks {
+			containers = append(containers, newContainers(*input.Cluster, &tasks.Tasks[i], "")...)
+		}
 	}
 
 	return containers, nil
 }
 
+// newContainers returns the containers of the task, or only the container with the name
+// if it is not empty. Containers without exec are flagged with the reason instead of skipped.
+func newContainers(cluster string, t *types.Task, name string) []Container {
+	cluster = resourceName(cluster)
+	if t.ClusterArn != nil {
+		cluster = resourceName(*t.ClusterArn)
+	}
+
+	group := aws.ToString(t.Group)
+
+	var containers []Container
+
+	for _, c := range t.Containers {
+		if name != "" && name != aws.ToString(c.Name) {
+			continue
+		}
+
+		container := Container{
+			Cluster:         cluster,
+			Task:            taskID(aws.ToString(c.TaskArn)),
+			Name:            aws.ToString(c.Name),
+			Group:           group,
+			TaskDefinition:  resourceName(aws.ToString(t.TaskDefinitionArn)),
+			LaunchType:      string(t.LaunchType),
+			LastStatus:      aws.ToString(c.LastStatus),
+			HealthStatus:    string(c.HealthStatus),
+			StartedAt:       aws.ToTime(t.StartedAt),
+			RuntimeID:       aws.ToString(c.RuntimeId),
+			ExecEnabled:     t.EnableExecuteCommand,
+			ExecAgentStatus: execAgentStatus(c.ManagedAgents),
+		}
+
+		if strings.HasPrefix(group, "service:") {
+			container.Service = strings.TrimPrefix(group, "service:")
+		}
+
+		container.Unavailable = unavailable(&container)
+
+		containers = append(containers, container)
+	}
+
+	return containers
+}
+
+func execAgentStatus(agents []types.ManagedAgent) string {
+	for _, a := range agents {
+		if a.Name == types.ManagedAgentNameExecuteCommandAgent {
+			return aws.ToString(a.LastStatus)
+		}
+	}
+
+	return ""
+}
+
+// unavailable returns why no session can be started in the container. Statuses that
+// were not reported are not held against the container.
+func unavailable(c *Container) string {
+	switch {
+	case !c.ExecEnabled:
+		return "execute command is not enabled for the task"
+	case c.LastStatus != "" && c.LastStatus != "RUNNING":
+		return fmt.Sprintf("container is %s", strings.ToLower(c.LastStatus))
+	case c.ExecAgentStatus != "" && c.ExecAgentStatus != "RUNNING":
+		return fmt.Sprintf("exec agent is %s", strings.ToLower(c.ExecAgentStatus))
+	}
+
+	return ""
+}
+
+// resourceName returns the last part of the resource of the arn, e.g. the name of a
+// cluster or the family:revision of a task definition.
+func resourceName(a string) string {
+	resourceARN, err := arn.Parse(a)
+	if err != nil {
+		return a
+	}
+
+	res := strings.Split(resourceARN.Resource, "/")
+
+	return res[len(res)-1]
+}
+
 func taskID(a string) string {
 	taskARN, _ := arn.Parse(a)
 	res := strings.Split(taskARN.Resource, "/")
@@ -16,16 +16,28 @@ type MockClient struct {
 	DescribeTasksError  error
 	ListTasksOutput     *aws_ecs.ListTasksOutput
 	ListTasksError      error
+	ListClustersOutput  *aws_ecs.ListClustersOutput
+	ListClustersError   error
+
+	// ListTasksInputs and DescribeTasksInputs record the calls
+	ListTasksInputs     []*aws_ecs.ListTasksInput
+	DescribeTasksInputs []*aws_ecs.DescribeTasksInput
 }
 
-func (m *MockClient) DescribeTasks(_ context.Context, _ *aws_ecs.DescribeTasksInput, _ ...func(*aws_ecs.Options)) (*aws_ecs.DescribeTasksOutput, error) {
+func (m *MockClient) DescribeTasks(_ context.Context, input *aws_ecs.DescribeTasksInput, _ ...func(*aws_ecs.Options)) (*aws_ecs.DescribeTasksOutput, error) {
+	m.DescribeTasksInputs = append(m.DescribeTasksInputs, input)
 	return m.DescribeTasksOutput, m.DescribeTasksError
 }
 
-func (m *MockClient) ListTasks(_ context.Context, _ *aws_ecs.ListTasksInput, _ ...func(*aws_ecs.Options)) (*aws_ecs.ListTasksOutput, error) {
+func (m *MockClient) ListTasks(_ context.Context, input *aws_ecs.ListTasksInput, _ ...func(*aws_ecs.Options)) (*aws_ecs.ListTasksOutput, error) {
+	m.ListTasksInputs = append(m.ListTasksInputs, input)
 	return m.ListTasksOutput, m.ListTasksError
 }
 
+func (m *MockClient) ListClusters(_ context.Context, _ *aws_ecs.ListClustersInput, _ ...func(*aws_ecs.Options)) (*aws_ecs.ListClustersOutput, error) {
+	return m.ListClustersOutput, m.ListClustersError
+}
+
 func TestContainerFinder(t *testing.T) {
 	t.Run("FindByIdentifier", func(t *testing.T) {
 		t.Run("no ssm with identifier", func(t *testing.T) {
@@ -65,7 +77,7 @@ func TestContainerFinder(t *testing.T) {
 				},
 			}
 			expected := []Container{
-				{Name: "container", Task: "1234567890123456789"},
+				{Cluster: "cluster", Name: "container", Task: "1234567890123456789", ExecEnabled: true},
 			}
 			instances, err := finder.FindByIdentifier("cluster", "task", "")
 			assert.Nil(t, err)
@@ -99,7 +111,7 @@ func TestContainerFinder(t *testing.T) {
 				},
 			}
 			expected := []Container{
-				{Name: "container1", Task: "1234567890123456789"},
+				{Cluster: "cluster", Name: "container1", Task: "1234567890123456789", ExecEnabled: true},
 			}
 			instances, err := finder.FindByIdentifier("cluster", "task", "container1")
 			assert.Nil(t, err)
@@ -108,6 +120,209 @@ func TestContainerFinder(t *testing.T) {
 	})
 }
 
+func TestContainerFinderFind(t *testing.T) {
+	startedAt := time.Date(2022, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	finder := &containerFinder{
+		timeout: time.Second * 15,
+		ecsClient: &MockClient{
+			ListTasksOutput: &aws_ecs.ListTasksOutput{
+				TaskArns: []string{"arn:aws:ecs:us-west-2:123456789012:task/MyCluster/1234567890123456789"},
+			},
+			DescribeTasksOutput: &aws_ecs.DescribeTasksOutput{
+				Tasks: []types.Task{
+					{
+						ClusterArn:           aws.String("arn:aws:ecs:us-west-2:123456789012:cluster/MyCluster"),
+						TaskDefinitionArn:    aws.String("arn:aws:ecs:us-west-2:123456789012:task-definition/web:42"),
+						Group:                aws.String("service:web"),
+						LaunchType:           types.LaunchTypeFargate,
+						StartedAt:            &startedAt,
+						EnableExecuteCommand: true,
+						Containers: []types.Container{
+							{
+								TaskArn:      aws.String("arn:aws:ecs:us-west-2:123456789012:task/MyCluster/1234567890123456789"),
+								Name:         aws.String("app"),
+								RuntimeId:    aws.String("1234567890123456789-265927825"),
+								LastStatus:   aws.String("RUNNING"),
+								HealthStatus: types.HealthStatusHealthy,
+								ManagedAgents: []types.ManagedAgent{{
+									Name:       types.ManagedAgentNameExecuteCommandAgent,
+									LastStatus: aws.String("RUNNING"),
+								}},
+							},
+							{
+								TaskArn:    aws.String("arn:aws:ecs:us-west-2:123456789012:task/MyCluster/1234567890123456789"),
+								Name:       aws.String("sidecar"),
+								LastStatus: aws.String("RUNNING"),
+								ManagedAgents: []types.ManagedAgent{{
+									Name:       types.ManagedAgentNameExecuteCommandAgent,
+									LastStatus: aws.String("PENDING"),
+								}},
+							},
+						},
+					},
+					{
+						Group: aws.String("family:batch"),
+						Containers: []types.Container{{
+							TaskArn: aws.String("arn:aws:ecs:us-west-2:123456789012:task/MyCluster/9876543210987654321"),
+							Name:    aws.String("job"),
+						}},
+					},
+				},
+			},
+		},
+	}
+
+	containers, err := finder.Find("MyCluster")
+	assert.NoError(t, err)
+	assert.Equal(t, []Container{
+		{
+			Cluster:         "MyCluster",
+			Task:            "1234567890123456789",
+			Name:            "app",
+			Service:         "web",
+			Group:           "service:web",
+			TaskDefinition:  "web:42",
+			LaunchType:      "FARGATE",
+			LastStatus:      "RUNNING",
+			HealthStatus:    "HEALTHY",
+			StartedAt:       startedAt,
+			RuntimeID:       "1234567890123456789-265927825",
+			ExecEnabled:     true,
+			ExecAgentStatus: "RUNNING",
+		},
+		{
+			Cluster:         "MyCluster",
+			Task:            "1234567890123456789",
+			Name:            "sidecar",
+			Service:         "web",
+			Group:           "service:web",
+			TaskDefinition:  "web:42",
+			LaunchType:      "FARGATE",
+			LastStatus:      "RUNNING",
+			StartedAt:       startedAt,
+			ExecEnabled:     true,
+			ExecAgentStatus: "PENDING",
+			Unavailable:     "exec agent is pending",
+		},
+		{
+			Cluster:     "MyCluster",
+			Task:        "9876543210987654321",
+			Name:        "job",
+			Group:       "family:batch",
+			Unavailable: "execute command is not enabled for the task",
+		},
+	}, containers)
+}
+
+func TestContainerFinderClusters(t *testing.T) {
+	task := types.Task{
+		ClusterArn:           aws.String("arn:aws:ecs:us-west-2:123456789012:cluster/MyCluster"),
+		EnableExecuteCommand: true,
+		Containers: []types.Container{{
+			TaskArn: aws.String("arn:aws:ecs:us-west-2:123456789012:task/MyCluster/1234567890123456789"),
+			Name:    aws.String("app"),
+		}},
+	}
+
+	newFinder := func() (*containerFinder, *MockClient) {
+		client := &MockClient{
+			ListClustersOutput: &aws_ecs.ListClustersOutput{
+				ClusterArns: []string{
+					"arn:aws:ecs:us-west-2:123456789012:cluster/MyCluster",
+					"arn:aws:ecs:us-west-2:123456789012:cluster/Other",
+				},
+			},
+			ListTasksOutput: &aws_ecs.ListTasksOutput{
+				TaskArns: []string{"arn:aws:ecs:us-west-2:123456789012:task/MyCluster/1234567890123456789"},
+			},
+			DescribeTasksOutput: &aws_ecs.DescribeTasksOutput{Tasks: []types.Task{task}},
+		}
+
+		return &containerFinder{timeout: time.Second * 15, ecsClient: client}, client
+	}
+
+	t.Run("all clusters", func(t *testing.T) {
+		finder, client := newFinder()
+
+		_, err := finder.Find("")
+		assert.NoError(t, err)
+		assert.Len(t, client.ListTasksInputs, 2)
+		assert.Equal(t, "arn:aws:ecs:us-west-2:123456789012:cluster/Other", *client.ListTasksInputs[1].Cluster)
+	})
+
+	t.Run("service arn", func(t *testing.T) {
+		finder, client := newFinder()
+
+		containers, err := finder.FindByService("", "arn:aws:ecs:us-west-2:123456789012:service/MyCluster/web")
+		assert.NoError(t, err)
+		assert.Len(t, containers, 1)
+		assert.Len(t, client.ListTasksInputs, 1)
+		assert.Equal(t, "MyCluster", *client.ListTasksInputs[0].Cluster)
+		assert.Equal(t, "web", *client.ListTasksInputs[0].ServiceName)
+	})
+
+	t.Run("service not found", func(t *testing.T) {
+		finder, client := newFinder()
+		client.ListTasksError = &types.ServiceNotFoundException{}
+
+		_, err := finder.FindByService("", "web")
+		assert.ErrorIs(t, err, ErrNoContainers)
+		assert.Len(t, client.ListTasksInputs, 2)
+	})
+
+	t.Run("task arn", func(t *testing.T) {
+		finder, client := newFinder()
+
+		containers, err := finder.FindByIdentifier("", "arn:aws:ecs:us-west-2:123456789012:task/MyCluster/1234567890123456789", "")
+		assert.NoError(t, err)
+		assert.Equal(t, "MyCluster", containers[0].Cluster)
+		assert.Len(t, client.DescribeTasksInputs, 1)
+		assert.Equal(t, "MyCluster", *client.DescribeTasksInputs[0].Cluster)
+	})
+
+	t.Run("task id", func(t *testing.T) {
+		finder, client := newFinder()
+
+		_, err := finder.FindByIdentifier("", "1234567890123456789", "")
+		assert.NoError(t, err)
+		assert.Len(t, client.DescribeTasksInputs, 2)
+	})
+
+	t.Run("no clusters", func(t *testing.T) {
+		finder, client := newFinder()
+		client.ListClustersOutput = &aws_ecs.ListClustersOutput{}
+
+		_, err := finder.Find("")
+		assert.ErrorIs(t, err, ErrNoClusters)
+	})
+}
+
+func TestSplitARN(t *testing.T) {
+	cluster, name, err := splitARN("arn:aws:ecs:us-west-2:123456789012:service/MyCluster/web", "service")
+	assert.NoError(t, err)
+	assert.Equal(t, "MyCluster", cluster)
+	assert.Equal(t, "web", name)
+
+	cluster, name, err = splitARN("arn:aws:ecs:us-west-2:123456789012:task/1234567890123456789", "task")
+	assert.NoError(t, err)
+	assert.Equal(t, "", cluster)
+	assert.Equal(t, "1234567890123456789", name)
+
+	cluster, name, err = splitARN("web", "service")
+	assert.NoError(t, err)
+	assert.Equal(t, "", cluster)
+	assert.Equal(t, "web", name)
+
+	_, _, err = splitARN("arn:aws:ecs:us-west-2:123456789012:task/MyCluster/1234567890123456789", "service")
+	assert.Error(t, err)
+}
+
+func TestContainerTarget(t *testing.T) {
+	c := &Container{Cluster: "MyCluster", Task: "1234567890123456789", Name: "app", RuntimeID: "1234567890123456789-265927825"}
+	assert.Equal(t, "ecs:MyCluster_1234567890123456789_1234567890123456789-265927825", c.Target())
+}
+
 func TestTaskID(t *testing.T) {
 	arn := "arn:aws:ecs:us-west-2:123456789012:task/MyCluster/1234567890123456789"
 	assert.Equal(t, "1234567890123456789", taskID(arn))
@@ -0,0 +1,270 @@
+package ecs
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hupe1980/gotoaws/internal/archive"
+	"github.com/hupe1980/gotoaws/pkg/config"
+)
+
+type CopyMode string
+
+const (
+	CopyModeSending   CopyMode = "sending"
+	CopyModeReceiving CopyMode = "receiving"
+)
+
+type CopyInput struct {
+	Container *Container
+
+	// Source is a local path when sending and a path in the container when receiving
+	Source string
+
+	// Target is copied into if it is a directory or ends with a slash, otherwise the copy is named after it
+	Target string
+
+	Mode CopyMode
+}
+
+// Markers of the copy scripts. ECS Exec always runs commands in a terminal, so the
+// archive is sent base64 encoded between markers.
+const (
+	markerReady = "GOTOAWS-READY"
+	markerBegin = "GOTOAWS-BEGIN"
+	markerEnd   = "GOTOAWS-END"
+	markerDone  = "GOTOAWS-DONE"
+)
+
+// Copy copies a file or directory between the local machine and the container with
+// tar over ECS Exec. The container needs sh, tar and base64.
+func Copy(cfg *config.Config, input *CopyInput) error {
+	script := receiveScript(input.Source)
+	if input.Mode == CopyModeSending {
+		if _, err := os.Stat(input.Source); err != nil {
+			return err
+		}
+
+		script = sendScript(input.Target)
+	}
+
+	session, err := NewSession(cfg, input.Container, "/bin/sh -c "+shellQuote(script))
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+
+	dc, err := session.OpenDataChannel()
+	if err != nil {
+		return err
+	}
+	defer dc.Close()
+
+	if input.Mode == CopyModeSending {
+		return sendArchive(dc, input.Source, input.Target)
+	}
+
+	return receiveArchive(dc, input.Source, input.Target)
+}
+
+// sendScript reports if the archive is extracted into target or named after it and
+// extracts the archive of stdin. The terminal must not echo the archive.
+func sendScript(target string) string {
+	into := "false"
+	if strings.HasSuffix(target, "/") {
+		into = "true"
+	}
+
+	return fmt.Sprintf(`stty -echo 2>/dev/null
+if [ -d %[1]s ] || %[3]s; then mkdir -p %[1]s && cd %[1]s && echo %[4]s dir; else mkdir -p %[2]s && cd %[2]s && echo %[4]s path; fi || exit 1
+base64 -d | tar -xof - && echo %[5]s`, shellQuote(target), shellQuote(path.Dir(target)), into, markerReady, markerDone)
+}
+
+func receiveScript(source string) string {
+	return fmt.Sprintf(`[ -e %[1]s ] || { echo %[1]s: No such file or directory; exit 1; }
+echo %[3]s
+tar -cf - -C %[2]s %[4]s | base64
+echo %[5]s`, shellQuote(source), shellQuote(path.Dir(source)), markerBegin, shellQuote(path.Base(source)), markerEnd)
+}
+
+// sendArchive waits for the script of sendScript and writes the archive of src in
+// lines of base64, ended by an end of transmission.
+func sendArchive(rw io.ReadWriter, src, target string) error {
+	lines := bufio.NewScanner(rw)
+
+	var output []string
+
+	name := ""
+
+	for name == "" && lines.Scan() {
+		line := strings.TrimRight(lines.Text(), "\r")
+
+		switch {
+		case line == markerReady+" dir":
+			name = filepath.Base(src)
+		case line == markerReady+" path":
+			name = path.Base(target)
+		default:
+			output = append(output, line)
+		}
+	}
+
+	if name == "" {
+		return copyError(lines.Err(), output)
+	}
+
+	// Lines are batched into larger messages of the data channel
+	buf := bufio.NewWriterSize(rw, 16*1024)
+	w := &lineWriter{w: buf, width: 76}
+	enc := base64.NewEncoder(base64.StdEncoding, w)
+
+	if err := archive.Write(enc, src, name); err != nil {
+		return err
+	}
+
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	if err := w.Close(); err != nil {
+		return err
+	}
+
+	// End of transmission ends the input of base64 on the terminal
+	if err := buf.WriteByte('\x04'); err != nil {
+		return err
+	}
+
+	if err := buf.Flush(); err != nil {
+		return err
+	}
+
+	output = nil
+
+	for lines.Scan() {
+		line := strings.TrimRight(lines.Text(), "\r")
+		if line == markerDone {
+			return nil
+		}
+
+		output = append(output, line)
+	}
+
+	return copyError(lines.Err(), output)
+}
+
+// receiveArchive decodes the archive of the script of receiveScript and extracts it to target.
+func receiveArchive(r io.Reader, source, target string) error {
+	lines := bufio.NewScanner(r)
+
+	var output []string
+
+	begun := false
+
+	for !begun && lines.Scan() {
+		line := strings.TrimRight(lines.Text(), "\r")
+		if line == markerBegin {
+			begun = true
+			continue
+		}
+
+		output = append(output, line)
+	}
+
+	// Without the begin marker the script failed before tar
+	if !begun {
+		return copyError(lines.Err(), output)
+	}
+
+	if info, err := os.Stat(target); (err == nil && info.IsDir()) || strings.HasSuffix(target, "/") {
+		target = filepath.Join(target, path.Base(source))
+	}
+
+	pr, pw := io.Pipe()
+
+	go func() {
+		for lines.Scan() {
+			line := strings.TrimRight(lines.Text(), "\r")
+			if line == markerEnd {
+				_ = pw.Close()
+				return
+			}
+
+			if _, err := pw.Write([]byte(line)); err != nil {
+				return
+			}
+		}
+
+		_ = pw.CloseWithError(copyError(lines.Err(), []string{"archive ended without " + markerEnd}))
+	}()
+
+	err := archive.Extract(base64.NewDecoder(base64.StdEncoding, pr), path.Base(source), target)
+
+	_ = pr.CloseWithError(err)
+
+	return err
+}
+
+func copyError(err error, output []string) error {
+	if err != nil {
+		return err
+	}
+
+	if msg := strings.TrimSpace(strings.Join(output, "\n")); msg != "" {
+		return errors.New(msg)
+	}
+
+	return errors.New("copy failed without output")
+}
+
+// lineWriter breaks the written bytes into lines of width.
+type lineWriter struct {
+	w     io.Writer
+	width int
+	line  bytes.Buffer
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	for _, b := range p {
+		lw.line.WriteByte(b)
+
+		if lw.line.Len() == lw.width {
+			if err := lw.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+
+	return len(p), nil
+}
+
+// Close writes the last incomplete line.
+func (lw *lineWriter) Close() error {
+	if lw.line.Len() == 0 {
+		return nil
+	}
+
+	return lw.flush()
+}
+
+func (lw *lineWriter) flush() error {
+	lw.line.WriteByte('\n')
+
+	_, err := lw.w.Write(lw.line.Bytes())
+	lw.line.Reset()
+
+	return err
+}
+
+// shellQuote quotes s for sh.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
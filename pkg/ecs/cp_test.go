@@ -0,0 +1,148 @@
+package ecs
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// terminal runs a copy script with sh like ECS Exec does, but without a terminal. The
+// end of transmission of the input closes stdin like the terminal would.
+type terminal struct {
+	stdin  io.WriteCloser
+	stdout io.Reader
+}
+
+func (t *terminal) Read(p []byte) (int, error) { return t.stdout.Read(p) }
+
+func (t *terminal) Write(p []byte) (int, error) {
+	if i := bytes.IndexByte(p, '\x04'); i != -1 {
+		if _, err := t.stdin.Write(p[:i]); err != nil {
+			return 0, err
+		}
+
+		return len(p), t.stdin.Close()
+	}
+
+	return t.stdin.Write(p)
+}
+
+func runScript(t *testing.T, script string) *terminal {
+	t.Helper()
+
+	for _, tool := range []string{"sh", "tar", "base64"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not installed", tool)
+		}
+	}
+
+	cmd := exec.Command("sh", "-c", script)
+
+	stdin, err := cmd.StdinPipe()
+	require.NoError(t, err)
+
+	pr, pw := io.Pipe()
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+
+	require.NoError(t, cmd.Start())
+
+	go func() {
+		_ = cmd.Wait()
+		pw.Close()
+	}()
+
+	return &terminal{stdin: stdin, stdout: pr}
+}
+
+func newFiles(t *testing.T) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "my app")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "conf"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "conf", "app.yaml"), []byte("port: 8080\n"), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "data.bin"), bytes.Repeat([]byte{0, 1, 2, 255}, 10000), 0o600))
+	require.NoError(t, os.Symlink("conf/app.yaml", filepath.Join(dir, "app.yaml")))
+
+	return dir
+}
+
+func assertFiles(t *testing.T, dir string) {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join(dir, "app.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "port: 8080\n", string(b))
+
+	b, err = os.ReadFile(filepath.Join(dir, "data.bin"))
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{0, 1, 2, 255}, 10000), b)
+}
+
+func TestCopy(t *testing.T) {
+	t.Run("send into directory", func(t *testing.T) {
+		src := newFiles(t)
+		target := t.TempDir()
+
+		require.NoError(t, sendArchive(runScript(t, sendScript(target)), src, target))
+		assertFiles(t, filepath.Join(target, "my app"))
+	})
+
+	t.Run("send renamed", func(t *testing.T) {
+		src := newFiles(t)
+		target := filepath.Join(t.TempDir(), "it's new", "app")
+
+		require.NoError(t, sendArchive(runScript(t, sendScript(target)), src, target))
+		assertFiles(t, target)
+	})
+
+	t.Run("send into new directory", func(t *testing.T) {
+		src := newFiles(t)
+		target := filepath.Join(t.TempDir(), "releases") + "/"
+
+		require.NoError(t, sendArchive(runScript(t, sendScript(target)), src, target))
+		assertFiles(t, filepath.Join(target, "my app"))
+	})
+
+	t.Run("receive", func(t *testing.T) {
+		src := newFiles(t)
+		target := filepath.Join(t.TempDir(), "copy")
+
+		require.NoError(t, receiveArchive(runScript(t, receiveScript(src)), src, target))
+		assertFiles(t, target)
+
+		// An existing directory is copied into
+		require.NoError(t, receiveArchive(runScript(t, receiveScript(src)), src, target))
+		assertFiles(t, filepath.Join(target, "my app"))
+	})
+
+	t.Run("receive missing", func(t *testing.T) {
+		src := filepath.Join(t.TempDir(), "missing")
+
+		err := receiveArchive(runScript(t, receiveScript(src)), src, t.TempDir())
+		assert.ErrorContains(t, err, "missing: No such file or directory")
+	})
+
+	t.Run("send fails", func(t *testing.T) {
+		src := newFiles(t)
+		blocker := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(blocker, nil, 0o600))
+
+		err := sendArchive(runScript(t, sendScript(filepath.Join(blocker, "app"))), src, filepath.Join(blocker, "app"))
+		require.Error(t, err)
+		assert.True(t, strings.Contains(err.Error(), "Not a directory") || strings.Contains(err.Error(), "File exists"), err.Error())
+	})
+}
+
+func TestShellQuote(t *testing.T) {
+	out, err := exec.Command("sh", "-c", "printf %s "+shellQuote(`it's "quoted" $HOME`)).Output()
+	require.NoError(t, err)
+	assert.Equal(t, `it's "quoted" $HOME`, string(out))
+}
@@ -0,0 +1,337 @@
+package ecs
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	aws_ecs "github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/hupe1980/gotoaws/pkg/config"
+)
+
+// LogStream is the CloudWatch log stream of a container with the awslogs log driver.
+type LogStream struct {
+	Cluster   string `json:"cluster"`
+	Task      string `json:"task"`
+	Container string `json:"container"`
+	Region    string `json:"region"`
+	Group     string `json:"group"`
+	Stream    string `json:"stream"`
+}
+
+// LogEvent is a log event of a container.
+type LogEvent struct {
+	Stream    *LogStream
+	Timestamp time.Time
+	Message   string
+}
+
+type TaskDefinitionClient interface {
+	DescribeTaskDefinition(ctx context.Context, params *aws_ecs.DescribeTaskDefinitionInput, optFns ...func(*aws_ecs.Options)) (*aws_ecs.DescribeTaskDefinitionOutput, error)
+}
+
+type LogStreamFinder interface {
+	Find(containers []Container, skip func(err error)) ([]LogStream, error)
+}
+
+type logStreamFinder struct {
+	timeout   time.Duration
+	region    string
+	ecsClient TaskDefinitionClient
+}
+
+func NewLogStreamFinder(cfg *config.Config) LogStreamFinder {
+	return &logStreamFinder{
+		timeout:   cfg.Timeout,
+		region:    cfg.AWSConfig.Region,
+		ecsClient: aws_ecs.NewFromConfig(cfg.AWSConfig),
+	}
+}
+
+// Find derives the log streams of the containers from the awslogs options of their
+// task definitions. The stream is named prefix/container/task, so the option
+// awslogs-stream-prefix is required. Of several containers, those without a stream
+// (e.g. sidecars with the firelens log driver) are passed to skip and left out.
+func (f *logStreamFinder) Find(containers []Container, skip func(err error)) ([]LogStream, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), f.timeout)
+	defer cancel()
+
+	definitions := make(map[string]*types.TaskDefinition)
+	streams := make([]LogStream, 0, len(containers))
+
+	for _, c := range containers {
+		def, ok := definitions[c.TaskDefinition]
+		if !ok {
+			out, err := f.ecsClient.DescribeTaskDefinition(ctx, &aws_ecs.DescribeTaskDefinitionInput{
+				TaskDefinition: aws.String(c.TaskDefinition),
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			def = out.TaskDefinition
+			definitions[c.TaskDefinition] = def
+		}
+
+		stream, err := f.logStream(&c, def)
+		if err != nil {
+			if len(containers) == 1 {
+				return nil, err
+			}
+
+			if skip != nil {
+				skip(err)
+			}
+
+			continue
+		}
+
+		streams = append(streams, *stream)
+	}
+
+	if len(streams) == 0 {
+		return nil, fmt.Errorf("none of the %d containers logs to a cloudwatch log stream", len(containers))
+	}
+
+	return streams, nil
+}
+
+func (f *logStreamFinder) logStream(c *Container, def *types.TaskDefinition) (*LogStream, error) {
+	for _, cd := range def.ContainerDefinitions {
+		if aws.ToString(cd.Name) != c.Name {
+			continue
+		}
+
+		if cd.LogConfiguration == nil || cd.LogConfiguration.LogDriver != types.LogDriverAwslogs {
+			return nil, fmt.Errorf("container %s does not log to cloudwatch with the awslogs log driver", c.Name)
+		}
+
+		options := cd.LogConfiguration.Options
+
+		if options["awslogs-stream-prefix"] == "" {
+			return nil, fmt.Errorf("the log stream of container %s cannot be derived without awslogs-stream-prefix", c.Name)
+		}
+
+		region := options["awslogs-region"]
+		if region == "" {
+			region = f.region
+		}
+
+		return &LogStream{
+			Cluster:   c.Cluster,
+			Task:      c.Task,
+			Container: c.Name,
+			Region:    region,
+			Group:     options["awslogs-group"],
+			Stream:    fmt.Sprintf("%s/%s/%s", options["awslogs-stream-prefix"], c.Name, c.Task),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("container %s is not part of task definition %s", c.Name, c.TaskDefinition)
+}
+
+type LogsInput struct {
+	Streams []LogStream
+
+	// StartTime of the first event, zero for the start of the streams
+	StartTime time.Time
+
+	// FilterPattern selects the events with the CloudWatch Logs filter syntax
+	FilterPattern string
+
+	// Follow polls for new events until the context is canceled
+	Follow bool
+
+	// Writer receives the events of all streams ordered by time
+	Writer func(event *LogEvent)
+}
+
+type LogReader interface {
+	Read(ctx context.Context, input *LogsInput) error
+}
+
+type logReader struct {
+	timeout      time.Duration
+	pollInterval time.Duration
+	logsClient   func(region string) cloudwatchlogs.FilterLogEventsAPIClient
+}
+
+func NewLogReader(cfg *config.Config) LogReader {
+	clients := make(map[string]cloudwatchlogs.FilterLogEventsAPIClient)
+
+	return &logReader{
+		timeout:      cfg.Timeout,
+		pollInterval: 2 * time.Second,
+		logsClient: func(region string) cloudwatchlogs.FilterLogEventsAPIClient {
+			if _, ok := clients[region]; !ok {
+				clients[region] = cloudwatchlogs.NewFromConfig(cfg.AWSConfig, func(o *cloudwatchlogs.Options) {
+					o.Region = region
+				})
+			}
+
+			return clients[region]
+		},
+	}
+}
+
+// logQuery reads the streams of a log group from the cursor on.
+type logQuery struct {
+	region  string
+	group   string
+	streams map[string]*LogStream
+
+	// cursor is the timestamp of the last event, seen are the IDs of the events at the cursor
+	cursor int64
+	seen   map[string]bool
+}
+
+// FilterLogEvents accepts up to 100 streams
+const maxLogStreams = 100
+
+// Read writes the events of the streams. Events of several log groups are interleaved by time.
+func (r *logReader) Read(ctx context.Context, input *LogsInput) error {
+	queries := newLogQueries(input.Streams, input.StartTime)
+
+	for {
+		var events []*LogEvent
+
+		for _, q := range queries {
+			found, err := r.poll(ctx, q, input.FilterPattern)
+			if err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+
+				return err
+			}
+
+			events = append(events, found...)
+		}
+
+		sort.SliceStable(events, func(i, j int) bool {
+			return events[i].Timestamp.Before(events[j].Timestamp)
+		})
+
+		for _, e := range events {
+			input.Writer(e)
+		}
+
+		if !input.Follow {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(r.pollInterval):
+		}
+	}
+}
+
+// poll reads the events after the cursor. The timeout applies to every page, the
+// first poll of a long stream may read many of them.
+func (r *logReader) poll(ctx context.Context, q *logQuery, filterPattern string) ([]*LogEvent, error) {
+	names := make([]string, 0, len(q.streams))
+	for name := range q.streams {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	input := &cloudwatchlogs.FilterLogEventsInput{
+		LogGroupName:   aws.String(q.group),
+		LogStreamNames: names,
+	}
+
+	if filterPattern != "" {
+		input.FilterPattern = aws.String(filterPattern)
+	}
+
+	if q.cursor > 0 {
+		input.StartTime = aws.Int64(q.cursor)
+	}
+
+	p := cloudwatchlogs.NewFilterLogEventsPaginator(r.logsClient(q.region), input)
+
+	var events []*LogEvent
+
+	cursor, seen := q.cursor, q.seen
+
+	for p.HasMorePages() {
+		page, err := r.nextPage(ctx, p)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, e := range page.Events {
+			id, ts := aws.ToString(e.EventId), aws.ToInt64(e.Timestamp)
+
+			// The start time includes the events at the cursor, which were already written
+			if ts < q.cursor || q.seen[id] {
+				continue
+			}
+
+			if ts > cursor {
+				cursor, seen = ts, make(map[string]bool)
+			}
+
+			if ts == cursor {
+				seen[id] = true
+			}
+
+			events = append(events, &LogEvent{
+				Stream:    q.streams[aws.ToString(e.LogStreamName)],
+				Timestamp: time.UnixMilli(ts),
+				Message:   aws.ToString(e.Message),
+			})
+		}
+	}
+
+	q.cursor, q.seen = cursor, seen
+
+	return events, nil
+}
+
+func (r *logReader) nextPage(ctx context.Context, p *cloudwatchlogs.FilterLogEventsPaginator) (*cloudwatchlogs.FilterLogEventsOutput, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	return p.NextPage(ctx)
+}
+
+// newLogQueries groups the streams by region and log group.
+func newLogQueries(streams []LogStream, startTime time.Time) []*logQuery {
+	var queries []*logQuery
+
+	index := make(map[string]*logQuery)
+
+	for i := range streams {
+		s := &streams[i]
+		key := s.Region + "/" + s.Group
+
+		q, ok := index[key]
+		if !ok || len(q.streams) == maxLogStreams {
+			q = &logQuery{
+				region:  s.Region,
+				group:   s.Group,
+				streams: make(map[string]*LogStream),
+				seen:    make(map[string]bool),
+			}
+
+			if !startTime.IsZero() {
+				q.cursor = startTime.UnixMilli()
+			}
+
+			index[key] = q
+			queries = append(queries, q)
+		}
+
+		q.streams[s.Stream] = s
+	}
+
+	return queries
+}
@@ -0,0 +1,299 @@
+package ecs
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs"
+	cwl_types "github.com/aws/aws-sdk-go-v2/service/cloudwatchlogs/types"
+	aws_ecs "github.com/aws/aws-sdk-go-v2/service/ecs"
+	"github.com/aws/aws-sdk-go-v2/service/ecs/types"
+	"github.com/stretchr/testify/assert"
+)
+
+type MockTaskDefinitionClient struct {
+	TaskDefinitions map[string]*types.TaskDefinition
+
+	// Calls counts the described task definitions
+	Calls int
+}
+
+func (m *MockTaskDefinitionClient) DescribeTaskDefinition(_ context.Context, input *aws_ecs.DescribeTaskDefinitionInput, _ ...func(*aws_ecs.Options)) (*aws_ecs.DescribeTaskDefinitionOutput, error) {
+	m.Calls++
+	return &aws_ecs.DescribeTaskDefinitionOutput{TaskDefinition: m.TaskDefinitions[aws.ToString(input.TaskDefinition)]}, nil
+}
+
+type MockLogsClient struct {
+	// Events are returned once per log group, one slice per call
+	Events map[string][][]cwl_types.FilteredLogEvent
+
+	// Inputs record the calls
+	Inputs []*cloudwatchlogs.FilterLogEventsInput
+
+	// OnCall is invoked after every call
+	OnCall func()
+}
+
+func (m *MockLogsClient) FilterLogEvents(_ context.Context, input *cloudwatchlogs.FilterLogEventsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error) {
+	m.Inputs = append(m.Inputs, input)
+
+	if m.OnCall != nil {
+		defer m.OnCall()
+	}
+
+	group := aws.ToString(input.LogGroupName)
+
+	if len(m.Events[group]) == 0 {
+		return &cloudwatchlogs.FilterLogEventsOutput{}, nil
+	}
+
+	events := m.Events[group][0]
+	m.Events[group] = m.Events[group][1:]
+
+	return &cloudwatchlogs.FilterLogEventsOutput{Events: events}, nil
+}
+
+// slowLogsClient returns one event per page after a delay.
+type slowLogsClient struct {
+	pages int
+	delay time.Duration
+}
+
+func (m *slowLogsClient) FilterLogEvents(ctx context.Context, input *cloudwatchlogs.FilterLogEventsInput, _ ...func(*cloudwatchlogs.Options)) (*cloudwatchlogs.FilterLogEventsOutput, error) {
+	select {
+	case <-time.After(m.delay):
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	page := 0
+	if input.NextToken != nil {
+		page, _ = strconv.Atoi(aws.ToString(input.NextToken))
+	}
+
+	out := &cloudwatchlogs.FilterLogEventsOutput{
+		Events: []cwl_types.FilteredLogEvent{logEvent(strconv.Itoa(page), "ecs/app/1234", int64(1000+page), "page")},
+	}
+
+	if page+1 < m.pages {
+		out.NextToken = aws.String(strconv.Itoa(page + 1))
+	}
+
+	return out, nil
+}
+
+func logEvent(id, stream string, ts int64, message string) cwl_types.FilteredLogEvent {
+	return cwl_types.FilteredLogEvent{
+		EventId:       aws.String(id),
+		LogStreamName: aws.String(stream),
+		Timestamp:     aws.Int64(ts),
+		Message:       aws.String(message),
+	}
+}
+
+func TestLogStreamFinder(t *testing.T) {
+	client := &MockTaskDefinitionClient{
+		TaskDefinitions: map[string]*types.TaskDefinition{
+			"web:42": {
+				ContainerDefinitions: []types.ContainerDefinition{
+					{
+						Name: aws.String("app"),
+						LogConfiguration: &types.LogConfiguration{
+							LogDriver: types.LogDriverAwslogs,
+							Options: map[string]string{
+								"awslogs-group":         "/ecs/web",
+								"awslogs-stream-prefix": "ecs",
+							},
+						},
+					},
+					{
+						Name: aws.String("sidecar"),
+						LogConfiguration: &types.LogConfiguration{
+							LogDriver: types.LogDriverAwslogs,
+							Options: map[string]string{
+								"awslogs-group":  "/ecs/sidecar",
+								"awslogs-region": "eu-west-1",
+							},
+						},
+					},
+					{
+						Name: aws.String("proxy"),
+						LogConfiguration: &types.LogConfiguration{
+							LogDriver: types.LogDriverAwsfirelens,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	finder := &logStreamFinder{
+		timeout:   time.Second * 15,
+		region:    "us-west-2",
+		ecsClient: client,
+	}
+
+	t.Run("awslogs", func(t *testing.T) {
+		streams, err := finder.Find([]Container{
+			{Cluster: "MyCluster", Task: "1234", Name: "app", TaskDefinition: "web:42"},
+			{Cluster: "MyCluster", Task: "5678", Name: "app", TaskDefinition: "web:42"},
+		}, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []LogStream{
+			{Cluster: "MyCluster", Task: "1234", Container: "app", Region: "us-west-2", Group: "/ecs/web", Stream: "ecs/app/1234"},
+			{Cluster: "MyCluster", Task: "5678", Container: "app", Region: "us-west-2", Group: "/ecs/web", Stream: "ecs/app/5678"},
+		}, streams)
+		assert.Equal(t, 1, client.Calls)
+	})
+
+	t.Run("no stream prefix", func(t *testing.T) {
+		_, err := finder.Find([]Container{{Task: "1234", Name: "sidecar", TaskDefinition: "web:42"}}, nil)
+		assert.EqualError(t, err, "the log stream of container sidecar cannot be derived without awslogs-stream-prefix")
+	})
+
+	t.Run("other log driver", func(t *testing.T) {
+		_, err := finder.Find([]Container{{Task: "1234", Name: "proxy", TaskDefinition: "web:42"}}, nil)
+		assert.EqualError(t, err, "container proxy does not log to cloudwatch with the awslogs log driver")
+	})
+
+	t.Run("mixed containers", func(t *testing.T) {
+		var skipped []string
+
+		streams, err := finder.Find([]Container{
+			{Cluster: "MyCluster", Task: "1234", Name: "app", TaskDefinition: "web:42"},
+			{Cluster: "MyCluster", Task: "1234", Name: "sidecar", TaskDefinition: "web:42"},
+			{Cluster: "MyCluster", Task: "1234", Name: "proxy", TaskDefinition: "web:42"},
+		}, func(err error) {
+			skipped = append(skipped, err.Error())
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []LogStream{
+			{Cluster: "MyCluster", Task: "1234", Container: "app", Region: "us-west-2", Group: "/ecs/web", Stream: "ecs/app/1234"},
+		}, streams)
+		assert.Equal(t, []string{
+			"the log stream of container sidecar cannot be derived without awslogs-stream-prefix",
+			"container proxy does not log to cloudwatch with the awslogs log driver",
+		}, skipped)
+
+		_, err = finder.Find([]Container{
+			{Task: "1234", Name: "sidecar", TaskDefinition: "web:42"},
+			{Task: "1234", Name: "proxy", TaskDefinition: "web:42"},
+		}, func(_ error) {})
+		assert.EqualError(t, err, "none of the 2 containers logs to a cloudwatch log stream")
+	})
+}
+
+func TestLogReader(t *testing.T) {
+	streams := []LogStream{
+		{Task: "1234", Container: "app", Region: "us-west-2", Group: "/ecs/web", Stream: "ecs/app/1234"},
+		{Task: "5678", Container: "app", Region: "us-west-2", Group: "/ecs/web", Stream: "ecs/app/5678"},
+		{Task: "1234", Container: "worker", Region: "us-west-2", Group: "/ecs/worker", Stream: "ecs/worker/1234"},
+	}
+
+	t.Run("interleaves log groups", func(t *testing.T) {
+		client := &MockLogsClient{
+			Events: map[string][][]cwl_types.FilteredLogEvent{
+				"/ecs/web": {{
+					logEvent("1", "ecs/app/1234", 1000, "first"),
+					logEvent("2", "ecs/app/5678", 3000, "third"),
+				}},
+				"/ecs/worker": {{
+					logEvent("3", "ecs/worker/1234", 2000, "second"),
+				}},
+			},
+		}
+
+		reader := &logReader{
+			timeout:    time.Second * 15,
+			logsClient: func(_ string) cloudwatchlogs.FilterLogEventsAPIClient { return client },
+		}
+
+		var lines []string
+
+		err := reader.Read(context.Background(), &LogsInput{
+			Streams:       streams,
+			StartTime:     time.UnixMilli(500),
+			FilterPattern: "ERROR",
+			Writer: func(e *LogEvent) {
+				lines = append(lines, e.Stream.Task+"/"+e.Stream.Container+" "+e.Message)
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"1234/app first", "1234/worker second", "5678/app third"}, lines)
+
+		assert.Len(t, client.Inputs, 2)
+		assert.Equal(t, []string{"ecs/app/1234", "ecs/app/5678"}, client.Inputs[0].LogStreamNames)
+		assert.Equal(t, int64(500), aws.ToInt64(client.Inputs[0].StartTime))
+		assert.Equal(t, "ERROR", aws.ToString(client.Inputs[0].FilterPattern))
+	})
+
+	t.Run("follow", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		client := &MockLogsClient{
+			Events: map[string][][]cwl_types.FilteredLogEvent{
+				"/ecs/web": {
+					{
+						logEvent("1", "ecs/app/1234", 1000, "first"),
+						logEvent("2", "ecs/app/1234", 2000, "second"),
+					},
+					{
+						// The start time of the next call includes the events at the cursor
+						logEvent("2", "ecs/app/1234", 2000, "second"),
+						logEvent("3", "ecs/app/5678", 2000, "third"),
+					},
+				},
+			},
+		}
+
+		client.OnCall = func() {
+			if len(client.Inputs) == 3 {
+				cancel()
+			}
+		}
+
+		reader := &logReader{
+			timeout:      time.Second * 15,
+			pollInterval: time.Millisecond,
+			logsClient:   func(_ string) cloudwatchlogs.FilterLogEventsAPIClient { return client },
+		}
+
+		var lines []string
+
+		err := reader.Read(ctx, &LogsInput{
+			Streams: streams[:2],
+			Follow:  true,
+			Writer: func(e *LogEvent) {
+				lines = append(lines, e.Message)
+			},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"first", "second", "third"}, lines)
+
+		assert.Nil(t, client.Inputs[0].StartTime)
+		assert.Equal(t, int64(2000), aws.ToInt64(client.Inputs[1].StartTime))
+		assert.Equal(t, int64(2000), aws.ToInt64(client.Inputs[2].StartTime))
+	})
+
+	t.Run("timeout per page", func(t *testing.T) {
+		client := &slowLogsClient{pages: 5, delay: 20 * time.Millisecond}
+
+		reader := &logReader{
+			timeout:    50 * time.Millisecond,
+			logsClient: func(_ string) cloudwatchlogs.FilterLogEventsAPIClient { return client },
+		}
+
+		count := 0
+
+		err := reader.Read(context.Background(), &LogsInput{
+			Streams: streams[:1],
+			Writer:  func(_ *LogEvent) { count++ },
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 5, count)
+	})
+}
@@ -0,0 +1,194 @@
+package ecs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/hupe1980/gotoaws/pkg/config"
+)
+
+// Markers of the run script. stdout and stderr share the terminal of ECS Exec, so
+// stderr is written to a file and sent after stdout.
+const (
+	markerStderr = "GOTOAWS-STDERR"
+	markerExit   = "GOTOAWS-EXIT"
+)
+
+// RunResult is the result of a command in one container.
+type RunResult struct {
+	Cluster   string `json:"cluster"`
+	Task      string `json:"task"`
+	Container string `json:"container"`
+
+	// ExitCode is the exit status of the command, -1 if it did not finish
+	ExitCode int `json:"exitCode"`
+
+	// Output and Error are stdout and stderr of the command, or the reason it did not finish
+	Output string `json:"stdout"`
+	Error  string `json:"stderr"`
+}
+
+type RunInput struct {
+	Container *Container
+
+	// Command is run by sh, like the command of a session
+	Command string
+
+	// Stdout and Stderr receive the output while the command runs. The output is
+	// captured in the result if they are nil.
+	Stdout io.Writer
+	Stderr io.Writer
+}
+
+// Run runs the command in the container with ECS Exec without input. The exit status
+// of the command is returned, not an error. The container needs sh.
+func Run(cfg *config.Config, input *RunInput) (*RunResult, error) {
+	session, err := NewSession(cfg, input.Container, "/bin/sh -c "+shellQuote(commandScript(input.Command)))
+	if err != nil {
+		return nil, err
+	}
+	defer session.Close()
+
+	dc, err := session.OpenDataChannel()
+	if err != nil {
+		return nil, err
+	}
+	defer dc.Close()
+
+	var stdout, stderr bytes.Buffer
+
+	outW, errW := input.Stdout, input.Stderr
+	if outW == nil {
+		outW = &stdout
+	}
+
+	if errW == nil {
+		errW = &stderr
+	}
+
+	code, err := readCommandOutput(dc, outW, errW)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RunResult{
+		Cluster:   input.Container.Cluster,
+		Task:      input.Container.Task,
+		Container: input.Container.Name,
+		ExitCode:  code,
+		Output:    stdout.String(),
+		Error:     stderr.String(),
+	}, nil
+}
+
+// commandScript runs the command in a subshell, so exit ends only the command, and
+// sends its stdout, stderr and exit status between markers. The terminal must not
+// echo or translate newlines.
+func commandScript(command string) string {
+	return fmt.Sprintf(`stty -echo -onlcr 2>/dev/null
+err=$(mktemp 2>/dev/null) || err=/tmp/gotoaws-run.$$
+echo %[1]s
+(
+%[2]s
+) </dev/null 2>"$err"
+code=$?
+printf '\n%[3]s\n'
+cat "$err"
+rm -f "$err"
+printf '\n%[4]s %%d\n' "$code"`, markerBegin, command, markerStderr, markerExit)
+}
+
+// readCommandOutput writes the stdout and stderr of the script of commandScript and
+// returns the exit status. The newlines in front of the markers are not part of the output.
+func readCommandOutput(r io.Reader, stdout, stderr io.Writer) (int, error) {
+	br := bufio.NewReader(r)
+
+	var output []string
+
+	for {
+		line, err := readLine(br)
+		if line == markerBegin {
+			break
+		}
+
+		output = append(output, line)
+
+		if err != nil {
+			// Without the begin marker the shell failed before the command
+			return -1, runError(err, output)
+		}
+	}
+
+	if _, err := copySection(br, stdout, markerStderr); err != nil {
+		return -1, err
+	}
+
+	status, err := copySection(br, stderr, markerExit)
+	if err != nil {
+		return -1, err
+	}
+
+	code, err := strconv.Atoi(strings.TrimPrefix(status, markerExit+" "))
+	if err != nil {
+		return -1, fmt.Errorf("invalid exit status %q", status)
+	}
+
+	return code, nil
+}
+
+// copySection copies the lines up to the marker line and returns it, including the
+// value after the marker. The newline in front of the marker is dropped.
+func copySection(br *bufio.Reader, w io.Writer, marker string) (string, error) {
+	newline := false
+
+	for {
+		line, err := br.ReadString('\n')
+
+		content := strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+		if newline && (content == marker || strings.HasPrefix(content, marker+" ")) {
+			return content, nil
+		}
+
+		if newline {
+			if _, werr := io.WriteString(w, "\n"); werr != nil {
+				return "", werr
+			}
+		}
+
+		if _, werr := io.WriteString(w, content); werr != nil {
+			return "", werr
+		}
+
+		newline = strings.HasSuffix(line, "\n")
+
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return "", fmt.Errorf("command ended without %s", marker)
+			}
+
+			return "", err
+		}
+	}
+}
+
+func readLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+func runError(err error, output []string) error {
+	if msg := strings.TrimSpace(strings.Join(output, "\n")); msg != "" {
+		return errors.New(msg)
+	}
+
+	if errors.Is(err, io.EOF) {
+		return errors.New("command ended without output")
+	}
+
+	return err
+}
@@ -0,0 +1,73 @@
+package ecs
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadCommandOutput(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not installed")
+	}
+
+	run := func(t *testing.T, command string) (string, string, int, error) {
+		t.Helper()
+
+		out, err := exec.Command("sh", "-c", commandScript(command)).Output() // nolint: gosec // commands of the test
+		require.NoError(t, err)
+
+		var stdout, stderr bytes.Buffer
+
+		code, err := readCommandOutput(bytes.NewReader(out), &stdout, &stderr)
+
+		return stdout.String(), stderr.String(), code, err
+	}
+
+	t.Run("output", func(t *testing.T) {
+		stdout, stderr, code, err := run(t, "echo hello; echo oops >&2")
+		require.NoError(t, err)
+		assert.Equal(t, "hello\n", stdout)
+		assert.Equal(t, "oops\n", stderr)
+		assert.Equal(t, 0, code)
+	})
+
+	t.Run("exit status", func(t *testing.T) {
+		stdout, stderr, code, err := run(t, "printf 'no newline'; exit 3")
+		require.NoError(t, err)
+		assert.Equal(t, "no newline", stdout)
+		assert.Equal(t, "", stderr)
+		assert.Equal(t, 3, code)
+	})
+
+	t.Run("markers in output", func(t *testing.T) {
+		stdout, _, code, err := run(t, "printf '\\n\\nGOTOAWS-STDERRx\\nGOTOAWS-EXIT\\n'")
+		require.NoError(t, err)
+		assert.Equal(t, "\n\nGOTOAWS-STDERRx\nGOTOAWS-EXIT\n", stdout)
+		assert.Equal(t, 0, code)
+	})
+
+	t.Run("terminal newlines", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+
+		out := "Welcome\r\nGOTOAWS-BEGIN\r\na\r\nb\r\n\r\nGOTOAWS-STDERR\r\n\r\nGOTOAWS-EXIT 1\r\n"
+		code, err := readCommandOutput(strings.NewReader(out), &stdout, &stderr)
+		require.NoError(t, err)
+		assert.Equal(t, "a\nb\n", stdout.String())
+		assert.Equal(t, 1, code)
+	})
+
+	t.Run("shell failed", func(t *testing.T) {
+		_, err := readCommandOutput(strings.NewReader("/bin/sh: not found\r\n"), &bytes.Buffer{}, &bytes.Buffer{})
+		assert.EqualError(t, err, "/bin/sh: not found")
+	})
+
+	t.Run("session ended", func(t *testing.T) {
+		_, err := readCommandOutput(strings.NewReader("GOTOAWS-BEGIN\npartial"), &bytes.Buffer{}, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "without GOTOAWS-STDERR")
+	})
+}
@@ -2,27 +2,36 @@ package ecs
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	aws_ecs "github.com/aws/aws-sdk-go-v2/service/ecs"
 	aws_ssm "github.com/aws/aws-sdk-go-v2/service/ssm"
 	"github.com/hupe1980/gotoaws/pkg/config"
+	"github.com/hupe1980/gotoaws/pkg/recording"
 	"github.com/hupe1980/gotoaws/pkg/ssm"
 )
 
 type Session interface {
 	Close() error
-	RunPlugin() error
+	RunShell() error
+	RecordShell(rec *recording.Recorder) error
+	OpenDataChannel() (*ssm.DataChannel, error)
 }
 
-func NewSession(cfg *config.Config, input *aws_ecs.ExecuteCommandInput) (Session, error) {
+// NewSession runs the command in the container with ECS Exec.
+func NewSession(cfg *config.Config, container *Container, command string) (Session, error) {
 	client := aws_ecs.NewFromConfig(cfg.AWSConfig)
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
 
 	defer cancel()
 
-	output, err := client.ExecuteCommand(ctx, input)
+	output, err := client.ExecuteCommand(ctx, &aws_ecs.ExecuteCommandInput{
+		Interactive: true,
+		Command:     aws.String(command),
+		Cluster:     aws.String(container.Cluster),
+		Task:        aws.String(container.Task),
+		Container:   aws.String(container.Name),
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -33,10 +42,9 @@ func NewSession(cfg *config.Config, input *aws_ecs.ExecuteCommandInput) (Session
 		TokenValue: output.Session.TokenValue,
 		SSMClient:  aws_ssm.NewFromConfig(cfg.AWSConfig),
 		Input: &aws_ssm.StartSessionInput{
-			Target: aws.String(fmt.Sprintf("ecs:%s_%s_%s", *input.Cluster, *input.Task, *input.Container)),
+			Target: aws.String(container.Target()),
 		},
 		Profile: cfg.Profile,
-		Plugin:  cfg.Plugin,
 		Region:  cfg.AWSConfig.Region,
 		Timeout: cfg.Timeout,
 	}, nil
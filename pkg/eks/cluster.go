@@ -15,19 +15,19 @@ import (
 // An object representing an Amazon EKS cluster.
 type Cluster struct {
 	// The Amazon Resource Name (ARN) of the cluster.
-	ARN string
+	ARN string `json:"arn"`
 
 	// The name of the cluster.
-	Name string
+	Name string `json:"name"`
 
 	// The Kubernetes server version for the cluster.
-	Version string
+	Version string `json:"version"`
 
 	// The endpoint for your Kubernetes API server.
-	Endpoint string
+	Endpoint string `json:"endpoint"`
 
 	// CAData contains PEM-encoded certificate authority certificates
-	CAData []byte
+	CAData []byte `json:"-"`
 }
 
 type Client interface {
@@ -0,0 +1,166 @@
+package eks
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/hupe1980/gotoaws/internal/archive"
+)
+
+type CopyMode string
+
+const (
+	CopyModeSending   CopyMode = "sending"
+	CopyModeReceiving CopyMode = "receiving"
+)
+
+type CopyInput struct {
+	// Namespace of the pod
+	Namespace string
+
+	// Name of the pod
+	PodName string
+
+	// Name of the container
+	Container string
+
+	// Source is a local path when sending and a path in the container when receiving
+	Source string
+
+	// Target is copied into if it is a directory or ends with a slash, otherwise the copy is named after it
+	Target string
+
+	Mode CopyMode
+}
+
+// streamFunc runs a command in the container without a terminal.
+type streamFunc func(ctx context.Context, command []string, stdin io.Reader, stdout, stderr io.Writer) error
+
+// Copy copies a file or directory between the local machine and the container with
+// tar over the exec subresource of the pod, like kubectl cp. The container needs sh and tar.
+func (k *Kubeclient) Copy(ctx context.Context, input *CopyInput) error {
+	stream := func(ctx context.Context, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+		return k.stream(ctx, &ExecInput{
+			Namespace: input.Namespace,
+			PodName:   input.PodName,
+			Container: input.Container,
+			Command:   command,
+		}, stdin, stdout, stderr)
+	}
+
+	if input.Mode == CopyModeSending {
+		return sendArchive(ctx, stream, input.Source, input.Target)
+	}
+
+	return receiveArchive(ctx, stream, input.Source, input.Target)
+}
+
+// sendScript prints if the archive is extracted into the target of $1 or named after it and
+// extracts the archive of stdin. A second argument into copies into the target.
+const sendScript = `if [ -d "$1" ] || [ "$2" = into ]; then mkdir -p "$1" && cd "$1" && echo dir
+else mkdir -p "$(dirname "$1")" && cd "$(dirname "$1")" && echo path; fi || exit 1
+exec tar -xof -`
+
+func sendArchive(ctx context.Context, stream streamFunc, src, target string) error {
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+
+	into := ""
+	if strings.HasSuffix(target, "/") {
+		into = "into"
+	}
+
+	stdinReader, stdinWriter := io.Pipe()
+	stdoutReader, stdoutWriter := io.Pipe()
+
+	var stderr bytes.Buffer
+
+	done := make(chan error, 1)
+
+	go func() {
+		err := stream(ctx, []string{"sh", "-c", sendScript, "sh", target, into}, stdinReader, stdoutWriter, &stderr)
+		_ = stdoutWriter.CloseWithError(err)
+		_ = stdinReader.CloseWithError(err)
+		done <- err
+	}()
+
+	stdout := bufio.NewReader(stdoutReader)
+
+	name := ""
+
+	switch line, _ := stdout.ReadString('\n'); strings.TrimSpace(line) {
+	case "dir":
+		name = filepath.Base(src)
+	case "path":
+		name = path.Base(target)
+	default:
+		_ = stdinWriter.Close()
+		return streamError(<-done, &stderr)
+	}
+
+	err := archive.Write(stdinWriter, src, name)
+	_ = stdinWriter.CloseWithError(err)
+
+	_, _ = io.Copy(io.Discard, stdout)
+
+	if streamErr := <-done; streamErr != nil {
+		return streamError(streamErr, &stderr)
+	}
+
+	return err
+}
+
+func receiveArchive(ctx context.Context, stream streamFunc, source, target string) error {
+	if info, err := os.Stat(target); (err == nil && info.IsDir()) || strings.HasSuffix(target, "/") {
+		target = filepath.Join(target, path.Base(source))
+	}
+
+	stdoutReader, stdoutWriter := io.Pipe()
+
+	var stderr bytes.Buffer
+
+	done := make(chan error, 1)
+
+	go func() {
+		err := stream(ctx, []string{"tar", "-cf", "-", "-C", path.Dir(source), path.Base(source)}, nil, stdoutWriter, &stderr)
+		_ = stdoutWriter.CloseWithError(err)
+		done <- err
+	}()
+
+	err := archive.Extract(stdoutReader, path.Base(source), target)
+	if err == nil {
+		// tar pads the archive after its end
+		_, _ = io.Copy(io.Discard, stdoutReader)
+	}
+
+	_ = stdoutReader.CloseWithError(err)
+
+	// A failed command also ends the archive early
+	if streamErr := <-done; streamErr != nil && (err == nil || errors.Is(err, streamErr)) {
+		return streamError(streamErr, &stderr)
+	}
+
+	return err
+}
+
+// streamError prefers the error output of the command over its exit status.
+func streamError(err error, stderr *bytes.Buffer) error {
+	if msg := strings.TrimSpace(stderr.String()); msg != "" {
+		return errors.New(msg)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return fmt.Errorf("copy failed without output")
+}
@@ -0,0 +1,102 @@
+package eks
+
+import (
+	"context"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// localStream runs the commands of a copy locally instead of in a container.
+func localStream(t *testing.T) streamFunc {
+	for _, tool := range []string{"sh", "tar"} {
+		if _, err := exec.LookPath(tool); err != nil {
+			t.Skipf("%s is not installed", tool)
+		}
+	}
+
+	return func(ctx context.Context, command []string, stdin io.Reader, stdout, stderr io.Writer) error {
+		cmd := exec.CommandContext(ctx, command[0], command[1:]...) // nolint: gosec // commands of the test
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+
+		// Like the exec subresource, the command does not wait for the rest of stdin
+		if stdin != nil {
+			w, err := cmd.StdinPipe()
+			if err != nil {
+				return err
+			}
+
+			go func() {
+				_, _ = io.Copy(w, stdin)
+				w.Close()
+			}()
+		}
+
+		return cmd.Run()
+	}
+}
+
+func newFiles(t *testing.T) string {
+	t.Helper()
+
+	dir := filepath.Join(t.TempDir(), "my app")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "conf"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "conf", "app.yaml"), []byte("port: 8080\n"), 0o644))
+	require.NoError(t, os.Symlink("conf/app.yaml", filepath.Join(dir, "app.yaml")))
+
+	return dir
+}
+
+func assertFiles(t *testing.T, dir string) {
+	t.Helper()
+
+	b, err := os.ReadFile(filepath.Join(dir, "app.yaml"))
+	require.NoError(t, err)
+	assert.Equal(t, "port: 8080\n", string(b))
+}
+
+func TestCopy(t *testing.T) {
+	ctx := context.Background()
+	stream := localStream(t)
+
+	t.Run("send into directory", func(t *testing.T) {
+		target := t.TempDir()
+
+		require.NoError(t, sendArchive(ctx, stream, newFiles(t), target))
+		assertFiles(t, filepath.Join(target, "my app"))
+	})
+
+	t.Run("send renamed", func(t *testing.T) {
+		target := filepath.Join(t.TempDir(), "new", "app")
+
+		require.NoError(t, sendArchive(ctx, stream, newFiles(t), target))
+		assertFiles(t, target)
+	})
+
+	t.Run("receive", func(t *testing.T) {
+		src := newFiles(t)
+		target := t.TempDir() + "/"
+
+		require.NoError(t, receiveArchive(ctx, stream, src, target))
+		assertFiles(t, filepath.Join(target, "my app"))
+	})
+
+	t.Run("receive missing", func(t *testing.T) {
+		err := receiveArchive(ctx, stream, filepath.Join(t.TempDir(), "missing"), t.TempDir())
+		assert.ErrorContains(t, err, "missing")
+	})
+
+	t.Run("send fails", func(t *testing.T) {
+		blocker := filepath.Join(t.TempDir(), "file")
+		require.NoError(t, os.WriteFile(blocker, nil, 0o600))
+
+		err := sendArchive(ctx, stream, newFiles(t), filepath.Join(blocker, "app"))
+		assert.Error(t, err)
+	})
+}
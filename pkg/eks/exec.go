@@ -2,9 +2,12 @@ package eks
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"os"
 
+	"github.com/hupe1980/gotoaws/pkg/recording"
+	"golang.org/x/term"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/tools/remotecommand"
@@ -22,9 +25,62 @@ type ExecInput struct {
 
 	// Command to run
 	Command []string
+
+	// Recorder records the terminal of the command if set
+	Recorder *recording.Recorder
 }
 
 func (k *Kubeclient) Exec(ctx context.Context, input *ExecInput) error {
+	executor, err := k.executor(input, true, true)
+	if err != nil {
+		return err
+	}
+
+	var (
+		stdin  io.Reader = os.Stdin
+		stdout io.Writer = os.Stdout
+		stderr io.Writer = os.Stderr
+	)
+
+	if rec := input.Recorder; rec != nil {
+		cols, rows, err := term.GetSize(int(os.Stdin.Fd()))
+		if err != nil {
+			cols, rows = 80, 24
+		}
+
+		if err := rec.Start(cols, rows); err != nil {
+			return err
+		}
+
+		stdin = io.TeeReader(os.Stdin, rec.Input())
+		stdout = io.MultiWriter(os.Stdout, rec.Output())
+		stderr = io.MultiWriter(os.Stderr, rec.Output())
+	}
+
+	return executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:             stdin,
+		Stdout:            stdout,
+		Stderr:            stderr,
+		Tty:               true,
+		TerminalSizeQueue: nil,
+	})
+}
+
+// stream runs the command of the input without a terminal, so stdin and stdout carry binary data.
+func (k *Kubeclient) stream(ctx context.Context, input *ExecInput, stdin io.Reader, stdout, stderr io.Writer) error {
+	executor, err := k.executor(input, false, stdin != nil)
+	if err != nil {
+		return err
+	}
+
+	return executor.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: stdout,
+		Stderr: stderr,
+	})
+}
+
+func (k *Kubeclient) executor(input *ExecInput, tty, stdin bool) (remotecommand.Executor, error) {
 	req := k.clientset.CoreV1().RESTClient().
 		Post().
 		Namespace(input.Namespace).
@@ -34,22 +90,11 @@ func (k *Kubeclient) Exec(ctx context.Context, input *ExecInput) error {
 		VersionedParams(&v1.PodExecOptions{
 			Command:   input.Command,
 			Container: input.Container,
-			Stdin:     true,
+			Stdin:     stdin,
 			Stdout:    true,
 			Stderr:    true,
-			TTY:       true,
+			TTY:       tty,
 		}, scheme.ParameterCodec)
 
-	executor, err := remotecommand.NewSPDYExecutor(k.restCfg, http.MethodPost, req.URL())
-	if err != nil {
-		return err
-	}
-
-	return executor.StreamWithContext(ctx, remotecommand.StreamOptions{
-		Stdin:             os.Stdin,
-		Stdout:            os.Stdout,
-		Stderr:            os.Stderr,
-		Tty:               true,
-		TerminalSizeQueue: nil,
-	})
+	return remotecommand.NewSPDYExecutor(k.restCfg, http.MethodPost, req.URL())
 }
@@ -9,25 +9,25 @@ import (
 // ContainerPort represents a network port in a single container.
 type ContainerPort struct {
 	// Number of port to expose on the pod's IP address
-	Port int32
+	Port int32 `json:"port"`
 
 	// Protocol for port. Must be UDP, TCP, or SCTP.
-	Protocol string
+	Protocol string `json:"protocol"`
 }
 
 // An object representing a pod.
 type Pod struct {
 	// The name of the pod.
-	Name string
+	Name string `json:"name"`
 
 	// The namespace of the pod.
-	Namespace string
+	Namespace string `json:"namespace"`
 
 	// The name of the container
-	Container string
+	Container string `json:"container"`
 
 	// List of ports to expose from the container
-	ContainerPorts []ContainerPort
+	ContainerPorts []ContainerPort `json:"containerPorts"`
 }
 
 type PodFinder interface {
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"time"
 
+	httpspdy "k8s.io/apimachinery/pkg/util/httpstream/spdy"
+	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/portforward"
 	"k8s.io/client-go/transport/spdy"
 )
@@ -27,6 +30,9 @@ type PortForwardInput struct {
 
 	// ReadyCh communicates when the tunnel is ready to receive traffic
 	ReadyCh chan struct{}
+
+	// KeepAlive is the interval of pings on the connection to the API server. Zero uses the client-go default of 5s.
+	KeepAlive time.Duration
 }
 
 func (k *Kubeclient) RunPortForward(input *PortForwardInput) error {
@@ -37,7 +43,7 @@ func (k *Kubeclient) RunPortForward(input *PortForwardInput) error {
 		Name(input.PodName).
 		SubResource("portforward")
 
-	transport, upgrader, err := spdy.RoundTripperFor(k.restCfg)
+	transport, upgrader, err := roundTripperFor(k.restCfg, input.KeepAlive)
 	if err != nil {
 		return err
 	}
@@ -51,3 +57,36 @@ func (k *Kubeclient) RunPortForward(input *PortForwardInput) error {
 
 	return fw.ForwardPorts()
 }
+
+// roundTripperFor is spdy.RoundTripperFor with a configurable ping period.
+func roundTripperFor(config *rest.Config, pingPeriod time.Duration) (http.RoundTripper, spdy.Upgrader, error) {
+	if pingPeriod == 0 {
+		return spdy.RoundTripperFor(config)
+	}
+
+	tlsConfig, err := rest.TLSConfigFor(config)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if config.Proxy != nil {
+		proxy = config.Proxy
+	}
+
+	upgradeRoundTripper, err := httpspdy.NewRoundTripperWithConfig(httpspdy.RoundTripperConfig{
+		TLS:        tlsConfig,
+		Proxier:    proxy,
+		PingPeriod: pingPeriod,
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	wrapper, err := rest.HTTPWrappersForConfig(config, upgradeRoundTripper)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return wrapper, upgradeRoundTripper, nil
+}
@@ -37,7 +37,7 @@ type TokenGen interface {
 	// Get a token using credentials in the default credentials chain.
 	Get(clusterName string) (*Token, error)
 
-	// GetWithRole creates a token by assuming the provided role, using the credentials in the default chain.
+	// GetWithRole creates a token by assuming the provided role, using the credentials of the config.
 	GetWithRole(clusterName, role string) (*Token, error)
 
 	// FormatJSON returns the client auth formatted json for the ExecCredential auth.
@@ -45,29 +45,52 @@ type TokenGen interface {
 }
 
 type tokenGen struct {
-	client  *sts.Client
-	region  string
-	account string
+	client      *sts.Client
+	region      string
+	account     string
+	principal   string
+	sessionName string
 }
 
 // NewTokenGen creates a TokenGen and returns it.
 func NewTokenGen(cfg *config.Config) TokenGen {
 	return &tokenGen{
-		client:  sts.NewFromConfig(cfg.AWSConfig),
-		region:  cfg.Region,
-		account: cfg.Account,
+		client:      sts.NewFromConfig(cfg.AWSConfig),
+		region:      cfg.Region,
+		account:     cfg.Account,
+		principal:   cfg.Principal,
+		sessionName: cfg.SessionName,
 	}
 }
 
 // Get uses the directly available AWS credentials to return a token valid for the clusterName.
+// Tokens are cached until shortly before they expire.
 func (t *tokenGen) Get(clusterName string) (*Token, error) {
-	return t.get(t.client, clusterName)
+	key := []string{t.principal, t.region, clusterName}
+
+	var token Token
+	if config.ReadCache(config.CacheKindToken, key, &token) {
+		return &token, nil
+	}
+
+	return t.getAndCache(t.client, clusterName, key)
 }
 
 // GetWithRole assumes the given AWS IAM role and returns a token valid for the clusterName.
+// Tokens are cached until shortly before they expire, the role is only assumed for a new token.
 func (t *tokenGen) GetWithRole(clusterName, role string) (*Token, error) {
-	prov := stscreds.NewAssumeRoleProvider(t.client, iam.RoleARN(t.account, role), func(aro *stscreds.AssumeRoleOptions) {
-		aro.RoleSessionName = "EKSGetTokenAuth"
+	roleARN := iam.RoleARN(t.account, role)
+	key := []string{t.principal, t.region, clusterName, roleARN}
+
+	var token Token
+	if config.ReadCache(config.CacheKindToken, key, &token) {
+		return &token, nil
+	}
+
+	prov := stscreds.NewAssumeRoleProvider(t.client, roleARN, func(aro *stscreds.AssumeRoleOptions) {
+		if t.sessionName != "" {
+			aro.RoleSessionName = t.sessionName
+		}
 	})
 
 	config, err := aws_config.LoadDefaultConfig(
@@ -79,7 +102,19 @@ func (t *tokenGen) GetWithRole(clusterName, role string) (*Token, error) {
 		return nil, err
 	}
 
-	return t.get(sts.NewFromConfig(config), clusterName)
+	return t.getAndCache(sts.NewFromConfig(config), clusterName, key)
+}
+
+func (t *tokenGen) getAndCache(client *sts.Client, clusterName string, key []string) (*Token, error) {
+	token, err := t.get(client, clusterName)
+	if err != nil {
+		return nil, err
+	}
+
+	// The token works without the cache, it is only presigned again
+	_ = config.WriteCache(config.CacheKindToken, key, token.Expiration, token)
+
+	return token, nil
 }
 
 func (t *tokenGen) get(client *sts.Client, clusterName string) (*Token, error) {
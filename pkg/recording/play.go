@@ -0,0 +1,64 @@
+package recording
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+type PlayInput struct {
+	// Speed is the playback speed factor. The default is 1.
+	Speed float64
+
+	// IdleLimit caps the pauses between events. Zero keeps the recorded pauses.
+	IdleLimit time.Duration
+}
+
+// Play writes the output events of the recording to w with the recorded timing.
+func Play(ctx context.Context, rec *Recording, w io.Writer, input *PlayInput) error {
+	speed := input.Speed
+	if speed <= 0 {
+		speed = 1
+	}
+
+	var last time.Duration
+
+	for _, e := range rec.Events {
+		delay := e.Time - last
+		last = e.Time
+
+		if input.IdleLimit > 0 && delay > input.IdleLimit {
+			delay = input.IdleLimit
+		}
+
+		if e.Type != EventOutput {
+			continue
+		}
+
+		if err := sleep(ctx, time.Duration(float64(delay)/speed)); err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, e.Data); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
@@ -0,0 +1,320 @@
+// Package recording captures terminal sessions in the asciicast v2 format.
+//
+// See https://docs.asciinema.org/manual/asciicast/v2/
+package recording
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const version = 2
+
+// EventType is the type of an asciicast event.
+type EventType string
+
+const (
+	EventOutput EventType = "o"
+	EventInput  EventType = "i"
+	EventResize EventType = "r"
+	EventMarker EventType = "m"
+)
+
+// endMarker is the label of the marker written when the session ends.
+const endMarker = "end"
+
+// Metadata describes the recorded session for audits.
+type Metadata struct {
+	Account   string    `json:"account,omitempty"`
+	Region    string    `json:"region,omitempty"`
+	Principal string    `json:"principal,omitempty"`
+	Target    string    `json:"target,omitempty"`
+	SessionID string    `json:"sessionId,omitempty"`
+	Start     time.Time `json:"start"`
+
+	// End is taken from the end marker when reading a recording
+	End *time.Time `json:"end,omitempty"`
+}
+
+// Header is the first line of an asciicast file. The metadata is stored in the
+// gotoaws key, which is ignored by other players.
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	Metadata  *Metadata         `json:"gotoaws,omitempty"`
+}
+
+// Event is a line of an asciicast file.
+type Event struct {
+	// Time is the offset from the start of the session
+	Time time.Duration
+	Type EventType
+	Data string
+}
+
+// MarshalJSON encodes the event as [time, type, data].
+func (e Event) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]interface{}{e.Time.Seconds(), e.Type, e.Data})
+}
+
+// UnmarshalJSON decodes an event from [time, type, data].
+func (e *Event) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	if len(raw) != 3 {
+		return fmt.Errorf("invalid event %s", string(b))
+	}
+
+	var seconds float64
+	if err := json.Unmarshal(raw[0], &seconds); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(raw[1], &e.Type); err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(raw[2], &e.Data); err != nil {
+		return err
+	}
+
+	e.Time = time.Duration(seconds * float64(time.Second))
+
+	return nil
+}
+
+// Recorder writes the events of a terminal session. It is safe for concurrent use.
+type Recorder struct {
+	// Metadata is written with the header and can be completed until Start is called
+	Metadata Metadata
+
+	mu      sync.Mutex
+	enc     *json.Encoder
+	start   time.Time
+	cols    int
+	rows    int
+	started bool
+	closed  bool
+	err     error
+	now     func() time.Time
+
+	// pending holds incomplete utf-8 sequences until the next write of the stream
+	pending map[EventType][]byte
+}
+
+// NewRecorder returns a recorder writing to w.
+func NewRecorder(w io.Writer, metadata Metadata) *Recorder {
+	return &Recorder{
+		Metadata: metadata,
+		enc:      json.NewEncoder(w),
+		now:      time.Now,
+		pending:  make(map[EventType][]byte),
+	}
+}
+
+// Start writes the header with the initial terminal size. Events before Start are dropped.
+func (r *Recorder) Start(cols, rows int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.started {
+		return errors.New("recording already started")
+	}
+
+	r.start = r.now()
+	r.cols, r.rows = cols, rows
+	r.started = true
+
+	if r.Metadata.Start.IsZero() {
+		r.Metadata.Start = r.start
+	}
+
+	meta := r.Metadata
+
+	r.err = r.enc.Encode(&Header{
+		Version:   version,
+		Width:     cols,
+		Height:    rows,
+		Timestamp: r.start.Unix(),
+		Title:     meta.Target,
+		Metadata:  &meta,
+	})
+
+	return r.err
+}
+
+// Output returns a writer recording the output of the session.
+func (r *Recorder) Output() io.Writer {
+	return eventWriter{r: r, typ: EventOutput}
+}
+
+// Input returns a writer recording the input of the session.
+func (r *Recorder) Input() io.Writer {
+	return eventWriter{r: r, typ: EventInput}
+}
+
+// Resize records a change of the terminal size. An unchanged size is ignored.
+func (r *Recorder) Resize(cols, rows int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cols == r.cols && rows == r.rows {
+		return nil
+	}
+
+	r.cols, r.rows = cols, rows
+
+	return r.encode(EventResize, fmt.Sprintf("%dx%d", cols, rows))
+}
+
+// Close records the end of the session. It does not close the underlying writer.
+func (r *Recorder) Close() error {
+	if err := r.record(EventMarker, endMarker); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.closed = true
+
+	return nil
+}
+
+func (r *Recorder) record(typ EventType, data string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.encode(typ, data)
+}
+
+// encode must be called with the lock held.
+func (r *Recorder) encode(typ EventType, data string) error {
+	if !r.started || r.closed {
+		return nil
+	}
+
+	if r.err != nil {
+		return r.err
+	}
+
+	r.err = r.enc.Encode(Event{Time: r.now().Sub(r.start), Type: typ, Data: data})
+
+	return r.err
+}
+
+type eventWriter struct {
+	r   *Recorder
+	typ EventType
+}
+
+// Write records p. Errors of the recording are not passed to the session.
+func (w eventWriter) Write(p []byte) (int, error) {
+	w.r.mu.Lock()
+	defer w.r.mu.Unlock()
+
+	// Event data must be valid utf-8, so multi-byte characters split across writes are joined
+	b := append(w.r.pending[w.typ], p...)
+	complete, rest := splitUTF8(b)
+	w.r.pending[w.typ] = append([]byte(nil), rest...)
+
+	if len(complete) > 0 {
+		_ = w.r.encode(w.typ, string(complete))
+	}
+
+	return len(p), nil
+}
+
+// splitUTF8 splits an incomplete utf-8 sequence from the end of b.
+func splitUTF8(b []byte) ([]byte, []byte) {
+	for i := 1; i < utf8.UTFMax && i <= len(b); i++ {
+		if utf8.RuneStart(b[len(b)-i]) {
+			if !utf8.FullRune(b[len(b)-i:]) {
+				return b[:len(b)-i], b[len(b)-i:]
+			}
+
+			break
+		}
+	}
+
+	return b, nil
+}
+
+// Recording is a parsed asciicast file.
+type Recording struct {
+	Header Header
+	Events []Event
+}
+
+// Duration returns the offset of the last event.
+func (rec *Recording) Duration() time.Duration {
+	if len(rec.Events) == 0 {
+		return 0
+	}
+
+	return rec.Events[len(rec.Events)-1].Time
+}
+
+// Read parses an asciicast v2 file. The end of the metadata is taken from the end marker.
+func Read(r io.Reader) (*Recording, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+
+		return nil, errors.New("empty recording")
+	}
+
+	rec := &Recording{}
+	if err := json.Unmarshal(scanner.Bytes(), &rec.Header); err != nil {
+		return nil, fmt.Errorf("invalid header: %w", err)
+	}
+
+	if rec.Header.Version != version {
+		return nil, fmt.Errorf("unsupported asciicast version %d", rec.Header.Version)
+	}
+
+	line := 1
+
+	for scanner.Scan() {
+		line++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("invalid event in line %d: %w", line, err)
+		}
+
+		rec.Events = append(rec.Events, e)
+
+		if e.Type == EventMarker && e.Data == endMarker && rec.Header.Metadata != nil {
+			end := rec.Header.Metadata.Start.Add(e.Time)
+			rec.Header.Metadata.End = &end
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
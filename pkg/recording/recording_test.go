@@ -0,0 +1,86 @@
+package recording
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecorder(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	clock := start
+
+	var buf bytes.Buffer
+
+	rec := NewRecorder(&buf, Metadata{Account: "123456789012", Region: "eu-central-1", Target: "i-123"})
+	rec.now = func() time.Time { return clock }
+
+	// dropped before the start
+	_, _ = rec.Output().Write([]byte("ignored"))
+
+	rec.Metadata.SessionID = "session-1"
+	require.NoError(t, rec.Start(80, 24))
+
+	clock = clock.Add(500 * time.Millisecond)
+	_, _ = rec.Input().Write([]byte("ls\r"))
+
+	clock = clock.Add(time.Second)
+	euro := []byte("€ done\r\n")
+	_, _ = rec.Output().Write(euro[:1])
+	_, _ = rec.Output().Write(euro[1:])
+
+	require.NoError(t, rec.Resize(80, 24))
+	require.NoError(t, rec.Resize(120, 40))
+
+	clock = clock.Add(time.Second)
+	require.NoError(t, rec.Close())
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 5)
+	assert.Contains(t, lines[0], `"version":2`)
+	assert.Contains(t, lines[0], `"sessionId":"session-1"`)
+	assert.Equal(t, `[0.5,"i","ls\r"]`, lines[1])
+	assert.Equal(t, `[1.5,"o","€ done\r\n"]`, lines[2])
+	assert.Equal(t, `[1.5,"r","120x40"]`, lines[3])
+	assert.Equal(t, `[2.5,"m","end"]`, lines[4])
+
+	recording, err := Read(&buf)
+	require.NoError(t, err)
+	assert.Equal(t, 80, recording.Header.Width)
+	assert.Equal(t, "i-123", recording.Header.Metadata.Target)
+	assert.Equal(t, start, recording.Header.Metadata.Start)
+	require.NotNil(t, recording.Header.Metadata.End)
+	assert.Equal(t, start.Add(2500*time.Millisecond), *recording.Header.Metadata.End)
+	assert.Equal(t, 2500*time.Millisecond, recording.Duration())
+
+	var out bytes.Buffer
+	require.NoError(t, Play(context.Background(), recording, &out, &PlayInput{Speed: 100, IdleLimit: 10 * time.Millisecond}))
+	assert.Equal(t, "€ done\r\n", out.String())
+}
+
+func TestRead(t *testing.T) {
+	t.Run("asciinema", func(t *testing.T) {
+		rec, err := Read(strings.NewReader(`{"version": 2, "width": 80, "height": 24}
+[0.1, "o", "hello"]
+`))
+		require.NoError(t, err)
+		assert.Nil(t, rec.Header.Metadata)
+		assert.Equal(t, []Event{{Time: 100 * time.Millisecond, Type: EventOutput, Data: "hello"}}, rec.Events)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		_, err := Read(strings.NewReader(`{"version": 1}`))
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid event", func(t *testing.T) {
+		_, err := Read(strings.NewReader(`{"version": 2}
+[0.1, "o"]`))
+		assert.EqualError(t, err, `invalid event in line 2: invalid event [0.1, "o"]`)
+	})
+}
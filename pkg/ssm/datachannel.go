@@ -0,0 +1,553 @@
+package ssm
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// The version reported to the agent. Agents use it to decide which features the client supports,
+	// e.g. multiplexed port forwarding requires at least 1.1.70.
+	clientVersion = "1.2.0.0"
+
+	// The maximum size of a single input stream payload.
+	streamDataPayloadSize = 1024
+
+	resendInterval = 500 * time.Millisecond
+	resendTimeout  = 3 * time.Second
+
+	pingTimeout = 5 * time.Second
+
+	// maxOutOfOrder limits the messages buffered while a missing one is resent
+	maxOutOfOrder = 1024
+)
+
+// ErrKMSEncryption is returned for sessions that require kms encryption, which is not
+// implemented. Such sessions need the session-manager-plugin of the AWS CLI.
+var ErrKMSEncryption = errors.New("sessions with kms encryption are not supported, use the session-manager-plugin of the aws cli")
+
+const (
+	actionTypeKMSEncryption = "KMSEncryption"
+	actionTypeSessionType   = "SessionType"
+
+	actionStatusSuccess     = 1
+	actionStatusFailed      = 2
+	actionStatusUnsupported = 3
+)
+
+type openDataChannelInput struct {
+	MessageSchemaVersion string `json:"MessageSchemaVersion"`
+	RequestID            string `json:"RequestId"`
+	TokenValue           string `json:"TokenValue"`
+	ClientID             string `json:"ClientId"`
+	ClientVersion        string `json:"ClientVersion"`
+}
+
+type handshakeRequest struct {
+	AgentVersion           string                  `json:"AgentVersion"`
+	RequestedClientActions []requestedClientAction `json:"RequestedClientActions"`
+}
+
+type requestedClientAction struct {
+	ActionType       string          `json:"ActionType"`
+	ActionParameters json.RawMessage `json:"ActionParameters"`
+}
+
+type sessionTypeRequest struct {
+	SessionType string          `json:"SessionType"`
+	Properties  json.RawMessage `json:"Properties"`
+}
+
+type handshakeResponse struct {
+	ClientVersion          string                  `json:"ClientVersion"`
+	ProcessedClientActions []processedClientAction `json:"ProcessedClientActions"`
+	Errors                 []string                `json:"Errors"`
+}
+
+type processedClientAction struct {
+	ActionType   string `json:"ActionType"`
+	ActionStatus int    `json:"ActionStatus"`
+	Error        string `json:"Error,omitempty"`
+}
+
+type acknowledgeContent struct {
+	MessageType         MessageType `json:"AcknowledgedMessageType"`
+	MessageID           string      `json:"AcknowledgedMessageId"`
+	SequenceNumber      int64       `json:"AcknowledgedMessageSequenceNumber"`
+	IsSequentialMessage bool        `json:"IsSequentialMessage"`
+}
+
+type sizeData struct {
+	Cols uint32 `json:"cols"`
+	Rows uint32 `json:"rows"`
+}
+
+type outgoingMessage struct {
+	msg    *ClientMessage
+	sentAt time.Time
+}
+
+// DataChannel speaks the Session Manager data channel protocol with the agent.
+// It implements io.ReadWriteCloser for the stream data of the session.
+type DataChannel struct {
+	streamURL  string
+	tokenValue string
+
+	conn *websocket.Conn
+
+	// writeMu keeps the chunks of one Write together
+	writeMu sync.Mutex
+
+	// mu guards the outgoing sequence number, unacknowledged messages and writes to conn
+	mu         sync.Mutex
+	sequence   int64
+	unacked    map[int64]*outgoingMessage
+	expected   int64
+	outOfOrder map[int64]*ClientMessage
+	handshake  chan struct{}
+	data       chan []byte
+	buf        []byte
+	done       chan struct{}
+	closeOnce  sync.Once
+	err        error
+
+	// handshakeOnce closes handshake, the agent may complete the handshake more than once
+	handshakeOnce sync.Once
+
+	agentVersion string
+	sessionType  string
+	properties   json.RawMessage
+}
+
+// NewDataChannel creates a DataChannel for the given stream url and token of a started session.
+func NewDataChannel(streamURL, tokenValue string) *DataChannel {
+	return &DataChannel{
+		streamURL:  streamURL,
+		tokenValue: tokenValue,
+		unacked:    make(map[int64]*outgoingMessage),
+		outOfOrder: make(map[int64]*ClientMessage),
+		handshake:  make(chan struct{}),
+		data:       make(chan []byte, 256),
+		done:       make(chan struct{}),
+	}
+}
+
+// Open connects to the stream url and waits until the handshake with the agent is completed.
+func (dc *DataChannel) Open(ctx context.Context) error {
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, dc.streamURL, nil)
+	if err != nil {
+		return err
+	}
+
+	if resp != nil && resp.Body != nil {
+		resp.Body.Close()
+	}
+
+	dc.conn = conn
+
+	if err := conn.WriteJSON(&openDataChannelInput{
+		MessageSchemaVersion: "1.0",
+		RequestID:            uuid.NewString(),
+		TokenValue:           dc.tokenValue,
+		ClientID:             uuid.NewString(),
+		ClientVersion:        clientVersion,
+	}); err != nil {
+		conn.Close()
+		return err
+	}
+
+	go dc.readLoop()
+	go dc.resendLoop()
+
+	select {
+	case <-dc.handshake:
+		return nil
+	case <-dc.done:
+		return dc.Err()
+	case <-ctx.Done():
+		dc.Close()
+		return ctx.Err()
+	}
+}
+
+// AgentVersion returns the version of the agent reported during the handshake.
+func (dc *DataChannel) AgentVersion() string {
+	return dc.agentVersion
+}
+
+// SessionType returns the session type (Standard_Stream, Port, InteractiveCommands, ...) requested by the agent.
+func (dc *DataChannel) SessionType() string {
+	return dc.sessionType
+}
+
+// Properties returns the raw session properties requested by the agent.
+func (dc *DataChannel) Properties() json.RawMessage {
+	return dc.properties
+}
+
+// Read reads stream output of the session. It returns io.EOF after the channel is closed.
+func (dc *DataChannel) Read(p []byte) (int, error) {
+	if len(dc.buf) == 0 {
+		b, ok := <-dc.data
+		if !ok {
+			if err := dc.Err(); err != nil {
+				return 0, err
+			}
+
+			return 0, io.EOF
+		}
+
+		dc.buf = b
+	}
+
+	n := copy(p, dc.buf)
+	dc.buf = dc.buf[n:]
+
+	return n, nil
+}
+
+// Write sends p as stream input to the session.
+func (dc *DataChannel) Write(p []byte) (int, error) {
+	dc.writeMu.Lock()
+	defer dc.writeMu.Unlock()
+
+	written := 0
+
+	for len(p) > 0 {
+		n := len(p)
+		if n > streamDataPayloadSize {
+			n = streamDataPayloadSize
+		}
+
+		chunk := make([]byte, n)
+		copy(chunk, p[:n])
+
+		if err := dc.sendInput(PayloadTypeOutput, chunk); err != nil {
+			return written, err
+		}
+
+		written += n
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// SetSize informs the agent about the size of the local terminal.
+func (dc *DataChannel) SetSize(cols, rows uint32) error {
+	b, err := json.Marshal(&sizeData{Cols: cols, Rows: rows})
+	if err != nil {
+		return err
+	}
+
+	return dc.sendInput(PayloadTypeSize, b)
+}
+
+// SendFlag sends a flag (e.g. FlagDisconnectToPort) to the agent.
+func (dc *DataChannel) SendFlag(flag Flag) error {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, uint32(flag))
+
+	return dc.sendInput(PayloadTypeFlag, b)
+}
+
+// Ping sends a websocket ping to keep the connection to the service alive.
+func (dc *DataChannel) Ping() error {
+	select {
+	case <-dc.done:
+		return errors.New("data channel closed")
+	default:
+	}
+
+	return dc.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingTimeout))
+}
+
+// Done is closed when the data channel is closed.
+func (dc *DataChannel) Done() <-chan struct{} {
+	return dc.done
+}
+
+// Err returns the error that caused the data channel to close, if any.
+func (dc *DataChannel) Err() error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	return dc.err
+}
+
+// Close closes the websocket connection.
+func (dc *DataChannel) Close() error {
+	return dc.closeWithError(nil)
+}
+
+func (dc *DataChannel) closeWithError(err error) error {
+	var closeErr error
+
+	dc.closeOnce.Do(func() {
+		dc.mu.Lock()
+		dc.err = err
+		dc.mu.Unlock()
+
+		close(dc.done)
+
+		if dc.conn != nil {
+			_ = dc.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(time.Second))
+			closeErr = dc.conn.Close()
+		}
+	})
+
+	return closeErr
+}
+
+func (dc *DataChannel) sendInput(payloadType PayloadType, payload []byte) error {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	select {
+	case <-dc.done:
+		return errors.New("data channel closed")
+	default:
+	}
+
+	msg := newClientMessage(MessageTypeInputStreamData, dc.sequence, flagData, payloadType, payload)
+
+	if err := dc.writeMessage(msg); err != nil {
+		return err
+	}
+
+	dc.unacked[msg.SequenceNumber] = &outgoingMessage{msg: msg, sentAt: time.Now()}
+	dc.sequence++
+
+	return nil
+}
+
+// writeMessage must be called with mu held.
+func (dc *DataChannel) writeMessage(msg *ClientMessage) error {
+	b, err := msg.MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return dc.conn.WriteMessage(websocket.BinaryMessage, b)
+}
+
+func (dc *DataChannel) sendAcknowledge(msg *ClientMessage) error {
+	b, err := json.Marshal(&acknowledgeContent{
+		MessageType:         msg.MessageType,
+		MessageID:           msg.MessageID.String(),
+		SequenceNumber:      msg.SequenceNumber,
+		IsSequentialMessage: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	return dc.writeMessage(newClientMessage(MessageTypeAcknowledge, 0, flagAck, 0, b))
+}
+
+func (dc *DataChannel) readLoop() {
+	defer close(dc.data)
+
+	for {
+		_, b, err := dc.conn.ReadMessage()
+		if err != nil {
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
+				err = nil
+			}
+
+			select {
+			case <-dc.done:
+				// closed by us
+			default:
+				_ = dc.closeWithError(err)
+			}
+
+			return
+		}
+
+		msg := &ClientMessage{}
+		if err := msg.UnmarshalBinary(b); err != nil {
+			_ = dc.closeWithError(err)
+			return
+		}
+
+		switch msg.MessageType {
+		case MessageTypeOutputStreamData:
+			if err := dc.handleOutputStreamData(msg); err != nil {
+				_ = dc.closeWithError(err)
+				return
+			}
+		case MessageTypeAcknowledge:
+			dc.handleAcknowledge(msg)
+		case MessageTypeChannelClosed:
+			_ = dc.closeWithError(nil)
+			return
+		case MessageTypeStartPublication, MessageTypePausePublication, MessageTypeInputStreamData:
+			// flow control is not implemented; the agent buffers on its side
+		}
+	}
+}
+
+func (dc *DataChannel) handleOutputStreamData(msg *ClientMessage) error {
+	// A failed acknowledge surfaces as read error of the connection
+	_ = dc.sendAcknowledge(msg)
+
+	if msg.SequenceNumber < dc.expected {
+		// duplicate of an already processed message
+		return nil
+	}
+
+	if msg.SequenceNumber > dc.expected {
+		if _, ok := dc.outOfOrder[msg.SequenceNumber]; !ok && len(dc.outOfOrder) == maxOutOfOrder {
+			return fmt.Errorf("message %d is still missing after %d later messages", dc.expected, maxOutOfOrder)
+		}
+
+		dc.outOfOrder[msg.SequenceNumber] = msg
+
+		return nil
+	}
+
+	for {
+		if err := dc.process(msg); err != nil {
+			return err
+		}
+
+		dc.expected++
+
+		next, ok := dc.outOfOrder[dc.expected]
+		if !ok {
+			return nil
+		}
+
+		delete(dc.outOfOrder, dc.expected)
+
+		msg = next
+	}
+}
+
+func (dc *DataChannel) handleAcknowledge(msg *ClientMessage) {
+	ack := &acknowledgeContent{}
+	if err := json.Unmarshal(msg.Payload, ack); err != nil {
+		return
+	}
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	delete(dc.unacked, ack.SequenceNumber)
+}
+
+func (dc *DataChannel) process(msg *ClientMessage) error {
+	switch msg.PayloadType {
+	case PayloadTypeOutput, PayloadTypeStdErr:
+		select {
+		case dc.data <- msg.Payload:
+		case <-dc.done:
+		}
+	case PayloadTypeHandshakeRequest:
+		return dc.handleHandshakeRequest(msg.Payload)
+	case PayloadTypeHandshakeComplete:
+		dc.handshakeOnce.Do(func() { close(dc.handshake) })
+	case PayloadTypeEncChallengeRequest:
+		return ErrKMSEncryption
+	}
+
+	return nil
+}
+
+func (dc *DataChannel) handleHandshakeRequest(payload []byte) error {
+	req := &handshakeRequest{}
+	if err := json.Unmarshal(payload, req); err != nil {
+		return err
+	}
+
+	dc.agentVersion = req.AgentVersion
+
+	resp := &handshakeResponse{
+		ClientVersion: clientVersion,
+		Errors:        []string{},
+	}
+
+	var handshakeErr error
+
+	for _, action := range req.RequestedClientActions {
+		switch action.ActionType {
+		case actionTypeSessionType:
+			st := &sessionTypeRequest{}
+			if err := json.Unmarshal(action.ActionParameters, st); err != nil {
+				return err
+			}
+
+			dc.sessionType = st.SessionType
+			dc.properties = st.Properties
+
+			resp.ProcessedClientActions = append(resp.ProcessedClientActions, processedClientAction{
+				ActionType:   action.ActionType,
+				ActionStatus: actionStatusSuccess,
+			})
+		case actionTypeKMSEncryption:
+			handshakeErr = ErrKMSEncryption
+
+			resp.ProcessedClientActions = append(resp.ProcessedClientActions, processedClientAction{
+				ActionType:   action.ActionType,
+				ActionStatus: actionStatusFailed,
+				Error:        "kms encryption is not supported",
+			})
+		default:
+			resp.ProcessedClientActions = append(resp.ProcessedClientActions, processedClientAction{
+				ActionType:   action.ActionType,
+				ActionStatus: actionStatusUnsupported,
+				Error:        fmt.Sprintf("unsupported action %s", action.ActionType),
+			})
+		}
+	}
+
+	b, err := json.Marshal(resp)
+	if err != nil {
+		return err
+	}
+
+	if err := dc.sendInput(PayloadTypeHandshakeResponse, b); err != nil {
+		return err
+	}
+
+	return handshakeErr
+}
+
+func (dc *DataChannel) resendLoop() {
+	ticker := time.NewTicker(resendInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-dc.done:
+			return
+		case <-ticker.C:
+			dc.mu.Lock()
+			for _, out := range dc.unacked {
+				if time.Since(out.sentAt) < resendTimeout {
+					continue
+				}
+
+				if err := dc.writeMessage(out.msg); err != nil {
+					break
+				}
+
+				out.sentAt = time.Now()
+			}
+			dc.mu.Unlock()
+		}
+	}
+}
@@ -0,0 +1,265 @@
+package ssm
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAgent is a local stand-in for the agent side of the data channel.
+type fakeAgent struct {
+	t        *testing.T
+	conn     *websocket.Conn
+	sequence int64
+}
+
+func newFakeAgentServer(t *testing.T, actions []requestedClientAction, handler func(a *fakeAgent)) *httptest.Server {
+	t.Helper()
+
+	upgrader := websocket.Upgrader{}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		a := &fakeAgent{t: t, conn: conn}
+
+		open := &openDataChannelInput{}
+		if err := conn.ReadJSON(open); err != nil {
+			t.Error(err)
+			return
+		}
+
+		assert.Equal(t, "token", open.TokenValue)
+
+		b, _ := json.Marshal(&handshakeRequest{AgentVersion: "3.1.0.0", RequestedClientActions: actions})
+		a.send(PayloadTypeHandshakeRequest, b)
+
+		resp := &handshakeResponse{}
+		if err := json.Unmarshal(a.readInput(PayloadTypeHandshakeResponse).Payload, resp); err != nil {
+			t.Error(err)
+			return
+		}
+
+		for _, action := range resp.ProcessedClientActions {
+			if action.ActionStatus != actionStatusSuccess {
+				return
+			}
+		}
+
+		a.send(PayloadTypeHandshakeComplete, []byte(`{"HandshakeTimeToComplete":1000000}`))
+
+		handler(a)
+	}))
+}
+
+func (a *fakeAgent) send(payloadType PayloadType, payload []byte) {
+	a.sendWithSequence(a.sequence, payloadType, payload)
+	a.sequence++
+}
+
+func (a *fakeAgent) sendWithSequence(sequence int64, payloadType PayloadType, payload []byte) {
+	msg := newClientMessage(MessageTypeOutputStreamData, sequence, flagData, payloadType, payload)
+
+	b, err := msg.MarshalBinary()
+	require.NoError(a.t, err)
+	require.NoError(a.t, a.conn.WriteMessage(websocket.BinaryMessage, b))
+}
+
+// readInput returns the next input stream message and acknowledges it.
+func (a *fakeAgent) readInput(payloadType PayloadType) *ClientMessage {
+	for {
+		_, b, err := a.conn.ReadMessage()
+		require.NoError(a.t, err)
+
+		msg := &ClientMessage{}
+		require.NoError(a.t, msg.UnmarshalBinary(b))
+
+		if msg.MessageType != MessageTypeInputStreamData {
+			continue
+		}
+
+		ack, _ := json.Marshal(&acknowledgeContent{
+			MessageType:         msg.MessageType,
+			MessageID:           msg.MessageID.String(),
+			SequenceNumber:      msg.SequenceNumber,
+			IsSequentialMessage: true,
+		})
+		ackMsg, _ := newClientMessage(MessageTypeAcknowledge, 0, flagAck, 0, ack).MarshalBinary()
+		require.NoError(a.t, a.conn.WriteMessage(websocket.BinaryMessage, ackMsg))
+
+		if msg.PayloadType == payloadType {
+			return msg
+		}
+	}
+}
+
+func (a *fakeAgent) close() {
+	msg, _ := newClientMessage(MessageTypeChannelClosed, 0, flagData, 0, []byte(`{"Output":"bye"}`)).MarshalBinary()
+	_ = a.conn.WriteMessage(websocket.BinaryMessage, msg)
+}
+
+func wsURL(s *httptest.Server) string {
+	return "ws" + strings.TrimPrefix(s.URL, "http")
+}
+
+func sessionTypeAction(t *testing.T, sessionType string) requestedClientAction {
+	t.Helper()
+
+	b, err := json.Marshal(&sessionTypeRequest{SessionType: sessionType, Properties: json.RawMessage(`{}`)})
+	require.NoError(t, err)
+
+	return requestedClientAction{ActionType: actionTypeSessionType, ActionParameters: b}
+}
+
+func TestDataChannel(t *testing.T) {
+	t.Run("handshake and stream data", func(t *testing.T) {
+		server := newFakeAgentServer(t, []requestedClientAction{sessionTypeAction(t, "Standard_Stream")}, func(a *fakeAgent) {
+			size := &sizeData{}
+			require.NoError(t, json.Unmarshal(a.readInput(PayloadTypeSize).Payload, size))
+			assert.Equal(t, &sizeData{Cols: 80, Rows: 24}, size)
+
+			assert.Equal(t, "date\n", string(a.readInput(PayloadTypeOutput).Payload))
+
+			// send out of order, the client has to restore the order
+			a.sendWithSequence(a.sequence+1, PayloadTypeOutput, []byte("world"))
+			a.sendWithSequence(a.sequence, PayloadTypeOutput, []byte("hello "))
+			a.sequence += 2
+
+			// duplicates are dropped
+			a.sendWithSequence(a.sequence-1, PayloadTypeOutput, []byte("world"))
+
+			a.close()
+		})
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		dc := NewDataChannel(wsURL(server), "token")
+		require.NoError(t, dc.Open(ctx))
+
+		defer dc.Close()
+
+		assert.Equal(t, "3.1.0.0", dc.AgentVersion())
+		assert.Equal(t, "Standard_Stream", dc.SessionType())
+
+		require.NoError(t, dc.SetSize(80, 24))
+
+		_, err := dc.Write([]byte("date\n"))
+		require.NoError(t, err)
+
+		out, err := io.ReadAll(dc)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello world", string(out))
+	})
+
+	t.Run("repeated handshake complete", func(t *testing.T) {
+		server := newFakeAgentServer(t, []requestedClientAction{sessionTypeAction(t, "Standard_Stream")}, func(a *fakeAgent) {
+			a.send(PayloadTypeHandshakeComplete, []byte(`{"HandshakeTimeToComplete":1000000}`))
+			a.send(PayloadTypeOutput, []byte("ok"))
+			a.close()
+		})
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		dc := NewDataChannel(wsURL(server), "token")
+		require.NoError(t, dc.Open(ctx))
+
+		defer dc.Close()
+
+		out, err := io.ReadAll(dc)
+		assert.NoError(t, err)
+		assert.Equal(t, "ok", string(out))
+	})
+
+	t.Run("missing message", func(t *testing.T) {
+		server := newFakeAgentServer(t, []requestedClientAction{sessionTypeAction(t, "Standard_Stream")}, func(a *fakeAgent) {
+			for i := int64(1); i <= maxOutOfOrder+1; i++ {
+				a.sendWithSequence(a.sequence+i, PayloadTypeOutput, []byte("x"))
+			}
+
+			// wait for the client to give up
+			for {
+				if _, _, err := a.conn.ReadMessage(); err != nil {
+					return
+				}
+			}
+		})
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		dc := NewDataChannel(wsURL(server), "token")
+		require.NoError(t, dc.Open(ctx))
+
+		defer dc.Close()
+
+		out, err := io.ReadAll(dc)
+		assert.ErrorContains(t, err, "is still missing after 1024 later messages")
+		assert.Empty(t, out)
+	})
+
+	t.Run("large input is split", func(t *testing.T) {
+		input := strings.Repeat("x", streamDataPayloadSize*2+10)
+
+		server := newFakeAgentServer(t, []requestedClientAction{sessionTypeAction(t, "Port")}, func(a *fakeAgent) {
+			received := ""
+			for len(received) < len(input) {
+				msg := a.readInput(PayloadTypeOutput)
+				assert.LessOrEqual(t, len(msg.Payload), streamDataPayloadSize)
+
+				received += string(msg.Payload)
+			}
+
+			assert.Equal(t, input, received)
+
+			a.close()
+		})
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		dc := NewDataChannel(wsURL(server), "token")
+		require.NoError(t, dc.Open(ctx))
+
+		defer dc.Close()
+
+		n, err := dc.Write([]byte(input))
+		assert.NoError(t, err)
+		assert.Equal(t, len(input), n)
+
+		<-dc.Done()
+	})
+
+	t.Run("kms encryption", func(t *testing.T) {
+		kms := requestedClientAction{ActionType: actionTypeKMSEncryption, ActionParameters: json.RawMessage(`{"KMSKeyId":"key"}`)}
+
+		server := newFakeAgentServer(t, []requestedClientAction{sessionTypeAction(t, "Standard_Stream"), kms}, func(_ *fakeAgent) {})
+		defer server.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		dc := NewDataChannel(wsURL(server), "token")
+		err := dc.Open(ctx)
+		assert.ErrorIs(t, err, ErrKMSEncryption)
+	})
+}
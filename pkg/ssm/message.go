@@ -0,0 +1,187 @@
+package ssm
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MessageType is the type of a message exchanged over the data channel.
+type MessageType string
+
+const (
+	MessageTypeInputStreamData  MessageType = "input_stream_data"
+	MessageTypeOutputStreamData MessageType = "output_stream_data"
+	MessageTypeAcknowledge      MessageType = "acknowledge"
+	MessageTypeChannelClosed    MessageType = "channel_closed"
+	MessageTypeStartPublication MessageType = "start_publication"
+	MessageTypePausePublication MessageType = "pause_publication"
+)
+
+// PayloadType describes the content of a stream data message.
+type PayloadType uint32
+
+const (
+	PayloadTypeOutput               PayloadType = 1
+	PayloadTypeError                PayloadType = 2
+	PayloadTypeSize                 PayloadType = 3
+	PayloadTypeParameter            PayloadType = 4
+	PayloadTypeHandshakeRequest     PayloadType = 5
+	PayloadTypeHandshakeResponse    PayloadType = 6
+	PayloadTypeHandshakeComplete    PayloadType = 7
+	PayloadTypeEncChallengeRequest  PayloadType = 8
+	PayloadTypeEncChallengeResponse PayloadType = 9
+	PayloadTypeFlag                 PayloadType = 10
+	PayloadTypeStdErr               PayloadType = 11
+)
+
+// Flag is the content of a message with PayloadTypeFlag.
+type Flag uint32
+
+const (
+	FlagDisconnectToPort   Flag = 1
+	FlagTerminateSession   Flag = 2
+	FlagConnectToPortError Flag = 3
+)
+
+// Flags of the message header.
+const (
+	flagData uint64 = 0
+	flagAck  uint64 = 3
+)
+
+const (
+	schemaVersion uint32 = 1
+
+	headerLengthLength   = 4
+	messageTypeLength    = 32
+	schemaVersionLength  = 4
+	createdDateLength    = 8
+	sequenceNumberLength = 8
+	flagsLength          = 8
+	messageIDLength      = 16
+	payloadDigestLength  = 32
+	payloadTypeLength    = 4
+	payloadLengthLength  = 4
+
+	messageTypeOffset    = headerLengthLength
+	schemaVersionOffset  = messageTypeOffset + messageTypeLength
+	createdDateOffset    = schemaVersionOffset + schemaVersionLength
+	sequenceNumberOffset = createdDateOffset + createdDateLength
+	flagsOffset          = sequenceNumberOffset + sequenceNumberLength
+	messageIDOffset      = flagsOffset + flagsLength
+	payloadDigestOffset  = messageIDOffset + messageIDLength
+	payloadTypeOffset    = payloadDigestOffset + payloadDigestLength
+	payloadLengthOffset  = payloadTypeOffset + payloadTypeLength
+	payloadOffset        = payloadLengthOffset + payloadLengthLength
+
+	// The header length does not include the payload length field.
+	headerLength = payloadLengthOffset
+)
+
+// ClientMessage is the binary frame that is exchanged with the agent over the websocket.
+type ClientMessage struct {
+	MessageType    MessageType
+	SchemaVersion  uint32
+	CreatedDate    time.Time
+	SequenceNumber int64
+	Flags          uint64
+	MessageID      uuid.UUID
+	PayloadType    PayloadType
+	Payload        []byte
+}
+
+func newClientMessage(messageType MessageType, sequenceNumber int64, flags uint64, payloadType PayloadType, payload []byte) *ClientMessage {
+	return &ClientMessage{
+		MessageType:    messageType,
+		SchemaVersion:  schemaVersion,
+		CreatedDate:    time.Now(),
+		SequenceNumber: sequenceNumber,
+		Flags:          flags,
+		MessageID:      uuid.New(),
+		PayloadType:    payloadType,
+		Payload:        payload,
+	}
+}
+
+// MarshalBinary serializes the message into its wire format.
+func (m *ClientMessage) MarshalBinary() ([]byte, error) {
+	if len(m.MessageType) > messageTypeLength {
+		return nil, fmt.Errorf("message type too long: %s", m.MessageType)
+	}
+
+	b := make([]byte, payloadOffset+len(m.Payload))
+
+	binary.BigEndian.PutUint32(b, headerLength)
+	copy(b[messageTypeOffset:schemaVersionOffset], padRight(string(m.MessageType), messageTypeLength))
+	binary.BigEndian.PutUint32(b[schemaVersionOffset:], m.SchemaVersion)
+	binary.BigEndian.PutUint64(b[createdDateOffset:], uint64(m.CreatedDate.UnixMilli()))
+	binary.BigEndian.PutUint64(b[sequenceNumberOffset:], uint64(m.SequenceNumber))
+	binary.BigEndian.PutUint64(b[flagsOffset:], m.Flags)
+	putUUID(b[messageIDOffset:payloadDigestOffset], m.MessageID)
+
+	digest := sha256.Sum256(m.Payload)
+	copy(b[payloadDigestOffset:payloadTypeOffset], digest[:])
+
+	binary.BigEndian.PutUint32(b[payloadTypeOffset:], uint32(m.PayloadType))
+	binary.BigEndian.PutUint32(b[payloadLengthOffset:], uint32(len(m.Payload)))
+	copy(b[payloadOffset:], m.Payload)
+
+	return b, nil
+}
+
+// UnmarshalBinary deserializes the message from its wire format.
+func (m *ClientMessage) UnmarshalBinary(b []byte) error {
+	if len(b) < payloadOffset {
+		return errors.New("client message too short")
+	}
+
+	hl := binary.BigEndian.Uint32(b)
+	if hl < headerLength || int(hl)+payloadLengthLength > len(b) {
+		return fmt.Errorf("invalid header length: %d", hl)
+	}
+
+	m.MessageType = MessageType(strings.TrimRight(string(b[messageTypeOffset:schemaVersionOffset]), " \x00"))
+	m.SchemaVersion = binary.BigEndian.Uint32(b[schemaVersionOffset:])
+	m.CreatedDate = time.UnixMilli(int64(binary.BigEndian.Uint64(b[createdDateOffset:])))
+	m.SequenceNumber = int64(binary.BigEndian.Uint64(b[sequenceNumberOffset:]))
+	m.Flags = binary.BigEndian.Uint64(b[flagsOffset:])
+	m.MessageID = getUUID(b[messageIDOffset:payloadDigestOffset])
+	m.PayloadType = PayloadType(binary.BigEndian.Uint32(b[payloadTypeOffset:]))
+
+	pl := binary.BigEndian.Uint32(b[hl:])
+	start := int(hl) + payloadLengthLength
+
+	if uint64(start)+uint64(pl) > uint64(len(b)) {
+		return fmt.Errorf("invalid payload length: %d", pl)
+	}
+
+	m.Payload = make([]byte, pl)
+	copy(m.Payload, b[start:start+int(pl)])
+
+	return nil
+}
+
+func padRight(s string, n int) string {
+	return s + strings.Repeat(" ", n-len(s))
+}
+
+// The agent puts the least significant half of the uuid first.
+func putUUID(b []byte, id uuid.UUID) {
+	copy(b[:8], id[8:])
+	copy(b[8:], id[:8])
+}
+
+func getUUID(b []byte) uuid.UUID {
+	var id uuid.UUID
+
+	copy(id[8:], b[:8])
+	copy(id[:8], b[8:])
+
+	return id
+}
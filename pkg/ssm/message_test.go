@@ -0,0 +1,65 @@
+package ssm
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientMessage(t *testing.T) {
+	t.Run("roundtrip", func(t *testing.T) {
+		msg := &ClientMessage{
+			MessageType:    MessageTypeInputStreamData,
+			SchemaVersion:  1,
+			CreatedDate:    time.UnixMilli(1650000000000),
+			SequenceNumber: 42,
+			Flags:          flagData,
+			MessageID:      uuid.MustParse("01234567-89ab-cdef-0123-456789abcdef"),
+			PayloadType:    PayloadTypeOutput,
+			Payload:        []byte("hello"),
+		}
+
+		b, err := msg.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, payloadOffset+5, len(b))
+		assert.Equal(t, uint32(116), binary.BigEndian.Uint32(b))
+
+		actual := &ClientMessage{}
+		assert.NoError(t, actual.UnmarshalBinary(b))
+		assert.Equal(t, msg.MessageType, actual.MessageType)
+		assert.Equal(t, msg.SchemaVersion, actual.SchemaVersion)
+		assert.True(t, msg.CreatedDate.Equal(actual.CreatedDate))
+		assert.Equal(t, msg.SequenceNumber, actual.SequenceNumber)
+		assert.Equal(t, msg.Flags, actual.Flags)
+		assert.Equal(t, msg.MessageID, actual.MessageID)
+		assert.Equal(t, msg.PayloadType, actual.PayloadType)
+		assert.Equal(t, msg.Payload, actual.Payload)
+	})
+
+	t.Run("message id layout", func(t *testing.T) {
+		msg := newClientMessage(MessageTypeAcknowledge, 0, flagAck, 0, nil)
+		msg.MessageID = uuid.MustParse("00010203-0405-0607-0809-0a0b0c0d0e0f")
+
+		b, err := msg.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, []byte{8, 9, 10, 11, 12, 13, 14, 15, 0, 1, 2, 3, 4, 5, 6, 7}, b[messageIDOffset:payloadDigestOffset])
+	})
+
+	t.Run("too short", func(t *testing.T) {
+		msg := &ClientMessage{}
+		assert.Error(t, msg.UnmarshalBinary([]byte{0, 0, 0, 116}))
+	})
+
+	t.Run("invalid payload length", func(t *testing.T) {
+		msg := newClientMessage(MessageTypeOutputStreamData, 0, flagData, PayloadTypeOutput, []byte("hello"))
+
+		b, err := msg.MarshalBinary()
+		assert.NoError(t, err)
+
+		binary.BigEndian.PutUint32(b[payloadLengthOffset:], 100)
+		assert.Error(t, (&ClientMessage{}).UnmarshalBinary(b))
+	})
+}
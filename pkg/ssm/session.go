@@ -2,12 +2,14 @@ package ssm
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
+	"io"
+	"os"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
-	"github.com/hupe1980/gotoaws/internal/exec"
+	"github.com/hupe1980/gotoaws/pkg/recording"
+	"golang.org/x/term"
 )
 
 type Session struct {
@@ -17,7 +19,6 @@ type Session struct {
 	SSMClient  *ssm.Client
 	Input      *ssm.StartSessionInput
 	Profile    string
-	Plugin     string
 	Region     string
 	Timeout    time.Duration
 }
@@ -34,42 +35,83 @@ func (sess *Session) Close() error {
 	return nil
 }
 
-func (sess *Session) RunPlugin() error {
-	sessJSON, err := json.Marshal(map[string]*string{
-		"SessionId":  sess.ID,
-		"StreamUrl":  sess.StreamURL,
-		"TokenValue": sess.TokenValue,
-	})
-	if err != nil {
-		return err
+// OpenDataChannel connects to the stream url of the session and completes the handshake with the agent.
+func (sess *Session) OpenDataChannel() (*DataChannel, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sess.Timeout)
+	defer cancel()
+
+	dc := NewDataChannel(*sess.StreamURL, *sess.TokenValue)
+	if err := dc.Open(ctx); err != nil {
+		return nil, err
 	}
 
-	inputJSON, err := json.Marshal(sess.Input)
+	return dc, nil
+}
+
+// RunShell attaches the local terminal to the session.
+func (sess *Session) RunShell() error {
+	return sess.RecordShell(nil)
+}
+
+// RecordShell attaches the local terminal to the session. The input, output and
+// size changes of the terminal are recorded if rec is not nil.
+func (sess *Session) RecordShell(rec *recording.Recorder) error {
+	dc, err := sess.OpenDataChannel()
 	if err != nil {
 		return err
 	}
+	defer dc.Close()
 
-	cmd := exec.NewCmd()
+	var (
+		stdin  io.Reader = os.Stdin
+		stdout io.Writer = os.Stdout
+	)
 
-	return cmd.InteractiveRun(sess.Plugin, string(sessJSON), sess.Region, "StartSession", sess.Profile, string(inputJSON))
-}
+	fd := int(os.Stdin.Fd())
 
-func (sess *Session) ProxyCommand() (string, error) {
-	sessJSON, err := json.Marshal(map[string]*string{
-		"SessionId":  sess.ID,
-		"StreamUrl":  sess.StreamURL,
-		"TokenValue": sess.TokenValue,
-	})
-	if err != nil {
-		return "", err
+	if rec != nil {
+		cols, rows, err := term.GetSize(fd)
+		if err != nil {
+			cols, rows = 80, 24
+		}
+
+		rec.Metadata.SessionID = aws.ToString(sess.ID)
+
+		if err := rec.Start(cols, rows); err != nil {
+			return err
+		}
+
+		stdin = io.TeeReader(os.Stdin, rec.Input())
+		stdout = io.MultiWriter(os.Stdout, rec.Output())
 	}
 
-	inputJSON, err := json.Marshal(sess.Input)
-	if err != nil {
-		return "", err
+	if term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+
+		defer func() {
+			_ = term.Restore(fd, state)
+		}()
+
+		stop := WatchTerminalSize(fd, func(cols, rows int) {
+			_ = dc.SetSize(uint32(cols), uint32(rows))
+
+			if rec != nil {
+				_ = rec.Resize(cols, rows)
+			}
+		})
+		defer stop()
 	}
 
-	pc := fmt.Sprintf("ProxyCommand=%s '%s' %s %s %s '%s'", sess.Plugin, string(sessJSON), sess.Region, "StartSession", sess.Profile, string(inputJSON))
+	go func() {
+		_, _ = io.Copy(dc, stdin)
+	}()
 
-	return pc, nil
+	if _, err := io.Copy(stdout, dc); err != nil {
+		return err
+	}
+
+	return nil
 }
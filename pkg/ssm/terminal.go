@@ -0,0 +1,45 @@
+//go:build !windows
+// +build !windows
+
+package ssm
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+
+	"golang.org/x/term"
+)
+
+// WatchTerminalSize calls fn with the current terminal size and again on every SIGWINCH.
+// The returned func stops watching.
+func WatchTerminalSize(fd int, fn func(cols, rows int)) func() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGWINCH)
+
+	done := make(chan struct{})
+
+	notify := func() {
+		if cols, rows, err := term.GetSize(fd); err == nil {
+			fn(cols, rows)
+		}
+	}
+
+	notify()
+
+	go func() {
+		for {
+			select {
+			case <-sigs:
+				notify()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(sigs)
+		close(done)
+	}
+}
@@ -0,0 +1,49 @@
+//go:build windows
+// +build windows
+
+package ssm
+
+import (
+	"time"
+
+	"golang.org/x/term"
+)
+
+// WatchTerminalSize calls fn with the current terminal size and polls for changes,
+// because there is no SIGWINCH on windows.
+func WatchTerminalSize(fd int, fn func(cols, rows int)) func() {
+	done := make(chan struct{})
+
+	lastCols, lastRows := 0, 0
+
+	notify := func() {
+		cols, rows, err := term.GetSize(fd)
+		if err != nil || (cols == lastCols && rows == lastRows) {
+			return
+		}
+
+		lastCols, lastRows = cols, rows
+
+		fn(cols, rows)
+	}
+
+	notify()
+
+	go func() {
+		ticker := time.NewTicker(500 * time.Millisecond)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				notify()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+	}
+}
@@ -0,0 +1,49 @@
+package ssm
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	// Agents starting with this version multiplex port forwarding connections with smux.
+	muxSupportedAgentVersion = "3.0.196.0"
+
+	// Agents starting with this version do not require smux keep alive messages.
+	muxKeepAliveDisabledAgentVersion = "3.1.1511.0"
+)
+
+// SupportsMux reports whether the agent multiplexes port forwarding connections.
+func SupportsMux(agentVersion string) bool {
+	return versionAtLeast(agentVersion, muxSupportedAgentVersion)
+}
+
+// SupportsMuxKeepAliveDisabled reports whether smux keep alive messages can be disabled.
+func SupportsMuxKeepAliveDisabled(agentVersion string) bool {
+	return versionAtLeast(agentVersion, muxKeepAliveDisabledAgentVersion)
+}
+
+// versionAtLeast compares dotted version strings. Invalid versions are treated as lower.
+func versionAtLeast(version, min string) bool {
+	v := strings.Split(version, ".")
+	m := strings.Split(min, ".")
+
+	for i := range m {
+		if i >= len(v) {
+			return false
+		}
+
+		a, err := strconv.Atoi(v[i])
+		if err != nil {
+			return false
+		}
+
+		b, _ := strconv.Atoi(m[i])
+
+		if a != b {
+			return a > b
+		}
+	}
+
+	return true
+}
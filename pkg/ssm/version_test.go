@@ -0,0 +1,24 @@
+package ssm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVersionAtLeast(t *testing.T) {
+	assert.True(t, versionAtLeast("3.0.196.0", "3.0.196.0"))
+	assert.True(t, versionAtLeast("3.1.0.0", "3.0.196.0"))
+	assert.True(t, versionAtLeast("3.0.1000.0", "3.0.196.0"))
+	assert.False(t, versionAtLeast("3.0.195.9", "3.0.196.0"))
+	assert.False(t, versionAtLeast("2.3.672.0", "3.0.196.0"))
+	assert.False(t, versionAtLeast("", "3.0.196.0"))
+	assert.False(t, versionAtLeast("3.x", "3.0.196.0"))
+}
+
+func TestSupportsMux(t *testing.T) {
+	assert.True(t, SupportsMux("3.2.0.0"))
+	assert.False(t, SupportsMux("2.3.672.0"))
+	assert.True(t, SupportsMuxKeepAliveDisabled("3.1.1511.0"))
+	assert.False(t, SupportsMuxKeepAliveDisabled("3.1.1000.0"))
+}
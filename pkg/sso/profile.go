@@ -0,0 +1,201 @@
+package sso
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/config"
+)
+
+// ProfileInput describes a profile that gets its credentials from the portal.
+type ProfileInput struct {
+	// Profile is the name of the profile, e.g. default
+	Profile string
+
+	// SessionName is the name of the sso-session section. Empty writes a legacy profile.
+	SessionName string
+
+	StartURL  string
+	SSORegion string
+	Scopes    []string
+	AccountID string
+	RoleName  string
+
+	// Region is the default region of the profile. Empty keeps the current value.
+	Region string
+}
+
+// credentialSourceKeys configure other credentials of a profile, which take precedence over sso.
+var credentialSourceKeys = []string{
+	"role_arn",
+	"source_profile",
+	"credential_source",
+	"credential_process",
+	"web_identity_token_file",
+	"aws_access_key_id",
+	"aws_secret_access_key",
+	"aws_session_token",
+}
+
+type keyValue struct {
+	key   string
+	value string
+}
+
+// ConfigFile returns the path of the shared config file.
+func ConfigFile() string {
+	if path := os.Getenv("AWS_CONFIG_FILE"); path != "" {
+		return path
+	}
+
+	return config.DefaultSharedConfigFilename()
+}
+
+// WriteProfile creates or updates the profile and its sso-session in the shared config file.
+// Other sections and keys are kept, except the keys of other credentials of the profile.
+func WriteProfile(input *ProfileInput) error {
+	if input.Profile == "" {
+		return errors.New("profile name is required")
+	}
+
+	path := ConfigFile()
+
+	b, err := os.ReadFile(path) // nolint: gosec // shared config file
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	content := upsertProfile(string(b), input)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, []byte(content), 0o600)
+}
+
+func upsertProfile(content string, input *ProfileInput) string {
+	var lines []string
+	if content != "" {
+		lines = strings.Split(strings.TrimRight(content, "\n"), "\n")
+	}
+
+	profile := []keyValue{}
+
+	// Keys of the other sso style must not be mixed with the new ones
+	remove := append([]string{}, credentialSourceKeys...)
+
+	if input.SessionName != "" {
+		scopes := input.Scopes
+		if len(scopes) == 0 {
+			scopes = []string{DefaultScope}
+		}
+
+		lines = upsertSection(lines, "sso-session "+input.SessionName, []keyValue{
+			{"sso_start_url", input.StartURL},
+			{"sso_region", input.SSORegion},
+			{"sso_registration_scopes", strings.Join(scopes, ",")},
+		})
+
+		profile = append(profile, keyValue{"sso_session", input.SessionName})
+		remove = append(remove, "sso_start_url", "sso_region", "sso_registration_scopes")
+	} else {
+		profile = append(profile, keyValue{"sso_start_url", input.StartURL}, keyValue{"sso_region", input.SSORegion})
+		remove = append(remove, "sso_session")
+	}
+
+	profile = append(profile, keyValue{"sso_account_id", input.AccountID}, keyValue{"sso_role_name", input.RoleName})
+
+	if input.Region != "" {
+		profile = append(profile, keyValue{"region", input.Region})
+	}
+
+	header := "profile " + input.Profile
+	if input.Profile == "default" {
+		header = "default"
+	}
+
+	lines = upsertSection(lines, header, profile, remove...)
+
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// upsertSection sets the keys of the section and appends the section if it does not exist.
+// The keys to remove are deleted from an existing section.
+func upsertSection(lines []string, header string, values []keyValue, remove ...string) []string {
+	start := -1
+
+	for i, line := range lines {
+		if sectionName(line) == header {
+			start = i
+			break
+		}
+	}
+
+	if start == -1 {
+		if len(lines) > 0 {
+			lines = append(lines, "")
+		}
+
+		lines = append(lines, "["+header+"]")
+		for _, kv := range values {
+			lines = append(lines, kv.key+" = "+kv.value)
+		}
+
+		return lines
+	}
+
+	// The section ends before the next header, blank lines at the end stay behind it
+	end := start + 1
+	for end < len(lines) && sectionName(lines[end]) == "" {
+		end++
+	}
+
+	last := end
+	for last > start+1 && strings.TrimSpace(lines[last-1]) == "" {
+		last--
+	}
+
+	section := []string{}
+
+	for _, line := range lines[start+1 : last] {
+		if key, _, ok := strings.Cut(line, "="); ok && slices.Contains(remove, strings.TrimSpace(key)) {
+			continue
+		}
+
+		section = append(section, line)
+	}
+
+	for _, kv := range values {
+		found := false
+
+		for i, line := range section {
+			if key, _, ok := strings.Cut(line, "="); ok && strings.TrimSpace(key) == kv.key {
+				section[i] = kv.key + " = " + kv.value
+				found = true
+			}
+		}
+
+		if !found {
+			section = append(section, kv.key+" = "+kv.value)
+		}
+	}
+
+	result := append([]string{}, lines[:start+1]...)
+	result = append(result, section...)
+
+	return append(result, lines[last:]...)
+}
+
+// sectionName returns the name of a section header line, e.g. "profile dev", or an empty string.
+func sectionName(line string) string {
+	line = strings.TrimSpace(line)
+	if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+		return ""
+	}
+
+	return strings.Join(strings.Fields(line[1:len(line)-1]), " ")
+}
@@ -0,0 +1,137 @@
+package sso
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpsertProfile(t *testing.T) {
+	input := &ProfileInput{
+		Profile:     "dev",
+		SessionName: "my-org",
+		StartURL:    "https://my-org.awsapps.com/start",
+		SSORegion:   "eu-central-1",
+		AccountID:   "111111111111",
+		RoleName:    "Admin",
+		Region:      "eu-west-1",
+	}
+
+	t.Run("new file", func(t *testing.T) {
+		assert.Equal(t, `[sso-session my-org]
+sso_start_url = https://my-org.awsapps.com/start
+sso_region = eu-central-1
+sso_registration_scopes = sso:account:access
+
+[profile dev]
+sso_session = my-org
+sso_account_id = 111111111111
+sso_role_name = Admin
+region = eu-west-1
+`, upsertProfile("", input))
+	})
+
+	t.Run("existing sections", func(t *testing.T) {
+		content := `[default]
+region = us-east-1
+
+[profile  dev]
+sso_account_id=222222222222
+output = json
+
+[profile prod]
+region = eu-central-1
+`
+
+		assert.Equal(t, `[default]
+region = us-east-1
+
+[profile  dev]
+sso_account_id = 111111111111
+output = json
+sso_session = my-org
+sso_role_name = Admin
+region = eu-west-1
+
+[profile prod]
+region = eu-central-1
+
+[sso-session my-org]
+sso_start_url = https://my-org.awsapps.com/start
+sso_region = eu-central-1
+sso_registration_scopes = sso:account:access
+`, upsertProfile(content, input))
+	})
+
+	t.Run("other credentials", func(t *testing.T) {
+		content := `[profile dev]
+sso_start_url = https://old-org.awsapps.com/start
+sso_region = us-east-1
+role_arn = arn:aws:iam::222222222222:role/Admin
+source_profile = default
+credential_process = /usr/local/bin/creds
+output = json
+`
+
+		assert.Equal(t, `[profile dev]
+output = json
+sso_session = my-org
+sso_account_id = 111111111111
+sso_role_name = Admin
+region = eu-west-1
+
+[sso-session my-org]
+sso_start_url = https://my-org.awsapps.com/start
+sso_region = eu-central-1
+sso_registration_scopes = sso:account:access
+`, upsertProfile(content, input))
+
+		assert.Equal(t, `[profile dev]
+output = json
+sso_start_url = https://my-org.awsapps.com/start
+sso_region = eu-central-1
+sso_account_id = 111111111111
+sso_role_name = Admin
+`, upsertProfile(`[profile dev]
+sso_session = my-org
+output = json
+`, &ProfileInput{
+			Profile:   "dev",
+			StartURL:  "https://my-org.awsapps.com/start",
+			SSORegion: "eu-central-1",
+			AccountID: "111111111111",
+			RoleName:  "Admin",
+		}))
+	})
+
+	t.Run("legacy default profile", func(t *testing.T) {
+		assert.Equal(t, `[default]
+sso_start_url = https://my-org.awsapps.com/start
+sso_region = eu-central-1
+sso_account_id = 111111111111
+sso_role_name = Admin
+`, upsertProfile("", &ProfileInput{
+			Profile:   "default",
+			StartURL:  "https://my-org.awsapps.com/start",
+			SSORegion: "eu-central-1",
+			AccountID: "111111111111",
+			RoleName:  "Admin",
+		}))
+	})
+}
+
+func TestWriteProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	t.Setenv("AWS_CONFIG_FILE", path)
+
+	require.NoError(t, WriteProfile(&ProfileInput{Profile: "dev", SessionName: "my-org", AccountID: "111111111111"}))
+	require.NoError(t, WriteProfile(&ProfileInput{Profile: "dev", SessionName: "my-org", AccountID: "222222222222"}))
+
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(b), "sso_account_id = 222222222222")
+	assert.NotContains(t, string(b), "111111111111")
+}
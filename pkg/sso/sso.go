@@ -0,0 +1,305 @@
+// Package sso implements the IAM Identity Center login with the oidc device authorization flow.
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	aws_sso "github.com/aws/aws-sdk-go-v2/service/sso"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc"
+	"github.com/aws/aws-sdk-go-v2/service/ssooidc/types"
+)
+
+const (
+	// DefaultScope grants access to the accounts of the portal
+	DefaultScope = "sso:account:access"
+
+	clientName      = "gotoaws"
+	clientType      = "public"
+	deviceCodeGrant = "urn:ietf:params:oauth:grant-type:device_code"
+
+	// defaultInterval is the polling interval if the service does not return one
+	defaultInterval = 5 * time.Second
+
+	// slowDownInterval is added to the interval if the service asks to slow down
+	slowDownInterval = 5 * time.Second
+)
+
+// Account is an account of the portal.
+type Account struct {
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// Role is a permission set assigned to the user in an account.
+type Role struct {
+	AccountID string `json:"accountId"`
+	Name      string `json:"name"`
+}
+
+// LoginInput configures the device authorization.
+type LoginInput struct {
+	// StartURL of the portal, e.g. https://my-org.awsapps.com/start
+	StartURL string
+
+	// SessionName is the name of the sso-session. Empty caches the token by start url like legacy profiles.
+	SessionName string
+
+	// Scopes of the token. The default is sso:account:access.
+	Scopes []string
+
+	// Force starts a new authorization even if the cached token is valid
+	Force bool
+
+	// Authorize is called with the verification url and the code the user has to confirm
+	Authorize func(url, code string) error
+}
+
+// OIDCClient is the subset of the sso oidc api used for the device authorization.
+type OIDCClient interface {
+	RegisterClient(ctx context.Context, params *ssooidc.RegisterClientInput, optFns ...func(*ssooidc.Options)) (*ssooidc.RegisterClientOutput, error)
+	StartDeviceAuthorization(ctx context.Context, params *ssooidc.StartDeviceAuthorizationInput, optFns ...func(*ssooidc.Options)) (*ssooidc.StartDeviceAuthorizationOutput, error)
+	CreateToken(ctx context.Context, params *ssooidc.CreateTokenInput, optFns ...func(*ssooidc.Options)) (*ssooidc.CreateTokenOutput, error)
+}
+
+// PortalClient is the subset of the sso portal api used to list accounts and roles.
+type PortalClient interface {
+	aws_sso.ListAccountsAPIClient
+	aws_sso.ListAccountRolesAPIClient
+}
+
+// Client logs in to the portal and lists the assignments of the user.
+type Client interface {
+	// Login returns the cached token or starts a device authorization. The token is cached compatible with the AWS CLI.
+	Login(ctx context.Context, input *LoginInput) (*Token, error)
+
+	// Accounts returns the accounts of the user sorted by name.
+	Accounts(ctx context.Context, token *Token) ([]Account, error)
+
+	// Roles returns the permission sets of the user in the account sorted by name.
+	Roles(ctx context.Context, token *Token, accountID string) ([]Role, error)
+}
+
+type client struct {
+	region string
+	oidc   OIDCClient
+	portal PortalClient
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+// NewClient creates a Client for the sso region. The endpoints of awsCfg are used, credentials are not required.
+func NewClient(awsCfg aws.Config, region string) Client {
+	awsCfg = awsCfg.Copy()
+	awsCfg.Region = region
+
+	return &client{
+		region: region,
+		oidc:   ssooidc.NewFromConfig(awsCfg),
+		portal: aws_sso.NewFromConfig(awsCfg),
+		sleep:  sleep,
+	}
+}
+
+func (c *client) Login(ctx context.Context, input *LoginInput) (*Token, error) {
+	if input.StartURL == "" {
+		return nil, errors.New("sso start url is required")
+	}
+
+	cached, err := ReadToken(input.SessionName, input.StartURL)
+	if err != nil {
+		cached = nil
+	}
+
+	if cached != nil && !input.Force && cached.Valid(15*time.Minute) {
+		return cached, nil
+	}
+
+	token := &Token{StartURL: input.StartURL, Region: c.region}
+
+	if cached != nil && cached.registered() && cached.Region == c.region {
+		token.ClientID = cached.ClientID
+		token.ClientSecret = cached.ClientSecret
+		token.RegistrationExpiresAt = cached.RegistrationExpiresAt
+	} else if err := c.register(ctx, token, input); err != nil {
+		return nil, err
+	}
+
+	if err := c.authorize(ctx, token, input); err != nil {
+		return nil, err
+	}
+
+	if err := WriteToken(input.SessionName, token); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+func (c *client) register(ctx context.Context, token *Token, input *LoginInput) error {
+	params := &ssooidc.RegisterClientInput{
+		ClientName: aws.String(clientName),
+		ClientType: aws.String(clientType),
+	}
+
+	// Refresh tokens are only issued for sso-sessions with scopes
+	if input.SessionName != "" {
+		params.Scopes = input.Scopes
+		if len(params.Scopes) == 0 {
+			params.Scopes = []string{DefaultScope}
+		}
+	}
+
+	output, err := c.oidc.RegisterClient(ctx, params)
+	if err != nil {
+		return fmt.Errorf("cannot register client: %w", err)
+	}
+
+	expires := time.Unix(output.ClientSecretExpiresAt, 0).UTC()
+
+	token.ClientID = aws.ToString(output.ClientId)
+	token.ClientSecret = aws.ToString(output.ClientSecret)
+	token.RegistrationExpiresAt = &expires
+
+	return nil
+}
+
+func (c *client) authorize(ctx context.Context, token *Token, input *LoginInput) error {
+	device, err := c.oidc.StartDeviceAuthorization(ctx, &ssooidc.StartDeviceAuthorizationInput{
+		ClientId:     aws.String(token.ClientID),
+		ClientSecret: aws.String(token.ClientSecret),
+		StartUrl:     aws.String(input.StartURL),
+	})
+	if err != nil {
+		return fmt.Errorf("cannot start device authorization: %w", err)
+	}
+
+	url := aws.ToString(device.VerificationUriComplete)
+	if url == "" {
+		url = aws.ToString(device.VerificationUri)
+	}
+
+	if input.Authorize != nil {
+		if err := input.Authorize(url, aws.ToString(device.UserCode)); err != nil {
+			return err
+		}
+	}
+
+	interval := time.Duration(device.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	deadline := time.Now().Add(time.Duration(device.ExpiresIn) * time.Second)
+
+	for {
+		output, err := c.oidc.CreateToken(ctx, &ssooidc.CreateTokenInput{
+			ClientId:     aws.String(token.ClientID),
+			ClientSecret: aws.String(token.ClientSecret),
+			GrantType:    aws.String(deviceCodeGrant),
+			DeviceCode:   device.DeviceCode,
+		})
+		if err == nil {
+			token.AccessToken = aws.ToString(output.AccessToken)
+			token.RefreshToken = aws.ToString(output.RefreshToken)
+			token.ExpiresAt = time.Now().Add(time.Duration(output.ExpiresIn) * time.Second).UTC().Truncate(time.Second)
+
+			return nil
+		}
+
+		var (
+			pending  *types.AuthorizationPendingException
+			slowDown *types.SlowDownException
+		)
+
+		switch {
+		case errors.As(err, &pending):
+		case errors.As(err, &slowDown):
+			interval += slowDownInterval
+		default:
+			return fmt.Errorf("authorization failed: %w", err)
+		}
+
+		if device.ExpiresIn > 0 && time.Now().Add(interval).After(deadline) {
+			return errors.New("authorization expired before it was confirmed")
+		}
+
+		if err := c.sleep(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *client) Accounts(ctx context.Context, token *Token) ([]Account, error) {
+	var accounts []Account
+
+	paginator := aws_sso.NewListAccountsPaginator(c.portal, &aws_sso.ListAccountsInput{
+		AccessToken: aws.String(token.AccessToken),
+	})
+
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, a := range output.AccountList {
+			accounts = append(accounts, Account{
+				ID:    aws.ToString(a.AccountId),
+				Name:  aws.ToString(a.AccountName),
+				Email: aws.ToString(a.EmailAddress),
+			})
+		}
+	}
+
+	sort.SliceStable(accounts, func(i, j int) bool {
+		return accounts[i].Name < accounts[j].Name
+	})
+
+	return accounts, nil
+}
+
+func (c *client) Roles(ctx context.Context, token *Token, accountID string) ([]Role, error) {
+	var roles []Role
+
+	paginator := aws_sso.NewListAccountRolesPaginator(c.portal, &aws_sso.ListAccountRolesInput{
+		AccessToken: aws.String(token.AccessToken),
+		AccountId:   aws.String(accountID),
+	})
+
+	for paginator.HasMorePages() {
+		output, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, r := range output.RoleList {
+			roles = append(roles, Role{
+				AccountID: aws.ToString(r.AccountId),
+				Name:      aws.ToString(r.RoleName),
+			})
+		}
+	}
+
+	sort.SliceStable(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+
+	return roles, nil
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
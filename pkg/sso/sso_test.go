@@ -0,0 +1,152 @@
+package sso
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// portal is a stand-in for the oidc and portal endpoints.
+type portal struct {
+	registrations int
+	tokenRequests int
+	pending       int
+}
+
+func (p *portal) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	reply := func(v interface{}) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(v)
+	}
+
+	switch r.URL.Path {
+	case "/client/register":
+		p.registrations++
+		reply(map[string]interface{}{
+			"clientId":              "client-id",
+			"clientSecret":          "client-secret",
+			"clientSecretExpiresAt": time.Now().Add(90 * 24 * time.Hour).Unix(),
+		})
+	case "/device_authorization":
+		reply(map[string]interface{}{
+			"deviceCode":              "device-code",
+			"userCode":                "ABCD-EFGH",
+			"verificationUriComplete": "https://device.sso.eu-central-1.amazonaws.com/?user_code=ABCD-EFGH",
+			"interval":                1,
+			"expiresIn":               600,
+		})
+	case "/token":
+		p.tokenRequests++
+		if p.tokenRequests <= p.pending {
+			w.Header().Set("X-Amzn-ErrorType", "AuthorizationPendingException")
+			w.WriteHeader(http.StatusBadRequest)
+			reply(map[string]string{"error": "authorization_pending"})
+
+			return
+		}
+
+		reply(map[string]interface{}{
+			"accessToken":  "access-token",
+			"refreshToken": "refresh-token",
+			"tokenType":    "Bearer",
+			"expiresIn":    3600,
+		})
+	case "/assignment/accounts":
+		if r.Header.Get("x-amz-sso_bearer_token") != "access-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		reply(map[string]interface{}{
+			"accountList": []map[string]string{
+				{"accountId": "222222222222", "accountName": "prod", "emailAddress": "prod@example.com"},
+				{"accountId": "111111111111", "accountName": "dev", "emailAddress": "dev@example.com"},
+			},
+		})
+	case "/assignment/roles":
+		reply(map[string]interface{}{
+			"roleList": []map[string]string{
+				{"accountId": r.URL.Query().Get("account_id"), "roleName": "ReadOnly"},
+				{"accountId": r.URL.Query().Get("account_id"), "roleName": "Admin"},
+			},
+		})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestClient(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	p := &portal{pending: 2}
+	srv := httptest.NewServer(p)
+	defer srv.Close()
+
+	c := NewClient(aws.Config{BaseEndpoint: aws.String(srv.URL)}, "eu-central-1").(*client)
+
+	var waits []time.Duration
+	c.sleep = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+
+	var authorizedURL, authorizedCode string
+
+	input := &LoginInput{
+		StartURL:    "https://my-org.awsapps.com/start",
+		SessionName: "my-org",
+		Authorize: func(url, code string) error {
+			authorizedURL, authorizedCode = url, code
+			return nil
+		},
+	}
+
+	token, err := c.Login(context.Background(), input)
+	require.NoError(t, err)
+	assert.Equal(t, "access-token", token.AccessToken)
+	assert.Equal(t, "refresh-token", token.RefreshToken)
+	assert.Equal(t, "client-id", token.ClientID)
+	assert.Equal(t, "eu-central-1", token.Region)
+	assert.True(t, token.Valid(time.Minute))
+	assert.Equal(t, "ABCD-EFGH", authorizedCode)
+	assert.Contains(t, authorizedURL, "user_code=ABCD-EFGH")
+	assert.Equal(t, []time.Duration{time.Second, time.Second}, waits)
+
+	cached, err := ReadToken("my-org", "")
+	require.NoError(t, err)
+	assert.Equal(t, token.AccessToken, cached.AccessToken)
+	assert.Equal(t, "https://my-org.awsapps.com/start", cached.StartURL)
+
+	t.Run("cached token", func(t *testing.T) {
+		_, err := c.Login(context.Background(), input)
+		require.NoError(t, err)
+		assert.Equal(t, 3, p.tokenRequests)
+	})
+
+	t.Run("force reuses registration", func(t *testing.T) {
+		_, err := c.Login(context.Background(), &LoginInput{StartURL: input.StartURL, SessionName: "my-org", Force: true})
+		require.NoError(t, err)
+		assert.Equal(t, 1, p.registrations)
+		assert.Equal(t, 4, p.tokenRequests)
+	})
+
+	t.Run("accounts", func(t *testing.T) {
+		accounts, err := c.Accounts(context.Background(), token)
+		require.NoError(t, err)
+		require.Len(t, accounts, 2)
+		assert.Equal(t, Account{ID: "111111111111", Name: "dev", Email: "dev@example.com"}, accounts[0])
+	})
+
+	t.Run("roles", func(t *testing.T) {
+		roles, err := c.Roles(context.Background(), token, "111111111111")
+		require.NoError(t, err)
+		assert.Equal(t, []Role{{AccountID: "111111111111", Name: "Admin"}, {AccountID: "111111111111", Name: "ReadOnly"}}, roles)
+	})
+}
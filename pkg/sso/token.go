@@ -0,0 +1,81 @@
+package sso
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+)
+
+// Token is an sso access token in the format of the AWS CLI cache in ~/.aws/sso/cache.
+type Token struct {
+	StartURL              string     `json:"startUrl"`
+	Region                string     `json:"region"`
+	AccessToken           string     `json:"accessToken"`
+	ExpiresAt             time.Time  `json:"expiresAt"`
+	RefreshToken          string     `json:"refreshToken,omitempty"`
+	ClientID              string     `json:"clientId,omitempty"`
+	ClientSecret          string     `json:"clientSecret,omitempty"`
+	RegistrationExpiresAt *time.Time `json:"registrationExpiresAt,omitempty"`
+}
+
+// Valid reports whether the token can be used for at least the given duration.
+func (t *Token) Valid(d time.Duration) bool {
+	return t.AccessToken != "" && time.Now().Add(d).Before(t.ExpiresAt)
+}
+
+// registered reports whether the client registration of the token can be reused.
+func (t *Token) registered() bool {
+	return t.ClientID != "" && t.RegistrationExpiresAt != nil && time.Now().Add(time.Hour).Before(*t.RegistrationExpiresAt)
+}
+
+// cacheKey returns the key of the token in the cache. Profiles with an sso-session use its
+// name, legacy profiles the start url.
+func cacheKey(sessionName, startURL string) string {
+	if sessionName != "" {
+		return sessionName
+	}
+
+	return startURL
+}
+
+// ReadToken returns the cached token of the sso-session or, if the name is empty, of the start url.
+func ReadToken(sessionName, startURL string) (*Token, error) {
+	path, err := ssocreds.StandardCachedTokenFilepath(cacheKey(sessionName, startURL))
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := os.ReadFile(path) // nolint: gosec // path of the sso cache
+	if err != nil {
+		return nil, err
+	}
+
+	token := &Token{}
+	if err := json.Unmarshal(b, token); err != nil {
+		return nil, err
+	}
+
+	return token, nil
+}
+
+// WriteToken stores the token where the AWS CLI and SDKs look for it.
+func WriteToken(sessionName string, token *Token) error {
+	path, err := ssocreds.StandardCachedTokenFilepath(cacheKey(sessionName, token.StartURL))
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(token, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b, 0o600)
+}